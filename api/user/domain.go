@@ -1,15 +1,234 @@
 package user
 
+import (
+	"encoding/json"
+	"time"
+)
+
+// Role grants a user the permissions the authz policies attach to it.
+type Role string
+
+const (
+	RoleAdmin    Role = "admin"
+	RoleOperator Role = "operator"
+	// RoleSelf is never stored. Policies use it to grant
+	// a principal access to its own user.
+	RoleSelf Role = "self"
+)
+
 // User represents the data model for a user in the database.
 type User struct {
-	ID    string `json:"id"`
-	Name  string `json:"name"`
-	Email string `json:"email"`
+	ID            string    `json:"id"`
+	Name          string    `json:"name"`
+	Email         string    `json:"email"`
+	Roles         []Role    `json:"roles,omitempty"`
+	EmailVerified bool      `json:"email_verified"`
+	CreatedAt     time.Time `json:"created_at"`
+	// Scopes limits a principal authenticated with an API key to
+	// the actions listed. It is nil for every other principal.
+	Scopes []string `json:"-"`
 }
 
 // CreateUserDTO (Data Transfer Object) is used to capture
 // the request body when creating a new user.
 type CreateUserDTO struct {
+	Name     string `json:"name" jsonschema:"description=Display name of the user"`
+	Email    string `json:"email" jsonschema:"description=Email address of the user which must be unique"`
+	Password string `json:"password,omitempty" jsonschema:"description=Optional password of at least 8 characters to log in with"`
+}
+
+// UpdateUserDTO is used to capture the request body when
+// replacing an existing user. Nil Roles are left unchanged.
+type UpdateUserDTO struct {
 	Name  string `json:"name"`
 	Email string `json:"email"`
+	Roles []Role `json:"roles,omitempty"`
+}
+
+// PatchUserDTO is used to capture the request body when
+// partially updating an existing user. Nil fields are left unchanged.
+type PatchUserDTO struct {
+	Name  *string `json:"name,omitempty"`
+	Email *string `json:"email,omitempty"`
+	Roles *[]Role `json:"roles,omitempty"`
+}
+
+// ListUsersDTO is used to capture the query parameters when
+// listing users. Sort is a field name, optionally prefixed with
+// "-" for descending order. Name and Email are prefix filters.
+type ListUsersDTO struct {
+	Limit         int    `json:"limit,omitempty" jsonschema:"description=Maximum number of users to return (default 50 and max 200)"`
+	Cursor        string `json:"cursor,omitempty" jsonschema:"description=next_cursor from a previous page"`
+	Sort          string `json:"sort,omitempty" jsonschema:"description=One of created_at/id/name/email optionally prefixed with - for descending order"`
+	Name          string `json:"name,omitempty" jsonschema:"description=Case-insensitive name prefix filter"`
+	Email         string `json:"email,omitempty" jsonschema:"description=Email prefix filter"`
+	EmailVerified *bool  `json:"email_verified,omitempty" jsonschema:"description=Only return users whose email is (true) or is not (false) verified"`
+}
+
+// UsersPage is a single page of users. NextCursor is empty
+// when there are no further pages.
+type UsersPage struct {
+	Users      []User `json:"users"`
+	NextCursor string `json:"next_cursor,omitempty"`
+}
+
+// LoginDTO is used to capture the request body when
+// logging in with a password.
+type LoginDTO struct {
+	Email    string `json:"email"`
+	Password string `json:"password"`
+	// TOTPCode or RecoveryCode is required once two-factor
+	// authentication is enabled.
+	TOTPCode     string `json:"totp_code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// RefreshDTO is used to capture the request body when exchanging
+// a refresh token for new tokens, or revoking it on logout.
+type RefreshDTO struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+// VerifyEmailDTO is used to capture the request body when
+// verifying an email address with the token sent to it.
+type VerifyEmailDTO struct {
+	Token string `json:"token"`
+}
+
+// PasswordResetRequestDTO is used to capture the request body when
+// asking for a password reset token to be emailed.
+type PasswordResetRequestDTO struct {
+	Email string `json:"email"`
+}
+
+// PasswordResetConfirmDTO is used to capture the request body when
+// setting a new password with an emailed reset token.
+type PasswordResetConfirmDTO struct {
+	Token    string `json:"token"`
+	Password string `json:"password"`
+}
+
+// MagicLinkRequestDTO is used to capture the request body when
+// asking for a login token to be emailed.
+type MagicLinkRequestDTO struct {
+	Email string `json:"email"`
+}
+
+// MagicLinkLoginDTO is used to capture the request body when
+// logging in with an emailed login token.
+type MagicLinkLoginDTO struct {
+	Token        string `json:"token"`
+	TOTPCode     string `json:"totp_code,omitempty"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPCodeDTO is used to capture the request body when confirming
+// or disabling two-factor authentication. Disabling also accepts
+// a recovery code.
+type TOTPCodeDTO struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code,omitempty"`
+}
+
+// TOTPEnrollment is returned on enrolling in two-factor
+// authentication. URI is the otpauth:// URI authenticator apps
+// scan, and Secret is for entering by hand.
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"uri"`
+}
+
+// RecoveryCodes are returned once, on confirming two-factor
+// authentication. Each works once in place of a code.
+type RecoveryCodes struct {
+	Codes []string `json:"recovery_codes"`
+}
+
+// Tokens are issued on login and refresh. AccessToken is sent as
+// a bearer token and expires after ExpiresIn seconds. RefreshToken
+// is single use.
+type Tokens struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	TokenType    string `json:"token_type"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+// CreateAPIKeyDTO is used to capture the request body when creating
+// an API key. UserID defaults to the caller, and ExpiresAt to the
+// longest lifetime allowed.
+type CreateAPIKeyDTO struct {
+	Name      string     `json:"name"`
+	UserID    string     `json:"user_id,omitempty"`
+	Scopes    []string   `json:"scopes"`
+	ExpiresAt *time.Time `json:"expires_at,omitempty"`
+}
+
+// APIKey is an API key as shown to its owner. Prefix is the start of
+// the key, for telling keys apart; the key itself is never shown again
+// after creation.
+type APIKey struct {
+	ID         string     `json:"id"`
+	UserID     string     `json:"user_id"`
+	Name       string     `json:"name"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	ExpiresAt  time.Time  `json:"expires_at"`
+	LastUsedAt *time.Time `json:"last_used_at,omitempty"`
+	CreatedAt  time.Time  `json:"created_at"`
+}
+
+// CreatedAPIKey is returned once, on creating an API key. Key is sent
+// as a bearer token or in the X-API-Key header.
+type CreatedAPIKey struct {
+	APIKey
+	Key string `json:"key"`
+}
+
+// CreateWebhookDTO is used to capture the request body when subscribing
+// to events. Events filters which are sent, every one when empty.
+// Secret is generated when omitted.
+type CreateWebhookDTO struct {
+	URL    string   `json:"url"`
+	Secret string   `json:"secret,omitempty"`
+	Events []string `json:"events,omitempty"`
+}
+
+// Webhook is a subscription to events. Its secret is never shown
+// again after creation.
+type Webhook struct {
+	ID        string    `json:"id"`
+	URL       string    `json:"url"`
+	Events    []string  `json:"events"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// CreatedWebhook is returned once, on creating a webhook. Secret is
+// the key requests to it are signed with.
+type CreatedWebhook struct {
+	Webhook
+	Secret string `json:"secret"`
+}
+
+// WebhookDelivery is the delivery of an event to a webhook. Every
+// delivery of the same event has the same EventID, which is sent as
+// the Idempotency-Key.
+type WebhookDelivery struct {
+	ID        string           `json:"id"`
+	WebhookID string           `json:"webhook_id"`
+	EventID   string           `json:"event_id"`
+	Event     string           `json:"event"`
+	Payload   json.RawMessage  `json:"payload"`
+	Status    string           `json:"status"`
+	Attempts  []WebhookAttempt `json:"attempts"`
+	CreatedAt time.Time        `json:"created_at"`
+	UpdatedAt time.Time        `json:"updated_at"`
+}
+
+// WebhookAttempt is one request made for a delivery. StatusCode is
+// missing when no response came back.
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
 }
@@ -2,61 +2,267 @@ package demogo
 
 import (
 	"fmt"
+	"log/slog"
 	"net/http"
+	"net/url"
+	"time"
 
 	"github.com/gorilla/mux"
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpgoserver "github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	apiuser "github.com/w-h-a/demo-go/api/user"
+	userv1 "github.com/w-h-a/demo-go/api/user/v1"
+	"github.com/w-h-a/demo-go/internal/authz"
 	"github.com/w-h-a/demo-go/internal/client/notifier"
 	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
+	smtpnotifier "github.com/w-h-a/demo-go/internal/client/notifier/smtp"
 	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
 	"github.com/w-h-a/demo-go/internal/client/user_repo/postgres"
+	healthgrpchandler "github.com/w-h-a/demo-go/internal/handler/grpc/health"
+	usergrpchandler "github.com/w-h-a/demo-go/internal/handler/grpc/user"
+	apikeyhttphandler "github.com/w-h-a/demo-go/internal/handler/http/api_key"
+	authhttphandler "github.com/w-h-a/demo-go/internal/handler/http/auth"
+	healthhttphandler "github.com/w-h-a/demo-go/internal/handler/http/health"
 	userhttphandler "github.com/w-h-a/demo-go/internal/handler/http/user"
+	webhookhttphandler "github.com/w-h-a/demo-go/internal/handler/http/webhook"
+	usermcphandler "github.com/w-h-a/demo-go/internal/handler/mcp/user"
+	"github.com/w-h-a/demo-go/internal/health"
+	authgrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/auth"
+	deadlinegrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/deadline"
+	logginggrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/logging"
+	metricsgrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/metrics"
+	recoverygrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/recovery"
 	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	logginghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/logging"
+	metricshttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/metrics"
+	tracinghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/tracing"
 	"github.com/w-h-a/demo-go/internal/server"
+	grpcserver "github.com/w-h-a/demo-go/internal/server/grpc"
 	httpserver "github.com/w-h-a/demo-go/internal/server/http"
+	mcpserver "github.com/w-h-a/demo-go/internal/server/mcp"
 	"github.com/w-h-a/demo-go/internal/service/user"
+	"go.opentelemetry.io/contrib/instrumentation/google.golang.org/grpc/otelgrpc"
+	"go.opentelemetry.io/otel/trace"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
 )
 
-func InitUserRepo(loc string) (userrepo.UserRepo, error) {
-	return postgres.NewUserRepo(
-		userrepo.WithLocation(loc),
-	), nil
+func InitUserRepo(loc string, opts ...userrepo.Option) (userrepo.UserRepo, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse persister location: %w", err)
+	}
+
+	opts = append([]userrepo.Option{userrepo.WithLocation(loc)}, opts...)
+
+	switch u.Scheme {
+	case "memory":
+		return memoryuserrepo.NewUserRepo(opts...), nil
+	case "postgres", "postgresql":
+		return postgres.NewUserRepo(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported persister location scheme: %q", u.Scheme)
+	}
 }
 
-func InitNotifier() (notifier.Notifier, error) {
-	return memorynotifier.NewNotifier(), nil
+func InitMigrator(loc string, opts ...userrepo.Option) (*postgres.Migrator, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse persister location: %w", err)
+	}
+
+	switch u.Scheme {
+	case "postgres", "postgresql":
+		return postgres.NewMigrator(
+			append([]userrepo.Option{userrepo.WithLocation(loc)}, opts...)...,
+		)
+	default:
+		return nil, fmt.Errorf("migrations are not supported for persister location scheme: %q", u.Scheme)
+	}
 }
 
-func InitUserService(datalocation string) (*user.Service, error) {
-	ur, err := InitUserRepo(datalocation)
+func InitNotifier(loc string, opts ...notifier.Option) (notifier.Notifier, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse notifier location: %w", err)
+	}
+
+	opts = append([]notifier.Option{notifier.WithLocation(loc)}, opts...)
+
+	switch u.Scheme {
+	case "memory":
+		return memorynotifier.NewNotifier(opts...), nil
+	case "smtp", "smtps":
+		return smtpnotifier.NewNotifier(opts...)
+	default:
+		return nil, fmt.Errorf("unsupported notifier location scheme: %q", u.Scheme)
+	}
+}
+
+func InitAuthorizer(policyFile string) (authz.Authorizer, error) {
+	if len(policyFile) == 0 {
+		return authz.NewPolicyAuthorizer(authz.DefaultPolicies()...), nil
+	}
+
+	policies, err := authz.LoadPolicyFile(policyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load authz policies: %w", err)
+	}
+
+	return authz.NewPolicyAuthorizer(policies...), nil
+}
+
+func InitUserService(datalocation string, repoOpts []userrepo.Option, notifierLocation string, notifierOpts []notifier.Option, svcOpts ...user.Option) (*user.Service, error) {
+	ur, err := InitUserRepo(datalocation, repoOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	n, err := InitNotifier()
+	n, err := InitNotifier(notifierLocation, notifierOpts...)
 	if err != nil {
 		return nil, err
 	}
 
-	return user.New(ur, n), nil
+	return user.New(ur, n, svcOpts...), nil
 }
 
-func InitHttpServer(httpAddr string, userService *user.Service) (server.Server, error) {
+func InitHttpServer(httpAddr string, logger *slog.Logger, tp trace.TracerProvider, reg prometheus.Registerer, userService *user.Service, authOpts ...authhttpmiddleware.Option) (server.Server, error) {
+	// logging in is how callers get credentials, and the other
+	// tokens arrive by email, so none of these can require them
+	authOpts = append(authOpts, authhttpmiddleware.WithPublicRoutes(
+		"POST /api/auth/login",
+		"POST /api/auth/refresh",
+		"POST /api/auth/logout",
+		"POST /api/auth/password-reset",
+		"POST /api/auth/password-reset/confirm",
+		"POST /api/auth/magic-link",
+		"POST /api/auth/magic-link/login",
+		"POST /api/users/verify",
+	))
+
 	srv := httpserver.NewServer(
 		server.WithAddress(httpAddr),
+		server.WithLogger(logger),
 		httpserver.WithMiddleware(
-			authhttpmiddleware.New(),
+			tracinghttpmiddleware.New(tp),
+			logginghttpmiddleware.New(logger),
+			metricshttpmiddleware.New(reg),
+			authhttpmiddleware.New(authOpts...),
 		),
 	)
 
 	router := mux.NewRouter()
+	router.Use(logginghttpmiddleware.Route, tracinghttpmiddleware.Route)
+
+	authHandler := authhttphandler.New(userService)
+
+	router.HandleFunc("/api/auth/login", authHandler.Login).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/refresh", authHandler.Refresh).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/logout", authHandler.Logout).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/password-reset", authHandler.RequestPasswordReset).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/password-reset/confirm", authHandler.ConfirmPasswordReset).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/magic-link", authHandler.RequestMagicLink).Methods(http.MethodPost)
+	router.HandleFunc("/api/auth/magic-link/login", authHandler.LoginWithMagicLink).Methods(http.MethodPost)
+
+	apiKeysHandler := apikeyhttphandler.New(userService)
+
+	router.HandleFunc("/api/keys", apiKeysHandler.CreateAPIKey).Methods(http.MethodPost)
+	router.HandleFunc("/api/keys", apiKeysHandler.ListAPIKeys).Methods(http.MethodGet)
+	router.HandleFunc("/api/keys/{id}", apiKeysHandler.DeleteAPIKey).Methods(http.MethodDelete)
+
+	webhooksHandler := webhookhttphandler.New(userService)
+
+	router.HandleFunc("/api/webhooks", webhooksHandler.CreateWebhook).Methods(http.MethodPost)
+	router.HandleFunc("/api/webhooks", webhooksHandler.ListWebhooks).Methods(http.MethodGet)
+	router.HandleFunc("/api/webhooks/{id}", webhooksHandler.DeleteWebhook).Methods(http.MethodDelete)
+	router.HandleFunc("/api/webhooks/{id}/deliveries", webhooksHandler.ListDeliveries).Methods(http.MethodGet)
+	router.HandleFunc("/api/webhooks/{id}/deliveries/{delivery_id}/redeliver", webhooksHandler.Redeliver).Methods(http.MethodPost)
 
 	usersHandler := userhttphandler.New(userService)
 
 	router.HandleFunc("/api/users", usersHandler.CreateUser).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/verify", usersHandler.VerifyEmail).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/{id}/verify/resend", usersHandler.ResendVerification).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/{id}/totp", usersHandler.EnrollTOTP).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/{id}/totp/confirm", usersHandler.ConfirmTOTP).Methods(http.MethodPost)
+	router.HandleFunc("/api/users/{id}/totp", usersHandler.DisableTOTP).Methods(http.MethodDelete)
 	router.HandleFunc("/api/users/{id}", usersHandler.GetUserByID).Methods(http.MethodGet)
 	router.HandleFunc("/api/users", usersHandler.GetAllUsers).Methods(http.MethodGet)
+	router.HandleFunc("/api/users/{id}", usersHandler.UpdateUser).Methods(http.MethodPut)
+	router.HandleFunc("/api/users/{id}", usersHandler.PatchUser).Methods(http.MethodPatch)
+	router.HandleFunc("/api/users/{id}", usersHandler.DeleteUser).Methods(http.MethodDelete)
+
+	if err := srv.Handle(router); err != nil {
+		return nil, fmt.Errorf("failed to attach root handler: %w", err)
+	}
 
-	// TODO: additional routes
+	return srv, nil
+}
+
+func InitGrpcServer(grpcAddr string, logger *slog.Logger, tp trace.TracerProvider, reg prometheus.Registerer, healthRegistry *health.Registry, userService *user.Service, authenticators ...authhttpmiddleware.Authenticator) (server.Server, error) {
+	srv := grpcserver.NewServer(
+		server.WithAddress(grpcAddr),
+		server.WithLogger(logger),
+		grpcserver.WithStatsHandlers(otelgrpc.NewServerHandler(otelgrpc.WithTracerProvider(tp))),
+		// logging comes first, so panics are logged with the call's
+		// fields, and metrics after recovery, so panics count as Internal
+		grpcserver.WithUnaryInterceptors(
+			logginggrpcmiddleware.NewUnary(logger),
+			recoverygrpcmiddleware.NewUnary(),
+			metricsgrpcmiddleware.NewUnary(reg),
+			deadlinegrpcmiddleware.NewUnary(30*time.Second),
+			authgrpcmiddleware.NewUnary(authenticators...),
+		),
+		grpcserver.WithStreamInterceptors(
+			logginggrpcmiddleware.NewStream(logger),
+			recoverygrpcmiddleware.NewStream(),
+			metricsgrpcmiddleware.NewStream(reg),
+			deadlinegrpcmiddleware.NewStream(5*time.Minute),
+			authgrpcmiddleware.NewStream(authenticators...),
+		),
+	)
+
+	usersHandler := usergrpchandler.New(userService)
+
+	if err := srv.Handle(grpcserver.GrpcServiceRegistration{
+		Desc: &userv1.UserService_ServiceDesc,
+		Impl: usersHandler,
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register user service: %w", err)
+	}
+
+	if err := srv.Handle(grpcserver.GrpcServiceRegistration{
+		Desc: &healthpb.Health_ServiceDesc,
+		Impl: healthgrpchandler.New(healthRegistry),
+	}); err != nil {
+		return nil, fmt.Errorf("failed to register health service: %w", err)
+	}
+
+	return srv, nil
+}
+
+// InitAdminServer serves operational endpoints, such as /metrics and
+// the health probes, on a listener of their own, so they can be kept
+// off public networks.
+func InitAdminServer(adminAddr string, logger *slog.Logger, gatherer prometheus.Gatherer, healthRegistry *health.Registry) (server.Server, error) {
+	srv := httpserver.NewServer(
+		server.WithAddress(adminAddr),
+		server.WithLogger(logger),
+	)
+
+	router := mux.NewRouter()
+
+	router.Handle("/metrics", promhttp.HandlerFor(gatherer, promhttp.HandlerOpts{
+		ErrorLog: slog.NewLogLogger(logger.Handler(), slog.LevelError),
+	})).Methods(http.MethodGet)
+
+	healthHandler := healthhttphandler.New(healthRegistry)
+
+	router.HandleFunc("/livez", healthHandler.Live).Methods(http.MethodGet)
+	router.HandleFunc("/healthz", healthHandler.Health).Methods(http.MethodGet)
+	router.HandleFunc("/readyz", healthHandler.Ready).Methods(http.MethodGet)
 
 	if err := srv.Handle(router); err != nil {
 		return nil, fmt.Errorf("failed to attach root handler: %w", err)
@@ -64,3 +270,63 @@ func InitHttpServer(httpAddr string, userService *user.Service) (server.Server,
 
 	return srv, nil
 }
+
+func InitMcpServer(name string, version string, mcpAddr string, userService *user.Service, opts ...server.Option) (server.Server, error) {
+	srv := mcpserver.NewServer(
+		append([]server.Option{
+			server.WithAddress(mcpAddr),
+			server.WithName(name),
+			server.WithVersion(version),
+		}, opts...)...,
+	)
+
+	usersHandler := usermcphandler.New(userService)
+
+	handlers := []any{
+		mcpgoserver.ServerTool{
+			Tool: mcp.NewTool("create_user",
+				mcp.WithDescription("Create a new user."),
+				mcp.WithInputSchema[apiuser.CreateUserDTO](),
+			),
+			Handler: usersHandler.CreateUser,
+		},
+		mcpgoserver.ServerTool{
+			Tool: mcp.NewTool("get_user",
+				mcp.WithDescription("Get a single user by id."),
+				mcp.WithString("id", mcp.Required(), mcp.Description("ID of the user")),
+				mcp.WithReadOnlyHintAnnotation(true),
+			),
+			Handler: usersHandler.GetUser,
+		},
+		mcpgoserver.ServerTool{
+			Tool: mcp.NewTool("list_users",
+				mcp.WithDescription("List a page of users. Pass next_cursor back as cursor to fetch the following page."),
+				mcp.WithInputSchema[apiuser.ListUsersDTO](),
+				mcp.WithReadOnlyHintAnnotation(true),
+			),
+			Handler: usersHandler.ListUsers,
+		},
+		mcpgoserver.ServerResourceTemplate{
+			Template: mcp.NewResourceTemplate(usermcphandler.ResourceScheme+"{id}", "user",
+				mcp.WithTemplateDescription("A single user by id."),
+				mcp.WithTemplateMIMEType("application/json"),
+			),
+			Handler: usersHandler.ReadUser,
+		},
+		mcpgoserver.ServerResource{
+			Resource: mcp.NewResource(usermcphandler.ResourceScheme, "users",
+				mcp.WithResourceDescription("The first page of users."),
+				mcp.WithMIMEType("application/json"),
+			),
+			Handler: usersHandler.ReadUsers,
+		},
+	}
+
+	for _, h := range handlers {
+		if err := srv.Handle(h); err != nil {
+			return nil, fmt.Errorf("failed to attach mcp handler: %w", err)
+		}
+	}
+
+	return srv, nil
+}
@@ -0,0 +1,64 @@
+package authz
+
+import (
+	"context"
+	"errors"
+
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+// Action is something a principal may be allowed to do.
+type Action string
+
+const (
+	ActionCreateUser  Action = "users:create"
+	ActionReadUser    Action = "users:read"
+	ActionListUsers   Action = "users:list"
+	ActionUpdateUser  Action = "users:update"
+	ActionDeleteUser  Action = "users:delete"
+	ActionAssignRoles Action = "users:assign_roles"
+	// ActionManageAPIKeys covers creating, listing and deleting
+	// the API keys of a user.
+	ActionManageAPIKeys Action = "api_keys:manage"
+	// ActionManageWebhooks covers subscribing to events, which
+	// carry every user's details, and inspecting deliveries.
+	ActionManageWebhooks Action = "webhooks:manage"
+)
+
+var actions = map[Action]bool{
+	ActionCreateUser:  true,
+	ActionReadUser:    true,
+	ActionListUsers:   true,
+	ActionUpdateUser:  true,
+	ActionDeleteUser:  true,
+	ActionAssignRoles: true,
+
+	ActionManageAPIKeys:  true,
+	ActionManageWebhooks: true,
+}
+
+// Valid reports whether a is a known action
+func (a Action) Valid() bool {
+	return actions[a]
+}
+
+var ErrForbidden = errors.New("forbidden")
+
+// Authorizer decides whether principal may perform action on the
+// user with ownerID, which is empty for actions on no particular user.
+// A zero principal is an anonymous caller.
+type Authorizer interface {
+	Authorize(ctx context.Context, principal user.User, action Action, ownerID string) error
+}
+
+type allowAll struct{}
+
+func (allowAll) Authorize(ctx context.Context, principal user.User, action Action, ownerID string) error {
+	return nil
+}
+
+// AllowAll permits everything, for callers that are trusted
+// by construction such as the stdio transport.
+func AllowAll() Authorizer {
+	return allowAll{}
+}
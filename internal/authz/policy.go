@@ -0,0 +1,99 @@
+package authz
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+// Anyone matches every principal, including anonymous
+// callers on public routes.
+const Anyone user.Role = "*"
+
+// Policy grants an action to the principals holding any of Roles.
+type Policy struct {
+	Action Action      `json:"action"`
+	Roles  []user.Role `json:"roles"`
+}
+
+// DefaultPolicies lets admins do everything, operators read
+// and create users, and everyone read and update themselves
+// and manage their own API keys.
+func DefaultPolicies() []Policy {
+	return []Policy{
+		{Action: ActionCreateUser, Roles: []user.Role{user.RoleAdmin, user.RoleOperator}},
+		{Action: ActionReadUser, Roles: []user.Role{user.RoleAdmin, user.RoleOperator, user.RoleSelf}},
+		{Action: ActionListUsers, Roles: []user.Role{user.RoleAdmin}},
+		{Action: ActionUpdateUser, Roles: []user.Role{user.RoleAdmin, user.RoleSelf}},
+		{Action: ActionDeleteUser, Roles: []user.Role{user.RoleAdmin}},
+		{Action: ActionAssignRoles, Roles: []user.Role{user.RoleAdmin}},
+		{Action: ActionManageAPIKeys, Roles: []user.Role{user.RoleAdmin, user.RoleSelf}},
+		{Action: ActionManageWebhooks, Roles: []user.Role{user.RoleAdmin}},
+	}
+}
+
+// LoadPolicyFile reads policies from a JSON file of the form
+// {"policies": [{"action": "users:list", "roles": ["admin"]}]}.
+// Actions it doesn't mention are denied to everyone.
+func LoadPolicyFile(path string) ([]Policy, error) {
+	bs, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Policies []Policy `json:"policies"`
+	}
+
+	if err := json.Unmarshal(bs, &file); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+
+	for _, p := range file.Policies {
+		if !p.Action.Valid() {
+			return nil, fmt.Errorf("%s: unknown action %q", path, p.Action)
+		}
+	}
+
+	return file.Policies, nil
+}
+
+type policyAuthorizer struct {
+	grants map[Action][]user.Role
+}
+
+func (a *policyAuthorizer) Authorize(ctx context.Context, principal user.User, action Action, ownerID string) error {
+	for _, role := range a.grants[action] {
+		switch role {
+		case Anyone:
+			return nil
+		case user.RoleSelf:
+			if len(principal.ID) > 0 && principal.ID == ownerID {
+				return nil
+			}
+		default:
+			if slices.Contains(principal.Roles, role) {
+				return nil
+			}
+		}
+	}
+
+	return fmt.Errorf("%w: %s", ErrForbidden, action)
+}
+
+// NewPolicyAuthorizer allows an action when any policy for it matches.
+func NewPolicyAuthorizer(policies ...Policy) Authorizer {
+	a := &policyAuthorizer{
+		grants: map[Action][]user.Role{},
+	}
+
+	for _, p := range policies {
+		a.grants[p.Action] = append(a.grants[p.Action], p.Roles...)
+	}
+
+	return a
+}
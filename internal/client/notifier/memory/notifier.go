@@ -2,19 +2,53 @@ package memory
 
 import (
 	"context"
-	"log"
 
 	"github.com/w-h-a/demo-go/internal/client/notifier"
 )
 
-type memoryNotifier struct{}
+type memoryNotifier struct {
+	options notifier.Options
+}
 
 func (n *memoryNotifier) Notify(ctx context.Context, id string, dest string, opts ...notifier.NotifyOption) error {
-	log.Printf("[MemoryNotifier] Sending welcome email for %s at %s\n", id, dest)
+	options := notifier.NewNotifyOptions(opts...)
+	logger := n.options.Logger.With("notifier", "memory")
+
+	if len(options.Event) > 0 {
+		logger.InfoContext(ctx, "sending event", "event", options.Event, "idempotency_key", options.IdempotencyKey)
+		return nil
+	}
+
+	var what string
+
+	switch options.Kind {
+	case notifier.KindVerifyEmail:
+		what = "verification"
+	case notifier.KindPasswordReset:
+		what = "password reset"
+	case notifier.KindMagicLink:
+		what = "login link"
+	default:
+		what = "welcome"
+	}
+
+	logger.InfoContext(ctx, "sending "+what+" email", "id", id, "to", dest)
+
+	if len(options.Token) > 0 {
+		logger.InfoContext(ctx, "email token", "to", dest, "token", options.Token)
+	}
+
+	return nil
+}
+
+func (n *memoryNotifier) Check(ctx context.Context) error {
+	return nil
+}
 
+func (n *memoryNotifier) Close(ctx context.Context) error {
 	return nil
 }
 
 func NewNotifier(opts ...notifier.Option) notifier.Notifier {
-	return &memoryNotifier{}
+	return &memoryNotifier{options: notifier.NewOptions(opts...)}
 }
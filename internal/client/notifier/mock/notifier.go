@@ -16,6 +16,16 @@ func (m *mockNotifier) Notify(ctx context.Context, id string, dest string, opts
 	return args.Error(0)
 }
 
+func (m *mockNotifier) Check(ctx context.Context) error {
+	args := m.Called(testmock.Anything)
+	return args.Error(0)
+}
+
+func (m *mockNotifier) Close(ctx context.Context) error {
+	args := m.Called(testmock.Anything)
+	return args.Error(0)
+}
+
 func NewNotifier(opts ...notifier.Option) *mockNotifier {
 	return &mockNotifier{&testmock.Mock{}}
 }
@@ -4,4 +4,8 @@ import "context"
 
 type Notifier interface {
 	Notify(ctx context.Context, id string, dest string, opts ...NotifyOption) error
+	// Check returns why nothing could be sent, or nil if it could.
+	Check(ctx context.Context) error
+	// Close releases the notifier's resources, giving up when ctx is done.
+	Close(ctx context.Context) error
 }
@@ -1,15 +1,34 @@
 package notifier
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 type Option func(*Options)
 
 type Options struct {
-	Context context.Context
+	Location string
+	Logger   *slog.Logger
+	Context  context.Context
+}
+
+func WithLocation(loc string) Option {
+	return func(o *Options) {
+		o.Location = loc
+	}
+}
+
+// WithLogger sets the logger the notifier reports with.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
 }
 
 func NewOptions(opts ...Option) Options {
 	options := Options{
+		Logger:  slog.Default(),
 		Context: context.Background(),
 	}
 
@@ -20,14 +39,101 @@ func NewOptions(opts ...Option) Options {
 	return options
 }
 
+// Kind is the kind of message a notification sends.
+type Kind string
+
+const (
+	KindWelcome       Kind = "welcome"
+	KindVerifyEmail   Kind = "verify_email"
+	KindPasswordReset Kind = "password_reset"
+	KindMagicLink     Kind = "magic_link"
+)
+
+// Kinds returns every kind of message, for notifiers that prepare
+// something per kind.
+func Kinds() []Kind {
+	return []Kind{KindWelcome, KindVerifyEmail, KindPasswordReset, KindMagicLink}
+}
+
 type NotifyOption func(*NotifyOptions)
 
 type NotifyOptions struct {
-	Context context.Context
+	Kind  Kind
+	Token string
+	// Event, Payload and IdempotencyKey describe an event, for
+	// notifiers that tell other systems rather than people
+	Event          string
+	Payload        any
+	IdempotencyKey string
+	// Endpoint and Secret, when set, are where to send this
+	// notification and the key to sign it with, in place of
+	// the notifier's configured destinations
+	Endpoint string
+	Secret   string
+	// OnAttempt is told the outcome of each request made to send
+	// the notification, with a status code of 0 when no response
+	// came back. Requests to several destinations may be made at once.
+	OnAttempt func(statusCode int, err error)
+	Context   context.Context
+}
+
+// WithKind sets the kind of message to send. Defaults to KindWelcome.
+func WithKind(k Kind) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.Kind = k
+	}
+}
+
+// WithToken sets a token the recipient acts on, such as an
+// email verification token.
+func WithToken(token string) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.Token = token
+	}
+}
+
+// WithEvent sets the type of event being notified, such as "user.created".
+func WithEvent(event string) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.Event = event
+	}
+}
+
+// WithPayload sets the data of an event. It must marshal to JSON.
+func WithPayload(payload any) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.Payload = payload
+	}
+}
+
+// WithIdempotencyKey sets a key that is the same each time the same
+// event is notified, so receivers can ignore repeats.
+func WithIdempotencyKey(key string) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.IdempotencyKey = key
+	}
+}
+
+// WithEndpoint sends the notification to url, signed with secret,
+// rather than to the notifier's configured destinations.
+func WithEndpoint(url string, secret string) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.Endpoint = url
+		o.Secret = secret
+	}
+}
+
+// WithOnAttempt sets a func told about each request made to send
+// the notification, by notifiers that make requests.
+func WithOnAttempt(fn func(statusCode int, err error)) NotifyOption {
+	return func(o *NotifyOptions) {
+		o.OnAttempt = fn
+	}
 }
 
 func NewNotifyOptions(opts ...NotifyOption) NotifyOptions {
 	options := NotifyOptions{
+		Kind:    KindWelcome,
 		Context: context.Background(),
 	}
 
@@ -0,0 +1,70 @@
+package smtp
+
+import (
+	"context"
+	"crypto/tls"
+
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+)
+
+type fromKey struct{}
+
+// WithFrom sets the address mail is sent from, such as
+// "Demo <no-reply@example.com>".
+func WithFrom(from string) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, fromKey{}, from)
+	}
+}
+
+func getFromFromCtx(ctx context.Context) (string, bool) {
+	from, ok := ctx.Value(fromKey{}).(string)
+	return from, ok
+}
+
+type templatesDirKey struct{}
+
+// WithTemplatesDir reads templates from dir in preference to the
+// built-in ones. Each kind of message has a <kind>.subject.txt,
+// <kind>.txt and <kind>.html, and dir needn't have all of them.
+func WithTemplatesDir(dir string) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, templatesDirKey{}, dir)
+	}
+}
+
+func getTemplatesDirFromCtx(ctx context.Context) (string, bool) {
+	dir, ok := ctx.Value(templatesDirKey{}).(string)
+	return dir, ok
+}
+
+type tlsConfigKey struct{}
+
+// WithTLSConfig sets the TLS config for STARTTLS and implicit TLS.
+// ServerName defaults to the host of the location.
+func WithTLSConfig(cfg *tls.Config) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, tlsConfigKey{}, cfg)
+	}
+}
+
+func getTLSConfigFromCtx(ctx context.Context) (*tls.Config, bool) {
+	cfg, ok := ctx.Value(tlsConfigKey{}).(*tls.Config)
+	return cfg, ok
+}
+
+type insecureKey struct{}
+
+// WithInsecure lets smtp:// locations send in the clear when the server
+// doesn't offer STARTTLS, such as a local mail catcher. Otherwise such
+// servers are refused, as emails carry login and reset tokens.
+func WithInsecure(insecure bool) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, insecureKey{}, insecure)
+	}
+}
+
+func getInsecureFromCtx(ctx context.Context) (bool, bool) {
+	insecure, ok := ctx.Value(insecureKey{}).(bool)
+	return insecure, ok
+}
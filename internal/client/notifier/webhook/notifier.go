@@ -0,0 +1,253 @@
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+)
+
+const (
+	defaultTimeout  = 10 * time.Second
+	defaultAttempts = 3
+	defaultBackoff  = 500 * time.Millisecond
+)
+
+// Event is the JSON body of each request
+type Event struct {
+	// ID is the idempotency key the event was notified with
+	ID        string          `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+// statusError is a response that wasn't a 2xx
+type statusError struct {
+	code int
+}
+
+func (e statusError) Error() string {
+	return fmt.Sprintf("webhook responded %d", e.code)
+}
+
+// retryable reports whether the endpoint might accept the request later
+func (e statusError) retryable() bool {
+	return e.code == http.StatusTooManyRequests || e.code >= 500
+}
+
+// webhookNotifier posts events to every endpoint, or to the one given
+// with notifier.WithEndpoint. The id and dest given to Notify are
+// ignored, as events say who they're about.
+type webhookNotifier struct {
+	options   notifier.Options
+	endpoints []Endpoint
+	// timeout bounds attempts at endpoints given per notification
+	timeout time.Duration
+	retry   retry
+	client  *http.Client
+}
+
+func (n *webhookNotifier) Notify(ctx context.Context, id string, dest string, opts ...notifier.NotifyOption) error {
+	options := notifier.NewNotifyOptions(opts...)
+
+	if len(options.Event) == 0 {
+		return errors.New("webhook notifications need an event")
+	}
+
+	data, err := json.Marshal(options.Payload)
+	if err != nil {
+		return fmt.Errorf("failed to marshal %s payload: %w", options.Event, err)
+	}
+
+	event := Event{
+		ID:        options.IdempotencyKey,
+		Type:      options.Event,
+		CreatedAt: time.Now().UTC(),
+		Data:      data,
+	}
+
+	if len(event.ID) == 0 {
+		event.ID = uuid.NewString()
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+
+	endpoints := n.endpoints
+
+	if len(options.Endpoint) > 0 {
+		ep := Endpoint{URL: options.Endpoint, Secret: options.Secret, Timeout: n.timeout}
+		if err := validate(ep); err != nil {
+			return err
+		}
+		endpoints = []Endpoint{ep}
+	}
+
+	if len(endpoints) == 0 {
+		return errors.New("webhook notifier has no endpoint to post to")
+	}
+
+	onAttempt := options.OnAttempt
+	if onAttempt == nil {
+		onAttempt = func(int, error) {}
+	}
+
+	errs := make([]error, len(endpoints))
+
+	var wg sync.WaitGroup
+
+	for i, ep := range endpoints {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.deliver(ctx, ep, event, body, onAttempt); err != nil {
+				errs[i] = fmt.Errorf("%s: %w", ep.URL, err)
+			}
+		}()
+	}
+
+	wg.Wait()
+
+	return errors.Join(errs...)
+}
+
+// deliver posts body to ep until it is accepted, a failure can't be
+// retried, attempts run out or ctx is done
+func (n *webhookNotifier) deliver(ctx context.Context, ep Endpoint, event Event, body []byte, onAttempt func(int, error)) error {
+	backoff := n.retry.backoff
+
+	for attempt := 1; ; attempt++ {
+		code, err := n.post(ctx, ep, event, body)
+		onAttempt(code, err)
+
+		var status statusError
+		if err == nil || (errors.As(err, &status) && !status.retryable()) || attempt >= n.retry.attempts {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return errors.Join(err, ctx.Err())
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// post makes one signed request to ep, returning the status code
+// of the response if there was one
+func (n *webhookNotifier) post(ctx context.Context, ep Endpoint, event Event, body []byte) (int, error) {
+	ctx, cancel := context.WithTimeout(ctx, ep.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, ep.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+
+	// signed per attempt, so retries aren't refused as replays
+	now := time.Now()
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set(IDHeader, event.ID)
+	req.Header.Set(EventHeader, event.Type)
+	req.Header.Set(TimestampHeader, fmt.Sprint(now.Unix()))
+	req.Header.Set(SignatureHeader, Sign(ep.Secret, now, body))
+
+	rsp, err := n.client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer rsp.Body.Close()
+
+	// drain so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(rsp.Body, 64<<10))
+
+	if rsp.StatusCode < 200 || rsp.StatusCode > 299 {
+		return rsp.StatusCode, statusError{code: rsp.StatusCode}
+	}
+
+	return rsp.StatusCode, nil
+}
+
+// Check never fails, as endpoints are many and each delivery
+// records its own failures
+func (n *webhookNotifier) Check(ctx context.Context) error {
+	return nil
+}
+
+// Close drops idle keep-alive connections. Posts in flight are left to
+// their own timeouts.
+func (n *webhookNotifier) Close(ctx context.Context) error {
+	n.client.CloseIdleConnections()
+	return nil
+}
+
+// validate checks ep can be posted to
+func validate(ep Endpoint) error {
+	u, err := url.Parse(ep.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return fmt.Errorf("invalid webhook url %q", ep.URL)
+	}
+
+	if len(ep.Secret) == 0 {
+		return fmt.Errorf("webhook %s needs a secret", ep.URL)
+	}
+
+	return nil
+}
+
+// NewNotifier creates a notifier posting events to the endpoints added
+// with WithEndpoint. Without any, each notification must be given one.
+func NewNotifier(opts ...notifier.Option) (notifier.Notifier, error) {
+	options := notifier.NewOptions(opts...)
+
+	n := &webhookNotifier{
+		options: options,
+		timeout: defaultTimeout,
+		retry:   retry{attempts: defaultAttempts, backoff: defaultBackoff},
+		client:  &http.Client{},
+	}
+
+	if timeout, ok := getTimeoutFromCtx(options.Context); ok && timeout > 0 {
+		n.timeout = timeout
+	}
+
+	endpoints, _ := getEndpointsFromCtx(options.Context)
+
+	for _, ep := range endpoints {
+		if err := validate(ep); err != nil {
+			return nil, err
+		}
+
+		if ep.Timeout <= 0 {
+			ep.Timeout = defaultTimeout
+		}
+
+		n.endpoints = append(n.endpoints, ep)
+	}
+
+	if r, ok := getRetryFromCtx(options.Context); ok {
+		if r.attempts > 0 {
+			n.retry.attempts = r.attempts
+		}
+		if r.backoff > 0 {
+			n.retry.backoff = r.backoff
+		}
+	}
+
+	return n, nil
+}
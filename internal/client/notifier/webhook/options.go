@@ -0,0 +1,70 @@
+package webhook
+
+import (
+	"context"
+	"slices"
+	"time"
+
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+)
+
+// Endpoint is a URL events are posted to
+type Endpoint struct {
+	URL string
+	// Secret is the key requests to the URL are signed with
+	Secret string
+	// Timeout bounds each attempt. Defaults to 10s.
+	Timeout time.Duration
+}
+
+type endpointsKey struct{}
+
+// WithEndpoint adds an endpoint every event is posted to.
+func WithEndpoint(url string, secret string, timeout time.Duration) notifier.Option {
+	return func(o *notifier.Options) {
+		endpoints, _ := getEndpointsFromCtx(o.Context)
+		endpoints = append(slices.Clone(endpoints), Endpoint{URL: url, Secret: secret, Timeout: timeout})
+		o.Context = context.WithValue(o.Context, endpointsKey{}, endpoints)
+	}
+}
+
+func getEndpointsFromCtx(ctx context.Context) ([]Endpoint, bool) {
+	endpoints, ok := ctx.Value(endpointsKey{}).([]Endpoint)
+	return endpoints, ok
+}
+
+type retryKey struct{}
+
+type retry struct {
+	attempts int
+	backoff  time.Duration
+}
+
+// WithRetry sets how many times a request to an endpoint is tried,
+// waiting backoff after the first failure and doubling the wait after
+// each further one. Defaults to 3 attempts and 500ms.
+func WithRetry(attempts int, backoff time.Duration) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, retryKey{}, retry{attempts: attempts, backoff: backoff})
+	}
+}
+
+func getRetryFromCtx(ctx context.Context) (retry, bool) {
+	r, ok := ctx.Value(retryKey{}).(retry)
+	return r, ok
+}
+
+type timeoutKey struct{}
+
+// WithTimeout bounds each attempt at endpoints given with
+// notifier.WithEndpoint. Defaults to 10s.
+func WithTimeout(timeout time.Duration) notifier.Option {
+	return func(o *notifier.Options) {
+		o.Context = context.WithValue(o.Context, timeoutKey{}, timeout)
+	}
+}
+
+func getTimeoutFromCtx(ctx context.Context) (time.Duration, bool) {
+	timeout, ok := ctx.Value(timeoutKey{}).(time.Duration)
+	return timeout, ok
+}
@@ -0,0 +1,23 @@
+package userrepo
+
+import "time"
+
+// APIKey lets a service act as the user owning it, limited to Scopes.
+// Only a hash of the key is stored. Prefix is its first few characters,
+// kept so owners can tell their keys apart.
+type APIKey struct {
+	ID         string
+	UserID     string
+	Name       string
+	Prefix     string
+	KeyHash    string
+	Scopes     []string
+	ExpiresAt  time.Time
+	LastUsedAt *time.Time
+	CreatedAt  time.Time
+}
+
+// Active reports whether the key can still be used at now
+func (k APIKey) Active(now time.Time) bool {
+	return now.Before(k.ExpiresAt)
+}
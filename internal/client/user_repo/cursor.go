@@ -0,0 +1,77 @@
+package userrepo
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"time"
+
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// Cursor marks the last user of a previous page for keyset pagination.
+// It records the sort it was produced under so it cannot be replayed
+// against a different ordering.
+type Cursor struct {
+	Field SortField `json:"f"`
+	Desc  bool      `json:"d"`
+	Value string    `json:"v"`
+	ID    string    `json:"id"`
+}
+
+// Encode returns the opaque string form of the cursor.
+func (c Cursor) Encode() string {
+	bs, _ := json.Marshal(c)
+	return base64.RawURLEncoding.EncodeToString(bs)
+}
+
+// CursorAfter builds the cursor that continues a listing after u.
+func CursorAfter(u user.User, field SortField, desc bool) Cursor {
+	return Cursor{
+		Field: field,
+		Desc:  desc,
+		Value: SortValue(u, field),
+		ID:    u.ID,
+	}
+}
+
+// DecodeCursor parses a cursor produced by Encode.
+func DecodeCursor(s string) (Cursor, error) {
+	bs, err := base64.RawURLEncoding.DecodeString(s)
+	if err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	var c Cursor
+	if err := json.Unmarshal(bs, &c); err != nil {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if !c.Field.Valid() || c.ID == "" {
+		return Cursor{}, ErrInvalidCursor
+	}
+
+	if c.Field == SortByCreatedAt {
+		if _, err := time.Parse(time.RFC3339Nano, c.Value); err != nil {
+			return Cursor{}, ErrInvalidCursor
+		}
+	}
+
+	return c, nil
+}
+
+// SortValue returns the string form of the field u is sorted by.
+func SortValue(u user.User, field SortField) string {
+	switch field {
+	case SortByID:
+		return u.ID
+	case SortByName:
+		return u.Name
+	case SortByEmail:
+		return u.Email
+	default:
+		return u.CreatedAt.UTC().Format(time.RFC3339Nano)
+	}
+}
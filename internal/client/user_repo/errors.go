@@ -4,4 +4,21 @@ import "errors"
 
 var (
 	ErrUserNotFound = errors.New("user not found")
+	ErrEmailInUse   = errors.New("email already in use")
+	// ErrCredentialsNotFound means the user has no password
+	ErrCredentialsNotFound = errors.New("credentials not found")
+	ErrSessionNotFound     = errors.New("session not found")
+	// ErrVerificationTokenNotFound also covers tokens sent to an
+	// address the user no longer has
+	ErrVerificationTokenNotFound = errors.New("verification token not found")
+	ErrVerificationTokenExpired  = errors.New("verification token expired")
+	ErrTokenNotFound             = errors.New("token not found")
+	ErrTOTPNotFound              = errors.New("totp not found")
+	// ErrTOTPCodeUsed means a code at or after the counter was already accepted
+	ErrTOTPCodeUsed            = errors.New("totp code already used")
+	ErrRecoveryCodeNotFound    = errors.New("recovery code not found")
+	ErrAPIKeyNotFound          = errors.New("api key not found")
+	ErrOutboxMessageNotFound   = errors.New("outbox message not found")
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
 )
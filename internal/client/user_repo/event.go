@@ -0,0 +1,23 @@
+package userrepo
+
+import "github.com/w-h-a/demo-go/api/user"
+
+// Event announces a change to a user, as messages for the outbox and
+// deliveries to the webhooks subscribed to it.
+type Event struct {
+	Messages   []OutboxMessage
+	Deliveries []EventDelivery
+}
+
+// EventDelivery is a delivery to a webhook, with the message that
+// drives it. Deliveries to webhooks removed in the meantime are
+// dropped along with their message.
+type EventDelivery struct {
+	Delivery WebhookDelivery
+	Message  OutboxMessage
+}
+
+// EventFunc builds the event announcing a change from the user as it
+// is after the change, or as it was before being deleted. It runs
+// with the change, and an error aborts it.
+type EventFunc func(u user.User) (Event, error)
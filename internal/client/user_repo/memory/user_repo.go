@@ -0,0 +1,1096 @@
+package memory
+
+import (
+	"context"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+// memoryUserRepo is an in-process implementation of UserRepo.
+// It is safe for concurrent use.
+type memoryUserRepo struct {
+	options     userrepo.Options
+	users       map[string]user.User
+	emails      map[string]string
+	credentials map[string]string
+	sessions    map[string]userrepo.Session
+	// verifications are keyed by user ID
+	verifications map[string]userrepo.VerificationToken
+	// oneTimeTokens are keyed by token hash
+	oneTimeTokens map[string]userrepo.OneTimeToken
+	totps         map[string]userrepo.TOTP
+	// recoveryCodes maps user IDs to code hashes to whether they are used
+	recoveryCodes map[string]map[string]bool
+	apiKeys       map[string]userrepo.APIKey
+	outbox        map[string]userrepo.OutboxMessage
+	webhooks      map[string]userrepo.Webhook
+	deliveries    map[string]userrepo.WebhookDelivery
+	mtx           sync.RWMutex
+}
+
+// Create stores a new user, enforcing unique emails
+func (ur *memoryUserRepo) Create(ctx context.Context, dto user.CreateUserDTO, opts ...userrepo.CreateOption) (user.User, error) {
+	options := userrepo.NewCreateOptions(opts...)
+
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.emails[dto.Email]; ok {
+		return user.User{}, userrepo.ErrEmailInUse
+	}
+
+	u := user.User{
+		ID:        uuid.NewString(),
+		Name:      dto.Name,
+		Email:     dto.Email,
+		CreatedAt: time.Now().UTC(),
+	}
+
+	events, err := buildEvents(options.Events, u)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	ur.users[u.ID] = u
+	ur.emails[u.Email] = u.ID
+
+	if options.PasswordHash != "" {
+		ur.credentials[u.ID] = options.PasswordHash
+	}
+
+	if t := options.VerificationToken; t != nil {
+		t.UserID, t.Email, t.CreatedAt = u.ID, u.Email, u.CreatedAt
+		ur.verifications[u.ID] = *t
+	}
+
+	for _, m := range options.Outbox {
+		ur.enqueue(m)
+	}
+
+	ur.storeEvents(events)
+
+	return u, nil
+}
+
+// GetByID retrieves a user given their ID.
+func (ur *memoryUserRepo) GetByID(ctx context.Context, id string) (user.User, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	u, ok := ur.users[id]
+	if !ok {
+		return user.User{}, userrepo.ErrUserNotFound
+	}
+
+	return u, nil
+}
+
+// GetByEmail retrieves a user given their email.
+func (ur *memoryUserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	id, ok := ur.emails[email]
+	if !ok {
+		return user.User{}, userrepo.ErrUserNotFound
+	}
+
+	return ur.users[id], nil
+}
+
+// GetAll retrieves all users that satisfy GetAllOptions
+func (ur *memoryUserRepo) GetAll(ctx context.Context, opts ...userrepo.GetAllOption) ([]user.User, error) {
+	options := userrepo.NewGetAllOptions(opts...)
+
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	us := []user.User{}
+
+	for _, u := range ur.users {
+		if options.NamePrefix != "" && !strings.HasPrefix(strings.ToLower(u.Name), strings.ToLower(options.NamePrefix)) {
+			continue
+		}
+		if options.EmailPrefix != "" && !strings.HasPrefix(u.Email, options.EmailPrefix) {
+			continue
+		}
+		if options.EmailVerified != nil && u.EmailVerified != *options.EmailVerified {
+			continue
+		}
+		if options.Cursor != nil && !after(u, *options.Cursor) {
+			continue
+		}
+		us = append(us, u)
+	}
+
+	sort.Slice(us, func(i, j int) bool {
+		c := compare(us[i], us[j], options.SortField)
+		if options.SortDesc {
+			return c > 0
+		}
+		return c < 0
+	})
+
+	if options.Limit > 0 && len(us) > options.Limit {
+		us = us[:options.Limit]
+	}
+
+	return us, nil
+}
+
+// Update replaces the name, email and, when set, roles of an existing user, enforcing unique emails
+func (ur *memoryUserRepo) Update(ctx context.Context, id string, dto user.UpdateUserDTO, opts ...userrepo.ChangeOption) (user.User, error) {
+	options := userrepo.NewChangeOptions(opts...)
+
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	existing, ok := ur.users[id]
+	if !ok {
+		return user.User{}, userrepo.ErrUserNotFound
+	}
+
+	if ownerID, ok := ur.emails[dto.Email]; ok && ownerID != id {
+		return user.User{}, userrepo.ErrEmailInUse
+	}
+
+	u := existing
+	u.Name = dto.Name
+	u.Email = dto.Email
+	// a new address has to be verified again
+	u.EmailVerified = existing.EmailVerified && u.Email == existing.Email
+
+	if dto.Roles != nil {
+		u.Roles = slices.Clone(dto.Roles)
+	}
+
+	events, err := buildEvents(options.Events, u)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	delete(ur.emails, existing.Email)
+	ur.users[id] = u
+	ur.emails[u.Email] = id
+
+	ur.storeEvents(events)
+
+	return u, nil
+}
+
+// Delete removes a user given their ID.
+func (ur *memoryUserRepo) Delete(ctx context.Context, id string, opts ...userrepo.ChangeOption) error {
+	options := userrepo.NewChangeOptions(opts...)
+
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	existing, ok := ur.users[id]
+	if !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	events, err := buildEvents(options.Events, existing)
+	if err != nil {
+		return err
+	}
+
+	delete(ur.users, id)
+	delete(ur.emails, existing.Email)
+	delete(ur.credentials, id)
+	delete(ur.verifications, id)
+	delete(ur.totps, id)
+	delete(ur.recoveryCodes, id)
+
+	for kid, key := range ur.apiKeys {
+		if key.UserID == id {
+			delete(ur.apiKeys, kid)
+		}
+	}
+
+	for hash, token := range ur.oneTimeTokens {
+		if token.UserID == id {
+			delete(ur.oneTimeTokens, hash)
+		}
+	}
+
+	for sid, session := range ur.sessions {
+		if session.UserID == id {
+			delete(ur.sessions, sid)
+		}
+	}
+
+	ur.storeEvents(events)
+
+	return nil
+}
+
+// GetPasswordHash retrieves the password hash of a user
+func (ur *memoryUserRepo) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	hash, ok := ur.credentials[userID]
+	if !ok {
+		return "", userrepo.ErrCredentialsNotFound
+	}
+
+	return hash, nil
+}
+
+// CreateSession stores a new session
+func (ur *memoryUserRepo) CreateSession(ctx context.Context, session userrepo.Session) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[session.UserID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	session.CreatedAt = time.Now().UTC()
+	ur.sessions[session.ID] = session
+
+	return nil
+}
+
+// GetSession retrieves a session given its ID
+func (ur *memoryUserRepo) GetSession(ctx context.Context, id string) (userrepo.Session, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	session, ok := ur.sessions[id]
+	if !ok {
+		return userrepo.Session{}, userrepo.ErrSessionNotFound
+	}
+
+	return session, nil
+}
+
+// GetSessionByRefreshTokenHash retrieves a session given its refresh token hash
+func (ur *memoryUserRepo) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (userrepo.Session, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	for _, session := range ur.sessions {
+		if session.RefreshTokenHash == hash {
+			return session, nil
+		}
+	}
+
+	return userrepo.Session{}, userrepo.ErrSessionNotFound
+}
+
+// RotateSession swaps the refresh token hash of a live session
+func (ur *memoryUserRepo) RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	session, ok := ur.sessions[id]
+	if !ok || session.RefreshTokenHash != oldHash || session.RevokedAt != nil {
+		return userrepo.ErrSessionNotFound
+	}
+
+	session.RefreshTokenHash = newHash
+	session.ExpiresAt = expiresAt
+	ur.sessions[id] = session
+
+	return nil
+}
+
+// RevokeSession marks a session revoked
+func (ur *memoryUserRepo) RevokeSession(ctx context.Context, id string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	session, ok := ur.sessions[id]
+	if !ok {
+		return userrepo.ErrSessionNotFound
+	}
+
+	if session.RevokedAt == nil {
+		now := time.Now().UTC()
+		session.RevokedAt = &now
+		ur.sessions[id] = session
+	}
+
+	return nil
+}
+
+// RevokeUserSessions marks every session of a user revoked
+func (ur *memoryUserRepo) RevokeUserSessions(ctx context.Context, userID string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	now := time.Now().UTC()
+
+	for id, session := range ur.sessions {
+		if session.UserID == userID && session.RevokedAt == nil {
+			session.RevokedAt = &now
+			ur.sessions[id] = session
+		}
+	}
+
+	return nil
+}
+
+// SetPasswordHash creates or replaces the credentials of a user
+func (ur *memoryUserRepo) SetPasswordHash(ctx context.Context, userID string, hash string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[userID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	ur.credentials[userID] = hash
+
+	return nil
+}
+
+// SaveVerificationToken replaces the verification token of a user
+func (ur *memoryUserRepo) SaveVerificationToken(ctx context.Context, token userrepo.VerificationToken) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[token.UserID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	token.CreatedAt = time.Now().UTC()
+	ur.verifications[token.UserID] = token
+
+	return nil
+}
+
+// GetVerificationToken retrieves the verification token of a user
+func (ur *memoryUserRepo) GetVerificationToken(ctx context.Context, userID string) (userrepo.VerificationToken, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	token, ok := ur.verifications[userID]
+	if !ok {
+		return userrepo.VerificationToken{}, userrepo.ErrVerificationTokenNotFound
+	}
+
+	return token, nil
+}
+
+// VerifyEmail consumes a verification token and marks the address it was sent to verified
+func (ur *memoryUserRepo) VerifyEmail(ctx context.Context, tokenHash string, now time.Time, opts ...userrepo.ChangeOption) (user.User, error) {
+	options := userrepo.NewChangeOptions(opts...)
+
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	for userID, token := range ur.verifications {
+		if token.TokenHash != tokenHash {
+			continue
+		}
+
+		if !now.Before(token.ExpiresAt) {
+			delete(ur.verifications, userID)
+			return user.User{}, userrepo.ErrVerificationTokenExpired
+		}
+
+		u, ok := ur.users[userID]
+		if !ok || u.Email != token.Email {
+			delete(ur.verifications, userID)
+			return user.User{}, userrepo.ErrVerificationTokenNotFound
+		}
+
+		u.EmailVerified = true
+
+		// the token is kept when the change is aborted
+		events, err := buildEvents(options.Events, u)
+		if err != nil {
+			return user.User{}, err
+		}
+
+		delete(ur.verifications, userID)
+		ur.users[userID] = u
+
+		ur.storeEvents(events)
+
+		return u, nil
+	}
+
+	return user.User{}, userrepo.ErrVerificationTokenNotFound
+}
+
+// SaveOneTimeToken replaces the token of a user for the token's purpose
+func (ur *memoryUserRepo) SaveOneTimeToken(ctx context.Context, token userrepo.OneTimeToken) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[token.UserID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	for hash, existing := range ur.oneTimeTokens {
+		if existing.UserID == token.UserID && existing.Purpose == token.Purpose {
+			delete(ur.oneTimeTokens, hash)
+		}
+	}
+
+	token.CreatedAt = time.Now().UTC()
+	ur.oneTimeTokens[token.TokenHash] = token
+
+	return nil
+}
+
+// GetOneTimeToken retrieves the token of a user for purpose
+func (ur *memoryUserRepo) GetOneTimeToken(ctx context.Context, userID string, purpose userrepo.TokenPurpose) (userrepo.OneTimeToken, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	for _, token := range ur.oneTimeTokens {
+		if token.UserID == userID && token.Purpose == purpose {
+			return token, nil
+		}
+	}
+
+	return userrepo.OneTimeToken{}, userrepo.ErrTokenNotFound
+}
+
+// GetOneTimeTokenByHash retrieves a token issued for purpose
+func (ur *memoryUserRepo) GetOneTimeTokenByHash(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	token, ok := ur.oneTimeTokens[tokenHash]
+	if !ok || token.Purpose != purpose {
+		return userrepo.OneTimeToken{}, userrepo.ErrTokenNotFound
+	}
+
+	return token, nil
+}
+
+// ConsumeOneTimeToken deletes and returns a token issued for purpose
+func (ur *memoryUserRepo) ConsumeOneTimeToken(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	token, ok := ur.oneTimeTokens[tokenHash]
+	if !ok || token.Purpose != purpose {
+		return userrepo.OneTimeToken{}, userrepo.ErrTokenNotFound
+	}
+
+	delete(ur.oneTimeTokens, tokenHash)
+
+	return token, nil
+}
+
+// SaveTOTP replaces the enrollment of a user, dropping its recovery codes
+func (ur *memoryUserRepo) SaveTOTP(ctx context.Context, totp userrepo.TOTP) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[totp.UserID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	totp.CreatedAt = time.Now().UTC()
+	ur.totps[totp.UserID] = totp
+	delete(ur.recoveryCodes, totp.UserID)
+
+	return nil
+}
+
+// GetTOTP retrieves the enrollment of a user
+func (ur *memoryUserRepo) GetTOTP(ctx context.Context, userID string) (userrepo.TOTP, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	totp, ok := ur.totps[userID]
+	if !ok {
+		return userrepo.TOTP{}, userrepo.ErrTOTPNotFound
+	}
+
+	return totp, nil
+}
+
+// ConfirmTOTP enables the enrollment of a user and stores their recovery codes
+func (ur *memoryUserRepo) ConfirmTOTP(ctx context.Context, userID string, counter uint64, recoveryCodeHashes []string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	totp, ok := ur.totps[userID]
+	if !ok {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	now := time.Now().UTC()
+	totp.ConfirmedAt = &now
+	totp.LastCounter = counter
+	ur.totps[userID] = totp
+
+	codes := make(map[string]bool, len(recoveryCodeHashes))
+	for _, hash := range recoveryCodeHashes {
+		codes[hash] = false
+	}
+	ur.recoveryCodes[userID] = codes
+
+	return nil
+}
+
+// UseTOTPCounter accepts a counter after the last one accepted
+func (ur *memoryUserRepo) UseTOTPCounter(ctx context.Context, userID string, counter uint64) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	totp, ok := ur.totps[userID]
+	if !ok {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	if counter <= totp.LastCounter {
+		return userrepo.ErrTOTPCodeUsed
+	}
+
+	totp.LastCounter = counter
+	totp.FailedAttempts = 0
+	ur.totps[userID] = totp
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user used
+func (ur *memoryUserRepo) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	used, ok := ur.recoveryCodes[userID][codeHash]
+	if !ok || used {
+		return userrepo.ErrRecoveryCodeNotFound
+	}
+
+	ur.recoveryCodes[userID][codeHash] = true
+
+	if totp, ok := ur.totps[userID]; ok {
+		totp.FailedAttempts = 0
+		ur.totps[userID] = totp
+	}
+
+	return nil
+}
+
+// FailTOTP counts an invalid code of a user, locking their enrollment
+// until lockedUntil on the maxFailures-th in a row
+func (ur *memoryUserRepo) FailTOTP(ctx context.Context, userID string, maxFailures int, lockedUntil time.Time) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	totp, ok := ur.totps[userID]
+	if !ok {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	totp.FailedAttempts++
+	if totp.FailedAttempts >= maxFailures {
+		totp.FailedAttempts = 0
+		totp.LockedUntil = lockedUntil
+	}
+	ur.totps[userID] = totp
+
+	return nil
+}
+
+// DeleteTOTP removes the enrollment of a user and their recovery codes
+func (ur *memoryUserRepo) DeleteTOTP(ctx context.Context, userID string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.totps[userID]; !ok {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	delete(ur.totps, userID)
+	delete(ur.recoveryCodes, userID)
+
+	return nil
+}
+
+// CreateAPIKey stores a new API key for an existing user
+func (ur *memoryUserRepo) CreateAPIKey(ctx context.Context, key userrepo.APIKey) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.users[key.UserID]; !ok {
+		return userrepo.ErrUserNotFound
+	}
+
+	key.Scopes = slices.Clone(key.Scopes)
+	key.CreatedAt = time.Now().UTC()
+	ur.apiKeys[key.ID] = key
+
+	return nil
+}
+
+// GetAPIKey retrieves an API key given its ID
+func (ur *memoryUserRepo) GetAPIKey(ctx context.Context, id string) (userrepo.APIKey, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	key, ok := ur.apiKeys[id]
+	if !ok {
+		return userrepo.APIKey{}, userrepo.ErrAPIKeyNotFound
+	}
+
+	return key, nil
+}
+
+// GetAPIKeyByHash retrieves an API key given the hash of the key
+func (ur *memoryUserRepo) GetAPIKeyByHash(ctx context.Context, hash string) (userrepo.APIKey, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	for _, key := range ur.apiKeys {
+		if key.KeyHash == hash {
+			return key, nil
+		}
+	}
+
+	return userrepo.APIKey{}, userrepo.ErrAPIKeyNotFound
+}
+
+// ListAPIKeys returns the API keys of a user, oldest first
+func (ur *memoryUserRepo) ListAPIKeys(ctx context.Context, userID string) ([]userrepo.APIKey, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	keys := []userrepo.APIKey{}
+	for _, key := range ur.apiKeys {
+		if key.UserID == userID {
+			keys = append(keys, key)
+		}
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].CreatedAt.Equal(keys[j].CreatedAt) {
+			return keys[i].ID < keys[j].ID
+		}
+		return keys[i].CreatedAt.Before(keys[j].CreatedAt)
+	})
+
+	return keys, nil
+}
+
+// DeleteAPIKey removes an API key given its ID
+func (ur *memoryUserRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.apiKeys[id]; !ok {
+		return userrepo.ErrAPIKeyNotFound
+	}
+
+	delete(ur.apiKeys, id)
+
+	return nil
+}
+
+// TouchAPIKey records when an API key was last used
+func (ur *memoryUserRepo) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	key, ok := ur.apiKeys[id]
+	if !ok {
+		return userrepo.ErrAPIKeyNotFound
+	}
+
+	at = at.UTC()
+	key.LastUsedAt = &at
+	ur.apiKeys[id] = key
+
+	return nil
+}
+
+// EnqueueOutboxMessage stores a message to be sent now
+func (ur *memoryUserRepo) EnqueueOutboxMessage(ctx context.Context, m userrepo.OutboxMessage) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	ur.enqueue(m)
+
+	return nil
+}
+
+// ClaimOutboxMessages leases up to limit due pending messages, oldest due first
+func (ur *memoryUserRepo) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]userrepo.OutboxMessage, error) {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	now := time.Now().UTC()
+
+	due := []userrepo.OutboxMessage{}
+	for _, m := range ur.outbox {
+		if m.Status == userrepo.OutboxPending && !m.NextAttemptAt.After(now) {
+			due = append(due, m)
+		}
+	}
+
+	sort.Slice(due, func(i, j int) bool {
+		return due[i].NextAttemptAt.Before(due[j].NextAttemptAt)
+	})
+
+	if len(due) > limit {
+		due = due[:limit]
+	}
+
+	for i, m := range due {
+		m.Attempts++
+		m.NextAttemptAt = now.Add(lease)
+		ur.outbox[m.ID] = m
+		due[i] = m
+	}
+
+	return due, nil
+}
+
+// CompleteOutboxMessage removes a delivered message
+func (ur *memoryUserRepo) CompleteOutboxMessage(ctx context.Context, id string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.outbox[id]; !ok {
+		return userrepo.ErrOutboxMessageNotFound
+	}
+
+	delete(ur.outbox, id)
+
+	return nil
+}
+
+// RetryOutboxMessage reschedules a failed message
+func (ur *memoryUserRepo) RetryOutboxMessage(ctx context.Context, id string, lastErr string, at time.Time) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	m, ok := ur.outbox[id]
+	if !ok {
+		return userrepo.ErrOutboxMessageNotFound
+	}
+
+	m.LastError = lastErr
+	m.NextAttemptAt = at.UTC()
+	ur.outbox[id] = m
+
+	return nil
+}
+
+// DeadLetterOutboxMessage stops retrying a failed message
+func (ur *memoryUserRepo) DeadLetterOutboxMessage(ctx context.Context, id string, lastErr string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	m, ok := ur.outbox[id]
+	if !ok {
+		return userrepo.ErrOutboxMessageNotFound
+	}
+
+	m.LastError = lastErr
+	m.Status = userrepo.OutboxDead
+	ur.outbox[id] = m
+
+	return nil
+}
+
+// CreateWebhook stores a new webhook
+func (ur *memoryUserRepo) CreateWebhook(ctx context.Context, webhook userrepo.Webhook) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	webhook.Events = slices.Clone(webhook.Events)
+	webhook.CreatedAt = time.Now().UTC()
+	ur.webhooks[webhook.ID] = webhook
+
+	return nil
+}
+
+// GetWebhook retrieves a webhook given its ID
+func (ur *memoryUserRepo) GetWebhook(ctx context.Context, id string) (userrepo.Webhook, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	webhook, ok := ur.webhooks[id]
+	if !ok {
+		return userrepo.Webhook{}, userrepo.ErrWebhookNotFound
+	}
+
+	return webhook, nil
+}
+
+// ListWebhooks retrieves every webhook, oldest first
+func (ur *memoryUserRepo) ListWebhooks(ctx context.Context) ([]userrepo.Webhook, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	webhooks := []userrepo.Webhook{}
+	for _, webhook := range ur.webhooks {
+		webhooks = append(webhooks, webhook)
+	}
+
+	sort.Slice(webhooks, func(i, j int) bool {
+		if webhooks[i].CreatedAt.Equal(webhooks[j].CreatedAt) {
+			return webhooks[i].ID < webhooks[j].ID
+		}
+		return webhooks[i].CreatedAt.Before(webhooks[j].CreatedAt)
+	})
+
+	return webhooks, nil
+}
+
+// DeleteWebhook removes a webhook and its deliveries given its ID
+func (ur *memoryUserRepo) DeleteWebhook(ctx context.Context, id string) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.webhooks[id]; !ok {
+		return userrepo.ErrWebhookNotFound
+	}
+
+	delete(ur.webhooks, id)
+
+	for deliveryID, d := range ur.deliveries {
+		if d.WebhookID == id {
+			delete(ur.deliveries, deliveryID)
+		}
+	}
+
+	return nil
+}
+
+// CreateWebhookDelivery stores a pending delivery and enqueues m
+func (ur *memoryUserRepo) CreateWebhookDelivery(ctx context.Context, delivery userrepo.WebhookDelivery, m userrepo.OutboxMessage) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	if _, ok := ur.webhooks[delivery.WebhookID]; !ok {
+		return userrepo.ErrWebhookNotFound
+	}
+
+	ur.storeDelivery(delivery)
+	ur.enqueue(m)
+
+	return nil
+}
+
+// storeDelivery stores delivery as pending. Callers must hold the lock.
+func (ur *memoryUserRepo) storeDelivery(delivery userrepo.WebhookDelivery) {
+	now := time.Now().UTC()
+
+	delivery.Status = userrepo.DeliveryPending
+	delivery.Attempts = nil
+	delivery.CreatedAt = now
+	delivery.UpdatedAt = now
+	ur.deliveries[delivery.ID] = delivery
+}
+
+// GetWebhookDelivery retrieves a delivery given its ID
+func (ur *memoryUserRepo) GetWebhookDelivery(ctx context.Context, id string) (userrepo.WebhookDelivery, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	d, ok := ur.deliveries[id]
+	if !ok {
+		return userrepo.WebhookDelivery{}, userrepo.ErrWebhookDeliveryNotFound
+	}
+
+	d.Attempts = slices.Clone(d.Attempts)
+
+	return d, nil
+}
+
+// ListWebhookDeliveries retrieves up to limit of a webhook's deliveries, newest first
+func (ur *memoryUserRepo) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]userrepo.WebhookDelivery, error) {
+	ur.mtx.RLock()
+	defer ur.mtx.RUnlock()
+
+	deliveries := []userrepo.WebhookDelivery{}
+	for _, d := range ur.deliveries {
+		if d.WebhookID == webhookID {
+			d.Attempts = slices.Clone(d.Attempts)
+			deliveries = append(deliveries, d)
+		}
+	}
+
+	sort.Slice(deliveries, func(i, j int) bool {
+		if deliveries[i].CreatedAt.Equal(deliveries[j].CreatedAt) {
+			return deliveries[i].ID > deliveries[j].ID
+		}
+		return deliveries[i].CreatedAt.After(deliveries[j].CreatedAt)
+	})
+
+	if len(deliveries) > limit {
+		deliveries = deliveries[:limit]
+	}
+
+	return deliveries, nil
+}
+
+// RecordWebhookAttempts logs attempts against a delivery and sets its status
+func (ur *memoryUserRepo) RecordWebhookAttempts(ctx context.Context, id string, status userrepo.DeliveryStatus, attempts []userrepo.WebhookAttempt) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	d, ok := ur.deliveries[id]
+	if !ok {
+		return userrepo.ErrWebhookDeliveryNotFound
+	}
+
+	d.Status = status
+	d.Attempts = append(slices.Clone(d.Attempts), attempts...)
+	d.UpdatedAt = time.Now().UTC()
+	ur.deliveries[id] = d
+
+	return nil
+}
+
+// RedeliverWebhookDelivery makes a delivery pending again and enqueues m
+func (ur *memoryUserRepo) RedeliverWebhookDelivery(ctx context.Context, id string, m userrepo.OutboxMessage) error {
+	ur.mtx.Lock()
+	defer ur.mtx.Unlock()
+
+	d, ok := ur.deliveries[id]
+	if !ok {
+		return userrepo.ErrWebhookDeliveryNotFound
+	}
+
+	d.Status = userrepo.DeliveryPending
+	d.UpdatedAt = time.Now().UTC()
+	ur.deliveries[id] = d
+
+	ur.enqueue(m)
+
+	return nil
+}
+
+// enqueue stores m as pending and due now. Callers must hold the lock.
+func (ur *memoryUserRepo) enqueue(m userrepo.OutboxMessage) {
+	now := time.Now().UTC()
+
+	m.Status = userrepo.OutboxPending
+	m.Attempts = 0
+	m.LastError = ""
+	m.NextAttemptAt = now
+	m.CreatedAt = now
+
+	ur.outbox[m.ID] = m
+}
+
+// storeEvents stores the deliveries of events to webhooks that still
+// exist and enqueues their messages. Callers must hold the lock.
+func (ur *memoryUserRepo) storeEvents(events []userrepo.Event) {
+	for _, e := range events {
+		for _, m := range e.Messages {
+			ur.enqueue(m)
+		}
+
+		for _, d := range e.Deliveries {
+			if _, ok := ur.webhooks[d.Delivery.WebhookID]; !ok {
+				continue
+			}
+
+			ur.storeDelivery(d.Delivery)
+			ur.enqueue(d.Message)
+		}
+	}
+}
+
+// buildEvents calls each of fns with u, failing on the first error
+func buildEvents(fns []userrepo.EventFunc, u user.User) ([]userrepo.Event, error) {
+	events := make([]userrepo.Event, 0, len(fns))
+
+	for _, fn := range fns {
+		e, err := fn(u)
+		if err != nil {
+			return nil, err
+		}
+		events = append(events, e)
+	}
+
+	return events, nil
+}
+
+// compare orders a and b by field, breaking ties by id
+func compare(a, b user.User, field userrepo.SortField) int {
+	var c int
+
+	switch field {
+	case userrepo.SortByCreatedAt:
+		c = a.CreatedAt.Compare(b.CreatedAt)
+	case userrepo.SortByName:
+		c = strings.Compare(a.Name, b.Name)
+	case userrepo.SortByEmail:
+		c = strings.Compare(a.Email, b.Email)
+	}
+
+	if c != 0 {
+		return c
+	}
+
+	return strings.Compare(a.ID, b.ID)
+}
+
+// after reports whether u sorts strictly after the cursor position
+func after(u user.User, cursor userrepo.Cursor) bool {
+	pivot := user.User{ID: cursor.ID}
+
+	switch cursor.Field {
+	case userrepo.SortByCreatedAt:
+		pivot.CreatedAt, _ = time.Parse(time.RFC3339Nano, cursor.Value)
+	case userrepo.SortByName:
+		pivot.Name = cursor.Value
+	case userrepo.SortByEmail:
+		pivot.Email = cursor.Value
+	}
+
+	c := compare(u, pivot, cursor.Field)
+	if cursor.Desc {
+		return c < 0
+	}
+
+	return c > 0
+}
+
+// Check never fails, as there is nothing to reach
+func (ur *memoryUserRepo) Check(ctx context.Context) error {
+	return nil
+}
+
+// Close does nothing, as there is nothing to release
+func (ur *memoryUserRepo) Close(ctx context.Context) error {
+	return nil
+}
+
+// NewUserRepo creates a new memoryUserRepo
+func NewUserRepo(opts ...userrepo.Option) userrepo.UserRepo {
+	options := userrepo.NewOptions(opts...)
+
+	ur := &memoryUserRepo{
+		options:       options,
+		users:         map[string]user.User{},
+		emails:        map[string]string{},
+		credentials:   map[string]string{},
+		sessions:      map[string]userrepo.Session{},
+		verifications: map[string]userrepo.VerificationToken{},
+		oneTimeTokens: map[string]userrepo.OneTimeToken{},
+		totps:         map[string]userrepo.TOTP{},
+		recoveryCodes: map[string]map[string]bool{},
+		apiKeys:       map[string]userrepo.APIKey{},
+		outbox:        map[string]userrepo.OutboxMessage{},
+		webhooks:      map[string]userrepo.Webhook{},
+		deliveries:    map[string]userrepo.WebhookDelivery{},
+		mtx:           sync.RWMutex{},
+	}
+
+	return ur
+}
@@ -2,6 +2,7 @@ package mock
 
 import (
 	"context"
+	"time"
 
 	testmock "github.com/stretchr/testify/mock"
 	"github.com/w-h-a/demo-go/api/user"
@@ -12,8 +13,8 @@ type mockUserRepo struct {
 	*testmock.Mock
 }
 
-func (m *mockUserRepo) Create(ctx context.Context, dto user.CreateUserDTO) (user.User, error) {
-	args := m.Called(ctx, dto)
+func (m *mockUserRepo) Create(ctx context.Context, dto user.CreateUserDTO, opts ...userrepo.CreateOption) (user.User, error) {
+	args := m.Called(ctx, dto, opts)
 	return args.Get(0).(user.User), args.Error(1)
 }
 
@@ -32,6 +33,236 @@ func (m *mockUserRepo) GetAll(ctx context.Context, opts ...userrepo.GetAllOption
 	return args.Get(0).([]user.User), args.Error(1)
 }
 
+func (m *mockUserRepo) Update(ctx context.Context, id string, dto user.UpdateUserDTO, opts ...userrepo.ChangeOption) (user.User, error) {
+	args := m.Called(ctx, id, dto)
+	return args.Get(0).(user.User), args.Error(1)
+}
+
+func (m *mockUserRepo) Delete(ctx context.Context, id string, opts ...userrepo.ChangeOption) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	args := m.Called(ctx, userID)
+	return args.String(0), args.Error(1)
+}
+
+func (m *mockUserRepo) CreateSession(ctx context.Context, session userrepo.Session) error {
+	args := m.Called(ctx, session)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetSession(ctx context.Context, id string) (userrepo.Session, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(userrepo.Session), args.Error(1)
+}
+
+func (m *mockUserRepo) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (userrepo.Session, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(userrepo.Session), args.Error(1)
+}
+
+func (m *mockUserRepo) RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) error {
+	args := m.Called(ctx, id, oldHash, newHash, expiresAt)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) RevokeSession(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) SaveVerificationToken(ctx context.Context, token userrepo.VerificationToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetVerificationToken(ctx context.Context, userID string) (userrepo.VerificationToken, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(userrepo.VerificationToken), args.Error(1)
+}
+
+func (m *mockUserRepo) VerifyEmail(ctx context.Context, tokenHash string, now time.Time, opts ...userrepo.ChangeOption) (user.User, error) {
+	args := m.Called(ctx, tokenHash, now)
+	return args.Get(0).(user.User), args.Error(1)
+}
+
+func (m *mockUserRepo) RevokeUserSessions(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) SetPasswordHash(ctx context.Context, userID string, hash string) error {
+	args := m.Called(ctx, userID, hash)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) SaveOneTimeToken(ctx context.Context, token userrepo.OneTimeToken) error {
+	args := m.Called(ctx, token)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetOneTimeToken(ctx context.Context, userID string, purpose userrepo.TokenPurpose) (userrepo.OneTimeToken, error) {
+	args := m.Called(ctx, userID, purpose)
+	return args.Get(0).(userrepo.OneTimeToken), args.Error(1)
+}
+
+func (m *mockUserRepo) GetOneTimeTokenByHash(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	args := m.Called(ctx, purpose, tokenHash)
+	return args.Get(0).(userrepo.OneTimeToken), args.Error(1)
+}
+
+func (m *mockUserRepo) ConsumeOneTimeToken(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	args := m.Called(ctx, purpose, tokenHash)
+	return args.Get(0).(userrepo.OneTimeToken), args.Error(1)
+}
+
+func (m *mockUserRepo) SaveTOTP(ctx context.Context, totp userrepo.TOTP) error {
+	args := m.Called(ctx, totp)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetTOTP(ctx context.Context, userID string) (userrepo.TOTP, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).(userrepo.TOTP), args.Error(1)
+}
+
+func (m *mockUserRepo) ConfirmTOTP(ctx context.Context, userID string, counter uint64, recoveryCodeHashes []string) error {
+	args := m.Called(ctx, userID, counter, recoveryCodeHashes)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) UseTOTPCounter(ctx context.Context, userID string, counter uint64) error {
+	args := m.Called(ctx, userID, counter)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	args := m.Called(ctx, userID, codeHash)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) FailTOTP(ctx context.Context, userID string, maxFailures int, lockedUntil time.Time) error {
+	args := m.Called(ctx, userID, maxFailures, lockedUntil)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) DeleteTOTP(ctx context.Context, userID string) error {
+	args := m.Called(ctx, userID)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) CreateAPIKey(ctx context.Context, key userrepo.APIKey) error {
+	args := m.Called(ctx, key)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetAPIKey(ctx context.Context, id string) (userrepo.APIKey, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(userrepo.APIKey), args.Error(1)
+}
+
+func (m *mockUserRepo) GetAPIKeyByHash(ctx context.Context, hash string) (userrepo.APIKey, error) {
+	args := m.Called(ctx, hash)
+	return args.Get(0).(userrepo.APIKey), args.Error(1)
+}
+
+func (m *mockUserRepo) ListAPIKeys(ctx context.Context, userID string) ([]userrepo.APIKey, error) {
+	args := m.Called(ctx, userID)
+	return args.Get(0).([]userrepo.APIKey), args.Error(1)
+}
+
+func (m *mockUserRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	args := m.Called(ctx, id, at)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) EnqueueOutboxMessage(ctx context.Context, msg userrepo.OutboxMessage) error {
+	args := m.Called(ctx, msg)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]userrepo.OutboxMessage, error) {
+	args := m.Called(ctx, limit, lease)
+	return args.Get(0).([]userrepo.OutboxMessage), args.Error(1)
+}
+
+func (m *mockUserRepo) CompleteOutboxMessage(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) RetryOutboxMessage(ctx context.Context, id string, lastErr string, at time.Time) error {
+	args := m.Called(ctx, id, lastErr, at)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) DeadLetterOutboxMessage(ctx context.Context, id string, lastErr string) error {
+	args := m.Called(ctx, id, lastErr)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) CreateWebhook(ctx context.Context, webhook userrepo.Webhook) error {
+	args := m.Called(ctx, webhook)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetWebhook(ctx context.Context, id string) (userrepo.Webhook, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(userrepo.Webhook), args.Error(1)
+}
+
+func (m *mockUserRepo) ListWebhooks(ctx context.Context) ([]userrepo.Webhook, error) {
+	args := m.Called(ctx)
+	return args.Get(0).([]userrepo.Webhook), args.Error(1)
+}
+
+func (m *mockUserRepo) DeleteWebhook(ctx context.Context, id string) error {
+	args := m.Called(ctx, id)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) CreateWebhookDelivery(ctx context.Context, delivery userrepo.WebhookDelivery, msg userrepo.OutboxMessage) error {
+	args := m.Called(ctx, delivery, msg)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) GetWebhookDelivery(ctx context.Context, id string) (userrepo.WebhookDelivery, error) {
+	args := m.Called(ctx, id)
+	return args.Get(0).(userrepo.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockUserRepo) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]userrepo.WebhookDelivery, error) {
+	args := m.Called(ctx, webhookID, limit)
+	return args.Get(0).([]userrepo.WebhookDelivery), args.Error(1)
+}
+
+func (m *mockUserRepo) RecordWebhookAttempts(ctx context.Context, id string, status userrepo.DeliveryStatus, attempts []userrepo.WebhookAttempt) error {
+	args := m.Called(ctx, id, status, attempts)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) RedeliverWebhookDelivery(ctx context.Context, id string, msg userrepo.OutboxMessage) error {
+	args := m.Called(ctx, id, msg)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) Check(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
+func (m *mockUserRepo) Close(ctx context.Context) error {
+	args := m.Called(ctx)
+	return args.Error(0)
+}
+
 func NewUserRepo(opts ...userrepo.Option) *mockUserRepo {
 	return &mockUserRepo{&testmock.Mock{}}
 }
@@ -0,0 +1,22 @@
+package userrepo
+
+import "time"
+
+// TokenPurpose is what a one-time token may be exchanged for.
+type TokenPurpose string
+
+const (
+	PurposePasswordReset TokenPurpose = "password_reset"
+	PurposeMagicLink     TokenPurpose = "magic_link"
+)
+
+// OneTimeToken is emailed to Email and used up when exchanged. A user
+// has at most one per purpose, and only a hash of it is stored.
+type OneTimeToken struct {
+	UserID    string
+	Purpose   TokenPurpose
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
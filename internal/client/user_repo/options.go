@@ -1,11 +1,15 @@
 package userrepo
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 type Option func(*Options)
 
 type Options struct {
 	Location string
+	Logger   *slog.Logger
 	Context  context.Context
 }
 
@@ -15,8 +19,16 @@ func WithLocation(loc string) Option {
 	}
 }
 
+// WithLogger sets the logger the store reports with.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
 func NewOptions(opts ...Option) Options {
 	options := Options{
+		Logger:  slog.Default(),
 		Context: context.Background(),
 	}
 
@@ -27,14 +39,168 @@ func NewOptions(opts ...Option) Options {
 	return options
 }
 
+type SortField string
+
+const (
+	SortByCreatedAt SortField = "created_at"
+	SortByID        SortField = "id"
+	SortByName      SortField = "name"
+	SortByEmail     SortField = "email"
+)
+
+func (f SortField) Valid() bool {
+	switch f {
+	case SortByCreatedAt, SortByID, SortByName, SortByEmail:
+		return true
+	default:
+		return false
+	}
+}
+
 type GetAllOption func(*GetAllOptions)
 
 type GetAllOptions struct {
-	Context context.Context
+	Limit         int
+	Cursor        *Cursor
+	SortField     SortField
+	SortDesc      bool
+	NamePrefix    string
+	EmailPrefix   string
+	EmailVerified *bool
+	Context       context.Context
+}
+
+// WithLimit caps the number of users returned. Zero means no limit.
+func WithLimit(limit int) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.Limit = limit
+	}
+}
+
+// WithCursor resumes the listing after the user the cursor points at.
+func WithCursor(c Cursor) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.Cursor = &c
+	}
+}
+
+// WithSort orders users by field, ties broken by id.
+func WithSort(field SortField, desc bool) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.SortField = field
+		o.SortDesc = desc
+	}
+}
+
+// WithNamePrefix keeps users whose name starts with prefix, ignoring case.
+func WithNamePrefix(prefix string) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.NamePrefix = prefix
+	}
+}
+
+// WithEmailPrefix keeps users whose email starts with prefix.
+func WithEmailPrefix(prefix string) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.EmailPrefix = prefix
+	}
+}
+
+// WithEmailVerified keeps users whose email verification state is verified.
+func WithEmailVerified(verified bool) GetAllOption {
+	return func(o *GetAllOptions) {
+		o.EmailVerified = &verified
+	}
 }
 
 func NewGetAllOptions(opts ...GetAllOption) GetAllOptions {
 	options := GetAllOptions{
+		SortField: SortByCreatedAt,
+		Context:   context.Background(),
+	}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
+
+type CreateOption func(*CreateOptions)
+
+type CreateOptions struct {
+	PasswordHash      string
+	VerificationToken *VerificationToken
+	Outbox            []OutboxMessage
+	Events            []EventFunc
+	Context           context.Context
+}
+
+// WithPasswordHash stores the hash as the new user's credentials.
+func WithPasswordHash(hash string) CreateOption {
+	return func(o *CreateOptions) {
+		o.PasswordHash = hash
+	}
+}
+
+// WithVerificationToken stores t, which needs no UserID or Email,
+// as the new user's verification token.
+func WithVerificationToken(t VerificationToken) CreateOption {
+	return func(o *CreateOptions) {
+		o.VerificationToken = &t
+	}
+}
+
+// WithOutboxMessage enqueues m along with the new user, so it is
+// stored if and only if the user is.
+func WithOutboxMessage(m OutboxMessage) CreateOption {
+	return func(o *CreateOptions) {
+		o.Outbox = append(o.Outbox, m)
+	}
+}
+
+// WithEvent stores the event fn builds from the new user along with
+// it, so it is stored if and only if the user is. A nil fn is ignored.
+func WithEvent(fn EventFunc) CreateOption {
+	return func(o *CreateOptions) {
+		if fn != nil {
+			o.Events = append(o.Events, fn)
+		}
+	}
+}
+
+func NewCreateOptions(opts ...CreateOption) CreateOptions {
+	options := CreateOptions{
+		Context: context.Background(),
+	}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
+
+type ChangeOption func(*ChangeOptions)
+
+type ChangeOptions struct {
+	Events  []EventFunc
+	Context context.Context
+}
+
+// WithChangeEvent stores the event fn builds from the changed user
+// along with the change, so it is stored if and only if the change
+// is. A nil fn is ignored.
+func WithChangeEvent(fn EventFunc) ChangeOption {
+	return func(o *ChangeOptions) {
+		if fn != nil {
+			o.Events = append(o.Events, fn)
+		}
+	}
+}
+
+func NewChangeOptions(opts ...ChangeOption) ChangeOptions {
+	options := ChangeOptions{
 		Context: context.Background(),
 	}
 
@@ -0,0 +1,41 @@
+package userrepo
+
+import "time"
+
+// OutboxStatus is where an outbox message is in its delivery.
+type OutboxStatus string
+
+const (
+	OutboxPending OutboxStatus = "pending"
+	// OutboxDead messages ran out of attempts, and are kept
+	// for inspection rather than retried.
+	OutboxDead OutboxStatus = "dead"
+)
+
+// OutboxMessage is a notification waiting to be sent. Messages are
+// stored alongside the change that causes them, so neither is lost
+// without the other, and removed once delivered.
+type OutboxMessage struct {
+	ID    string
+	Kind  string
+	Name  string
+	Email string
+	Token string
+	// Event is set for messages announcing an event to other
+	// systems rather than emailing the user, with Payload its JSON
+	Event   string
+	Payload []byte
+	// DeliveryID is set for messages delivering an event to a
+	// subscribed webhook, which hold no Payload of their own
+	DeliveryID string
+	// TraceParent is the W3C traceparent of the span that queued the
+	// message, so sending it joins that span's trace
+	TraceParent string
+	// Status, Attempts, LastError and NextAttemptAt
+	// are kept by the repo
+	Status        OutboxStatus
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time
+	CreatedAt     time.Time
+}
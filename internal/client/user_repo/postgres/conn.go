@@ -0,0 +1,166 @@
+package postgres
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"fmt"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+const (
+	defaultPingTimeout = 5 * time.Second
+	maxRetryBackoff    = 30 * time.Second
+)
+
+var (
+	ErrInvalidOptions = errors.New("invalid postgres options")
+	ErrUnreachable    = errors.New("failed to reach postgres")
+)
+
+// validateOptions rejects options that cannot produce a working pool
+func validateOptions(options userrepo.Options) error {
+	if options.Location == "" {
+		return errors.New("postgres location is required")
+	}
+
+	if n, ok := getMaxOpenConnsFromCtx(options.Context); ok && n < 0 {
+		return fmt.Errorf("max open conns must not be negative, got %d", n)
+	}
+
+	if n, ok := getMaxIdleConnsFromCtx(options.Context); ok && n < 0 {
+		return fmt.Errorf("max idle conns must not be negative, got %d", n)
+	}
+
+	// zero max open conns is no limit
+	maxOpen, okOpen := getMaxOpenConnsFromCtx(options.Context)
+	maxIdle, okIdle := getMaxIdleConnsFromCtx(options.Context)
+	if okOpen && okIdle && maxOpen > 0 && maxIdle > maxOpen {
+		return fmt.Errorf("max idle conns must not exceed max open conns, got %d idle and %d open", maxIdle, maxOpen)
+	}
+
+	if d, ok := getConnMaxLifetimeFromCtx(options.Context); ok && d < 0 {
+		return fmt.Errorf("conn max lifetime must not be negative, got %s", d)
+	}
+
+	if d, ok := getPingTimeoutFromCtx(options.Context); ok && d <= 0 {
+		return fmt.Errorf("ping timeout must be positive, got %s", d)
+	}
+
+	if r, ok := getConnectRetryFromCtx(options.Context); ok && (r.attempts < 0 || r.backoff < 0) {
+		return fmt.Errorf("connect retry must not be negative, got %d attempts with %s backoff", r.attempts, r.backoff)
+	}
+
+	return nil
+}
+
+// pingTimeout bounds each ping, at startup and in health checks
+func pingTimeout(options userrepo.Options) time.Duration {
+	if d, ok := getPingTimeoutFromCtx(options.Context); ok {
+		return d
+	}
+
+	return defaultPingTimeout
+}
+
+// ConnectBackoff is the wait before the retry-th retry of the startup
+// ping, counting from zero. It doubles from backoff, up to 30s.
+func ConnectBackoff(backoff time.Duration, retry int) time.Duration {
+	for ; retry > 0 && backoff < maxRetryBackoff; retry-- {
+		backoff *= 2
+	}
+
+	return min(backoff, maxRetryBackoff)
+}
+
+// withConnectTimeout bounds connecting by timeout, rounded up to the
+// whole seconds of connect_timeout, unless location sets its own. The
+// driver ignores contexts once dialled, so a server that accepts but
+// never answers would otherwise hang pings past their timeout.
+func withConnectTimeout(location string, timeout time.Duration) (string, error) {
+	seconds := strconv.Itoa(int(math.Ceil(timeout.Seconds())))
+
+	if !strings.HasPrefix(location, "postgres://") && !strings.HasPrefix(location, "postgresql://") {
+		if strings.Contains(location, "connect_timeout=") {
+			return location, nil
+		}
+		return location + " connect_timeout=" + seconds, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", err
+	}
+
+	q := u.Query()
+	if !q.Has("connect_timeout") {
+		q.Set("connect_timeout", seconds)
+		u.RawQuery = q.Encode()
+	}
+
+	return u.String(), nil
+}
+
+// connect opens a configured pool and pings it, retrying with
+// exponential backoff so the db may come up after we do.
+func connect(options userrepo.Options) (*sql.DB, error) {
+	if err := validateOptions(options); err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+
+	location, err := withConnectTimeout(options.Location, pingTimeout(options))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %w", ErrInvalidOptions, err)
+	}
+
+	conn, err := sql.Open(DRIVER, location)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open postgres: %w", err)
+	}
+
+	if n, ok := getMaxOpenConnsFromCtx(options.Context); ok {
+		conn.SetMaxOpenConns(n)
+	}
+
+	if n, ok := getMaxIdleConnsFromCtx(options.Context); ok {
+		conn.SetMaxIdleConns(n)
+	}
+
+	if d, ok := getConnMaxLifetimeFromCtx(options.Context); ok {
+		conn.SetConnMaxLifetime(d)
+	}
+
+	retry, _ := getConnectRetryFromCtx(options.Context)
+
+	for attempt := 0; ; attempt++ {
+		pingCtx, pingCancel := context.WithTimeout(options.Context, pingTimeout(options))
+		err = conn.PingContext(pingCtx)
+		pingCancel()
+
+		if err == nil {
+			return conn, nil
+		}
+
+		if attempt >= retry.attempts {
+			conn.Close()
+			return nil, fmt.Errorf("%w after %d attempt(s): %w", ErrUnreachable, attempt+1, err)
+		}
+
+		backoff := ConnectBackoff(retry.backoff, attempt)
+
+		options.Logger.Warn("postgres not reachable yet, retrying", "attempt", attempt+1, "backoff", backoff, "error", err)
+
+		select {
+		case <-time.After(backoff):
+		case <-options.Context.Done():
+			conn.Close()
+			return nil, fmt.Errorf("%w: %w", ErrUnreachable, options.Context.Err())
+		}
+	}
+}
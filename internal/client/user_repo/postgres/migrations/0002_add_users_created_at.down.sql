@@ -0,0 +1,3 @@
+DROP INDEX IF EXISTS users_name_id_idx;
+DROP INDEX IF EXISTS users_created_at_id_idx;
+ALTER TABLE users DROP COLUMN IF EXISTS created_at;
@@ -0,0 +1,3 @@
+ALTER TABLE outbox
+    DROP COLUMN IF EXISTS payload,
+    DROP COLUMN IF EXISTS event;
@@ -0,0 +1,3 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS event TEXT NOT NULL DEFAULT '',
+    ADD COLUMN IF NOT EXISTS payload BYTEA;
@@ -0,0 +1,3 @@
+ALTER TABLE outbox DROP COLUMN IF EXISTS delivery_id;
+DROP TABLE IF EXISTS webhook_deliveries;
+DROP TABLE IF EXISTS webhooks;
@@ -0,0 +1,2 @@
+ALTER TABLE outbox
+    ADD COLUMN IF NOT EXISTS trace_parent TEXT NOT NULL DEFAULT '';
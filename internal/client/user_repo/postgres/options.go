@@ -0,0 +1,99 @@
+package postgres
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+type maxOpenConnsKey struct{}
+
+func WithMaxOpenConns(n int) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, maxOpenConnsKey{}, n)
+	}
+}
+
+func getMaxOpenConnsFromCtx(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxOpenConnsKey{}).(int)
+	return n, ok
+}
+
+type maxIdleConnsKey struct{}
+
+func WithMaxIdleConns(n int) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, maxIdleConnsKey{}, n)
+	}
+}
+
+func getMaxIdleConnsFromCtx(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxIdleConnsKey{}).(int)
+	return n, ok
+}
+
+type connMaxLifetimeKey struct{}
+
+func WithConnMaxLifetime(d time.Duration) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, connMaxLifetimeKey{}, d)
+	}
+}
+
+func getConnMaxLifetimeFromCtx(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(connMaxLifetimeKey{}).(time.Duration)
+	return d, ok
+}
+
+type pingTimeoutKey struct{}
+
+// WithPingTimeout bounds each ping. Unless the location sets
+// connect_timeout, it bounds opening connections too, rounded up to
+// whole seconds.
+func WithPingTimeout(d time.Duration) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, pingTimeoutKey{}, d)
+	}
+}
+
+func getPingTimeoutFromCtx(ctx context.Context) (time.Duration, bool) {
+	d, ok := ctx.Value(pingTimeoutKey{}).(time.Duration)
+	return d, ok
+}
+
+type connectRetry struct {
+	attempts int
+	backoff  time.Duration
+}
+
+type connectRetryKey struct{}
+
+// WithConnectRetry retries the startup ping up to attempts more times,
+// waiting backoff before the first retry and doubling it after each.
+func WithConnectRetry(attempts int, backoff time.Duration) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, connectRetryKey{}, connectRetry{attempts, backoff})
+	}
+}
+
+func getConnectRetryFromCtx(ctx context.Context) (connectRetry, bool) {
+	r, ok := ctx.Value(connectRetryKey{}).(connectRetry)
+	return r, ok
+}
+
+type registererKey struct{}
+
+// WithRegisterer sets where the stats of the connection pool, such as
+// connections in use and time spent waiting for one, are registered.
+func WithRegisterer(reg prometheus.Registerer) userrepo.Option {
+	return func(o *userrepo.Options) {
+		o.Context = context.WithValue(o.Context, registererKey{}, reg)
+	}
+}
+
+func getRegistererFromCtx(ctx context.Context) (prometheus.Registerer, bool) {
+	reg, ok := ctx.Value(registererKey{}).(prometheus.Registerer)
+	return reg, ok
+}
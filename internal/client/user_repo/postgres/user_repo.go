@@ -3,20 +3,69 @@ package postgres
 import (
 	"context"
 	"database/sql"
+	"encoding/json"
 	"errors"
+	"fmt"
+	"strings"
+	"time"
 
+	"github.com/XSAM/otelsql"
 	"github.com/google/uuid"
-	_ "github.com/lib/pq"
+	"github.com/lib/pq"
+	"github.com/prometheus/client_golang/prometheus/collectors"
 	"github.com/w-h-a/demo-go/api/user"
 	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
 )
 
 var DRIVER string
 
+const (
+	uniqueViolation     = "23505"
+	foreignKeyViolation = "23503"
+)
+
+var ErrSchemaOutOfDate = errors.New("database schema out of date")
+
+const userColumns = `id, name, email, roles, email_verified, created_at`
+
+const sessionColumns = `id, user_id, refresh_token_hash, expires_at, revoked_at, created_at`
+
+const verificationColumns = `user_id, email, token_hash, expires_at, created_at`
+
+const apiKeyColumns = `id, user_id, name, prefix, key_hash, scopes, expires_at, last_used_at, created_at`
+
+const outboxColumns = `id, kind, name, email, token, event, payload, delivery_id, trace_parent, status, attempts, last_error, next_attempt_at, created_at`
+
+const webhookColumns = `id, url, secret, events, created_at`
+
+const deliveryColumns = `id, webhook_id, event_id, event, payload, status, attempts, created_at, updated_at`
+
+const oneTimeTokenColumns = `user_id, purpose, email, token_hash, expires_at, created_at`
+
+var sortColumns = map[userrepo.SortField]string{
+	userrepo.SortByCreatedAt: "created_at",
+	userrepo.SortByID:        "id",
+	userrepo.SortByName:      "name",
+	userrepo.SortByEmail:     "email",
+}
+
 func init() {
-	// TODO: register with otel and set driver
+	// queries are traced with the global tracer provider, as the
+	// driver is registered before one is set up
+	driver, err := otelsql.Register("postgres",
+		otelsql.WithAttributes(semconv.DBSystemNamePostgreSQL),
+		otelsql.WithSpanOptions(otelsql.SpanOptions{
+			DisableErrSkip:       true,
+			OmitConnResetSession: true,
+			OmitRows:             true,
+		}),
+	)
+	if err != nil {
+		panic(fmt.Sprintf("failed to register traced postgres driver: %v", err))
+	}
 
-	DRIVER = "postgres"
+	DRIVER = driver
 }
 
 // pgUserRepo is an implementation of UserRepo
@@ -25,17 +74,60 @@ type pgUserRepo struct {
 	conn    *sql.DB
 }
 
-// Create inserts a new user into the db
-func (ur *pgUserRepo) Create(ctx context.Context, dto user.CreateUserDTO) (user.User, error) {
+// Create inserts a new user into the db, along with their
+// credentials and verification token when given
+func (ur *pgUserRepo) Create(ctx context.Context, dto user.CreateUserDTO, opts ...userrepo.CreateOption) (user.User, error) {
+	options := userrepo.NewCreateOptions(opts...)
+
 	u := user.User{
 		ID:    uuid.NewString(),
 		Name:  dto.Name,
 		Email: dto.Email,
 	}
 
-	query := `INSERT INTO users (id, name, email) VALUES ($1, $2, $3)`
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return user.User{}, err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO users (id, name, email) VALUES ($1, $2, $3) RETURNING created_at`
+
+	if err := tx.QueryRowContext(ctx, query, u.ID, u.Name, u.Email).Scan(&u.CreatedAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return user.User{}, userrepo.ErrEmailInUse
+		}
+		return user.User{}, err
+	}
+
+	if options.PasswordHash != "" {
+		query := `INSERT INTO credentials (user_id, password_hash) VALUES ($1, $2)`
+
+		if _, err := tx.ExecContext(ctx, query, u.ID, options.PasswordHash); err != nil {
+			return user.User{}, err
+		}
+	}
+
+	if t := options.VerificationToken; t != nil {
+		query := `INSERT INTO email_verifications (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+		if _, err := tx.ExecContext(ctx, query, u.ID, u.Email, t.TokenHash, t.ExpiresAt); err != nil {
+			return user.User{}, err
+		}
+	}
+
+	for _, m := range options.Outbox {
+		if err := enqueueOutboxMessage(ctx, tx, m); err != nil {
+			return user.User{}, err
+		}
+	}
+
+	if err := storeEvents(ctx, tx, options.Events, u); err != nil {
+		return user.User{}, err
+	}
 
-	if _, err := ur.conn.ExecContext(ctx, query, u.ID, u.Name, u.Email); err != nil {
+	if err := tx.Commit(); err != nil {
 		return user.User{}, err
 	}
 
@@ -44,13 +136,12 @@ func (ur *pgUserRepo) Create(ctx context.Context, dto user.CreateUserDTO) (user.
 
 // GetByID retrieves a user from the db given their ID.
 func (ur *pgUserRepo) GetByID(ctx context.Context, id string) (user.User, error) {
-	query := `SELECT id, name, email FROM users WHERE id = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE id = $1`
 
 	row := ur.conn.QueryRowContext(ctx, query, id)
 
-	var u user.User
-
-	if err := row.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+	u, err := scanUser(row)
+	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user.User{}, userrepo.ErrUserNotFound
 		}
@@ -62,13 +153,11 @@ func (ur *pgUserRepo) GetByID(ctx context.Context, id string) (user.User, error)
 
 // GetByEmail retrieves a user from the db given their email.
 func (ur *pgUserRepo) GetByEmail(ctx context.Context, email string) (user.User, error) {
-	query := `SELECT id, name, email FROM users WHERE email = $1`
+	query := `SELECT ` + userColumns + ` FROM users WHERE email = $1`
 
 	row := ur.conn.QueryRowContext(ctx, query, email)
 
-	var u user.User
-
-	err := row.Scan(&u.ID, &u.Name, &u.Email)
+	u, err := scanUser(row)
 	if err != nil {
 		if errors.Is(err, sql.ErrNoRows) {
 			return user.User{}, userrepo.ErrUserNotFound
@@ -81,66 +170,1125 @@ func (ur *pgUserRepo) GetByEmail(ctx context.Context, email string) (user.User,
 
 // GetAll retrieves all users that satisfy GetAllOptions from the db
 func (ur *pgUserRepo) GetAll(ctx context.Context, opts ...userrepo.GetAllOption) ([]user.User, error) {
-	// TODO: use opts to get sort, filters, etc
+	options := userrepo.NewGetAllOptions(opts...)
+
+	column, ok := sortColumns[options.SortField]
+	if !ok {
+		return nil, fmt.Errorf("unsupported sort field %q", options.SortField)
+	}
+
+	direction, comparison := "ASC", ">"
+	if options.SortDesc {
+		direction, comparison = "DESC", "<"
+	}
 
-	query := `SELECT id, name, email FROM users`
+	var conds []string
+	var args []any
 
-	rows, err := ur.conn.QueryContext(ctx, query)
+	if options.NamePrefix != "" {
+		args = append(args, escapeLike(options.NamePrefix)+"%")
+		conds = append(conds, fmt.Sprintf("name ILIKE $%d", len(args)))
+	}
+
+	if options.EmailPrefix != "" {
+		args = append(args, escapeLike(options.EmailPrefix)+"%")
+		conds = append(conds, fmt.Sprintf("email LIKE $%d", len(args)))
+	}
+
+	if options.EmailVerified != nil {
+		args = append(args, *options.EmailVerified)
+		conds = append(conds, fmt.Sprintf("email_verified = $%d", len(args)))
+	}
+
+	if options.Cursor != nil {
+		var value any = options.Cursor.Value
+		if options.Cursor.Field == userrepo.SortByCreatedAt {
+			t, err := time.Parse(time.RFC3339Nano, options.Cursor.Value)
+			if err != nil {
+				return nil, userrepo.ErrInvalidCursor
+			}
+			value = t
+		}
+		args = append(args, value, options.Cursor.ID)
+		conds = append(conds, fmt.Sprintf("(%s, id) %s ($%d, $%d)", column, comparison, len(args)-1, len(args)))
+	}
+
+	query := `SELECT ` + userColumns + ` FROM users`
+
+	if len(conds) > 0 {
+		query += ` WHERE ` + strings.Join(conds, ` AND `)
+	}
+
+	query += fmt.Sprintf(` ORDER BY %s %s, id %s`, column, direction, direction)
+
+	if options.Limit > 0 {
+		args = append(args, options.Limit)
+		query += fmt.Sprintf(` LIMIT $%d`, len(args))
+	}
+
+	rows, err := ur.conn.QueryContext(ctx, query, args...)
 	if err != nil {
 		return nil, err
 	}
 	defer rows.Close()
 
-	var us []user.User
+	us := []user.User{}
 
 	for rows.Next() {
-		var u user.User
-		if err := rows.Scan(&u.ID, &u.Name, &u.Email); err != nil {
+		u, err := scanUser(rows)
+		if err != nil {
 			return nil, err
 		}
 		us = append(us, u)
 	}
 
+	if err := rows.Err(); err != nil {
+		return nil, err
+	}
+
 	return us, nil
 }
 
-// NewUserRepo creates a new pgUserRepo
-func NewUserRepo(opts ...userrepo.Option) userrepo.UserRepo {
-	options := userrepo.NewOptions(opts...)
+// Update replaces the name, email and, when set, roles of an existing user in the db
+func (ur *pgUserRepo) Update(ctx context.Context, id string, dto user.UpdateUserDTO, opts ...userrepo.ChangeOption) (user.User, error) {
+	options := userrepo.NewChangeOptions(opts...)
 
-	// TODO: validate options
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return user.User{}, err
+	}
+	defer tx.Rollback()
 
-	ur := &pgUserRepo{
-		options: options,
+	// a new address has to be verified again
+	query := `UPDATE users SET name = $2, email = $3, roles = COALESCE($4, roles), email_verified = email_verified AND email = $3 WHERE id = $1 RETURNING ` + userColumns
+
+	// nil roles bind as NULL, which keeps the current ones
+	var roles pq.StringArray
+	for _, role := range dto.Roles {
+		roles = append(roles, string(role))
 	}
+	if dto.Roles != nil && roles == nil {
+		roles = pq.StringArray{}
+	}
+
+	row := tx.QueryRowContext(ctx, query, id, dto.Name, dto.Email, roles)
 
-	conn, err := sql.Open(DRIVER, ur.options.Location)
+	u, err := scanUser(row)
 	if err != nil {
-		// log
-		panic(err)
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, userrepo.ErrUserNotFound
+		}
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == uniqueViolation {
+			return user.User{}, userrepo.ErrEmailInUse
+		}
+		return user.User{}, err
+	}
+
+	if err := storeEvents(ctx, tx, options.Events, u); err != nil {
+		return user.User{}, err
 	}
 
-	if err := conn.Ping(); err != nil {
-		// log
-		panic(err)
+	if err := tx.Commit(); err != nil {
+		return user.User{}, err
 	}
 
-	// TODO: start otel
+	return u, nil
+}
 
-	ur.conn = conn
+// Delete removes a user from the db given their ID.
+func (ur *pgUserRepo) Delete(ctx context.Context, id string, opts ...userrepo.ChangeOption) error {
+	options := userrepo.NewChangeOptions(opts...)
+
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM users WHERE id = $1 RETURNING ` + userColumns
+
+	existing, err := scanUser(tx.QueryRowContext(ctx, query, id))
+	if errors.Is(err, sql.ErrNoRows) {
+		return userrepo.ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := storeEvents(ctx, tx, options.Events, existing); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetPasswordHash retrieves the password hash of a user from the db
+func (ur *pgUserRepo) GetPasswordHash(ctx context.Context, userID string) (string, error) {
+	query := `SELECT password_hash FROM credentials WHERE user_id = $1`
+
+	var hash string
+
+	if err := ur.conn.QueryRowContext(ctx, query, userID).Scan(&hash); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return "", userrepo.ErrCredentialsNotFound
+		}
+		return "", err
+	}
+
+	return hash, nil
+}
+
+// CreateSession inserts a new session into the db
+func (ur *pgUserRepo) CreateSession(ctx context.Context, session userrepo.Session) error {
+	query := `INSERT INTO sessions (id, user_id, refresh_token_hash, expires_at) VALUES ($1, $2, $3, $4)`
+
+	if _, err := ur.conn.ExecContext(ctx, query, session.ID, session.UserID, session.RefreshTokenHash, session.ExpiresAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetSession retrieves a session from the db given its ID
+func (ur *pgUserRepo) GetSession(ctx context.Context, id string) (userrepo.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE id = $1`
+
+	return scanSession(ur.conn.QueryRowContext(ctx, query, id))
+}
+
+// GetSessionByRefreshTokenHash retrieves a session from the db given its refresh token hash
+func (ur *pgUserRepo) GetSessionByRefreshTokenHash(ctx context.Context, hash string) (userrepo.Session, error) {
+	query := `SELECT ` + sessionColumns + ` FROM sessions WHERE refresh_token_hash = $1`
+
+	return scanSession(ur.conn.QueryRowContext(ctx, query, hash))
+}
+
+// RotateSession swaps the refresh token hash of a live session in the db
+func (ur *pgUserRepo) RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) error {
+	query := `UPDATE sessions SET refresh_token_hash = $3, expires_at = $4 WHERE id = $1 AND refresh_token_hash = $2 AND revoked_at IS NULL`
+
+	return expectOneRow(ur.conn.ExecContext(ctx, query, id, oldHash, newHash, expiresAt))
+}
+
+// RevokeSession marks a session in the db revoked
+func (ur *pgUserRepo) RevokeSession(ctx context.Context, id string) error {
+	query := `UPDATE sessions SET revoked_at = COALESCE(revoked_at, now()) WHERE id = $1`
+
+	return expectOneRow(ur.conn.ExecContext(ctx, query, id))
+}
+
+// RevokeUserSessions marks every session of a user in the db revoked
+func (ur *pgUserRepo) RevokeUserSessions(ctx context.Context, userID string) error {
+	query := `UPDATE sessions SET revoked_at = now() WHERE user_id = $1 AND revoked_at IS NULL`
+
+	_, err := ur.conn.ExecContext(ctx, query, userID)
 
-	query := `
-    CREATE TABLE IF NOT EXISTS users (
-        id UUID PRIMARY KEY,
-        name VARCHAR(100) NOT NULL,
-        email VARCHAR(100) NOT NULL UNIQUE
-    );
-    `
+	return err
+}
+
+// SetPasswordHash creates or replaces the credentials of a user in the db
+func (ur *pgUserRepo) SetPasswordHash(ctx context.Context, userID string, hash string) error {
+	query := `INSERT INTO credentials (user_id, password_hash) VALUES ($1, $2)
+		ON CONFLICT (user_id) DO UPDATE SET password_hash = EXCLUDED.password_hash, updated_at = now()`
+
+	if _, err := ur.conn.ExecContext(ctx, query, userID, hash); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// SaveVerificationToken replaces the verification token of a user in the db
+func (ur *pgUserRepo) SaveVerificationToken(ctx context.Context, token userrepo.VerificationToken) error {
+	query := `INSERT INTO email_verifications (user_id, email, token_hash, expires_at) VALUES ($1, $2, $3, $4)
+		ON CONFLICT (user_id) DO UPDATE SET email = EXCLUDED.email, token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at, created_at = now()`
+
+	if _, err := ur.conn.ExecContext(ctx, query, token.UserID, token.Email, token.TokenHash, token.ExpiresAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetVerificationToken retrieves the verification token of a user from the db
+func (ur *pgUserRepo) GetVerificationToken(ctx context.Context, userID string) (userrepo.VerificationToken, error) {
+	query := `SELECT ` + verificationColumns + ` FROM email_verifications WHERE user_id = $1`
+
+	var t userrepo.VerificationToken
+
+	if err := ur.conn.QueryRowContext(ctx, query, userID).Scan(&t.UserID, &t.Email, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.VerificationToken{}, userrepo.ErrVerificationTokenNotFound
+		}
+		return userrepo.VerificationToken{}, err
+	}
+
+	return t, nil
+}
+
+// VerifyEmail consumes a verification token in the db and marks the address it was sent to verified
+func (ur *pgUserRepo) VerifyEmail(ctx context.Context, tokenHash string, now time.Time, opts ...userrepo.ChangeOption) (user.User, error) {
+	options := userrepo.NewChangeOptions(opts...)
+
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return user.User{}, err
+	}
+	defer tx.Rollback()
+
+	query := `DELETE FROM email_verifications WHERE token_hash = $1 RETURNING user_id, email, expires_at`
+
+	var t userrepo.VerificationToken
+
+	if err := tx.QueryRowContext(ctx, query, tokenHash).Scan(&t.UserID, &t.Email, &t.ExpiresAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return user.User{}, userrepo.ErrVerificationTokenNotFound
+		}
+		return user.User{}, err
+	}
+
+	if !now.Before(t.ExpiresAt) {
+		// commit anyway, so the expired token is cleaned up
+		if err := tx.Commit(); err != nil {
+			return user.User{}, err
+		}
+		return user.User{}, userrepo.ErrVerificationTokenExpired
+	}
+
+	query = `UPDATE users SET email_verified = TRUE WHERE id = $1 AND email = $2 RETURNING ` + userColumns
+
+	u, err := scanUser(tx.QueryRowContext(ctx, query, t.UserID, t.Email))
+	if errors.Is(err, sql.ErrNoRows) {
+		return user.User{}, userrepo.ErrVerificationTokenNotFound
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if err := storeEvents(ctx, tx, options.Events, u); err != nil {
+		return user.User{}, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+// SaveOneTimeToken replaces the token of a user in the db for the token's purpose
+func (ur *pgUserRepo) SaveOneTimeToken(ctx context.Context, token userrepo.OneTimeToken) error {
+	query := `INSERT INTO one_time_tokens (user_id, purpose, email, token_hash, expires_at) VALUES ($1, $2, $3, $4, $5)
+		ON CONFLICT (user_id, purpose) DO UPDATE SET email = EXCLUDED.email, token_hash = EXCLUDED.token_hash, expires_at = EXCLUDED.expires_at, created_at = now()`
+
+	if _, err := ur.conn.ExecContext(ctx, query, token.UserID, string(token.Purpose), token.Email, token.TokenHash, token.ExpiresAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetOneTimeToken retrieves the token of a user from the db for purpose
+func (ur *pgUserRepo) GetOneTimeToken(ctx context.Context, userID string, purpose userrepo.TokenPurpose) (userrepo.OneTimeToken, error) {
+	query := `SELECT ` + oneTimeTokenColumns + ` FROM one_time_tokens WHERE user_id = $1 AND purpose = $2`
+
+	return scanOneTimeToken(ur.conn.QueryRowContext(ctx, query, userID, string(purpose)))
+}
+
+// GetOneTimeTokenByHash retrieves a token from the db issued for purpose
+func (ur *pgUserRepo) GetOneTimeTokenByHash(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	query := `SELECT ` + oneTimeTokenColumns + ` FROM one_time_tokens WHERE token_hash = $1 AND purpose = $2`
+
+	return scanOneTimeToken(ur.conn.QueryRowContext(ctx, query, tokenHash, string(purpose)))
+}
+
+// ConsumeOneTimeToken deletes and returns a token in the db issued for purpose
+func (ur *pgUserRepo) ConsumeOneTimeToken(ctx context.Context, purpose userrepo.TokenPurpose, tokenHash string) (userrepo.OneTimeToken, error) {
+	query := `DELETE FROM one_time_tokens WHERE token_hash = $1 AND purpose = $2 RETURNING ` + oneTimeTokenColumns
+
+	return scanOneTimeToken(ur.conn.QueryRowContext(ctx, query, tokenHash, string(purpose)))
+}
+
+// scanOneTimeToken scans a row selected as oneTimeTokenColumns
+func scanOneTimeToken(row scanner) (userrepo.OneTimeToken, error) {
+	var t userrepo.OneTimeToken
+
+	if err := row.Scan(&t.UserID, &t.Purpose, &t.Email, &t.TokenHash, &t.ExpiresAt, &t.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.OneTimeToken{}, userrepo.ErrTokenNotFound
+		}
+		return userrepo.OneTimeToken{}, err
+	}
+
+	return t, nil
+}
+
+// SaveTOTP replaces the enrollment of a user in the db, dropping its recovery codes
+func (ur *pgUserRepo) SaveTOTP(ctx context.Context, totp userrepo.TOTP) error {
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	// deleting cascades to the recovery codes
+	if _, err := tx.ExecContext(ctx, `DELETE FROM totp WHERE user_id = $1`, totp.UserID); err != nil {
+		return err
+	}
+
+	query := `INSERT INTO totp (user_id, secret) VALUES ($1, $2)`
+
+	if _, err := tx.ExecContext(ctx, query, totp.UserID, totp.Secret); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetTOTP retrieves the enrollment of a user from the db
+func (ur *pgUserRepo) GetTOTP(ctx context.Context, userID string) (userrepo.TOTP, error) {
+	query := `SELECT user_id, secret, last_counter, failed_attempts, locked_until, confirmed_at, created_at FROM totp WHERE user_id = $1`
+
+	var t userrepo.TOTP
+	var lastCounter int64
+	var lockedUntil, confirmedAt sql.NullTime
+
+	if err := ur.conn.QueryRowContext(ctx, query, userID).Scan(&t.UserID, &t.Secret, &lastCounter, &t.FailedAttempts, &lockedUntil, &confirmedAt, &t.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.TOTP{}, userrepo.ErrTOTPNotFound
+		}
+		return userrepo.TOTP{}, err
+	}
+
+	t.LastCounter = uint64(lastCounter)
+
+	if lockedUntil.Valid {
+		t.LockedUntil = lockedUntil.Time
+	}
+
+	if confirmedAt.Valid {
+		t.ConfirmedAt = &confirmedAt.Time
+	}
+
+	return t, nil
+}
+
+// ConfirmTOTP enables the enrollment of a user in the db and stores their recovery codes
+func (ur *pgUserRepo) ConfirmTOTP(ctx context.Context, userID string, counter uint64, recoveryCodeHashes []string) error {
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE totp SET confirmed_at = now(), last_counter = $2 WHERE user_id = $1`
+
+	res, err := tx.ExecContext(ctx, query, userID, int64(counter))
+	if err != nil {
+		return err
+	}
+
+	if n, err := res.RowsAffected(); err != nil {
+		return err
+	} else if n == 0 {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `DELETE FROM recovery_codes WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	query = `INSERT INTO recovery_codes (user_id, code_hash) SELECT $1, unnest($2::TEXT[])`
+
+	if _, err := tx.ExecContext(ctx, query, userID, pq.StringArray(recoveryCodeHashes)); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// UseTOTPCounter accepts a counter in the db after the last one accepted
+func (ur *pgUserRepo) UseTOTPCounter(ctx context.Context, userID string, counter uint64) error {
+	query := `UPDATE totp SET last_counter = $2, failed_attempts = 0 WHERE user_id = $1 AND last_counter < $2`
+
+	res, err := ur.conn.ExecContext(ctx, query, userID, int64(counter))
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		// tell a replayed code from a missing enrollment
+		if _, err := ur.GetTOTP(ctx, userID); err != nil {
+			return err
+		}
+		return userrepo.ErrTOTPCodeUsed
+	}
+
+	return nil
+}
+
+// UseRecoveryCode marks an unused recovery code of a user in the db used
+func (ur *pgUserRepo) UseRecoveryCode(ctx context.Context, userID string, codeHash string) error {
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE recovery_codes SET used_at = now() WHERE user_id = $1 AND code_hash = $2 AND used_at IS NULL`
+
+	res, err := tx.ExecContext(ctx, query, userID, codeHash)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrRecoveryCodeNotFound
+	}
+
+	if _, err := tx.ExecContext(ctx, `UPDATE totp SET failed_attempts = 0 WHERE user_id = $1`, userID); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// FailTOTP counts an invalid code of a user in the db, locking their
+// enrollment until lockedUntil on the maxFailures-th in a row
+func (ur *pgUserRepo) FailTOTP(ctx context.Context, userID string, maxFailures int, lockedUntil time.Time) error {
+	// the right-hand sides see the row as it was
+	query := `UPDATE totp SET failed_attempts = CASE WHEN failed_attempts + 1 >= $2 THEN 0 ELSE failed_attempts + 1 END, locked_until = CASE WHEN failed_attempts + 1 >= $2 THEN $3 ELSE locked_until END WHERE user_id = $1`
+
+	res, err := ur.conn.ExecContext(ctx, query, userID, maxFailures, lockedUntil)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	return nil
+}
+
+// DeleteTOTP removes the enrollment of a user from the db, along with their recovery codes
+func (ur *pgUserRepo) DeleteTOTP(ctx context.Context, userID string) error {
+	res, err := ur.conn.ExecContext(ctx, `DELETE FROM totp WHERE user_id = $1`, userID)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrTOTPNotFound
+	}
+
+	return nil
+}
+
+// CreateAPIKey inserts a new API key into the db
+func (ur *pgUserRepo) CreateAPIKey(ctx context.Context, key userrepo.APIKey) error {
+	query := `INSERT INTO api_keys (id, user_id, name, prefix, key_hash, scopes, expires_at) VALUES ($1, $2, $3, $4, $5, $6, $7)`
+
+	scopes := pq.StringArray(key.Scopes)
+	if scopes == nil {
+		scopes = pq.StringArray{}
+	}
+
+	if _, err := ur.conn.ExecContext(ctx, query, key.ID, key.UserID, key.Name, key.Prefix, key.KeyHash, scopes, key.ExpiresAt); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrUserNotFound
+		}
+		return err
+	}
+
+	return nil
+}
+
+// GetAPIKey retrieves an API key from the db given its ID
+func (ur *pgUserRepo) GetAPIKey(ctx context.Context, id string) (userrepo.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE id = $1`
+
+	return scanAPIKey(ur.conn.QueryRowContext(ctx, query, id))
+}
+
+// GetAPIKeyByHash retrieves an API key from the db given the hash of the key
+func (ur *pgUserRepo) GetAPIKeyByHash(ctx context.Context, hash string) (userrepo.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE key_hash = $1`
+
+	return scanAPIKey(ur.conn.QueryRowContext(ctx, query, hash))
+}
+
+// ListAPIKeys retrieves the API keys of a user from the db, oldest first
+func (ur *pgUserRepo) ListAPIKeys(ctx context.Context, userID string) ([]userrepo.APIKey, error) {
+	query := `SELECT ` + apiKeyColumns + ` FROM api_keys WHERE user_id = $1 ORDER BY created_at, id`
+
+	rows, err := ur.conn.QueryContext(ctx, query, userID)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	keys := []userrepo.APIKey{}
+	for rows.Next() {
+		key, err := scanAPIKey(rows)
+		if err != nil {
+			return nil, err
+		}
+		keys = append(keys, key)
+	}
+
+	return keys, rows.Err()
+}
+
+// DeleteAPIKey removes an API key from the db given its ID
+func (ur *pgUserRepo) DeleteAPIKey(ctx context.Context, id string) error {
+	res, err := ur.conn.ExecContext(ctx, `DELETE FROM api_keys WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// TouchAPIKey records in the db when an API key was last used
+func (ur *pgUserRepo) TouchAPIKey(ctx context.Context, id string, at time.Time) error {
+	res, err := ur.conn.ExecContext(ctx, `UPDATE api_keys SET last_used_at = $2 WHERE id = $1`, id, at)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrAPIKeyNotFound
+	}
+
+	return nil
+}
+
+// EnqueueOutboxMessage inserts a message to be sent now into the db
+func (ur *pgUserRepo) EnqueueOutboxMessage(ctx context.Context, m userrepo.OutboxMessage) error {
+	return enqueueOutboxMessage(ctx, ur.conn, m)
+}
+
+// ClaimOutboxMessages leases up to limit due pending messages in the db.
+// Rows locked by another replica's claim are skipped rather than waited
+// on, and the lease keeps them from being claimed again while they are
+// sent, so replicas never send the same message at once.
+func (ur *pgUserRepo) ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]userrepo.OutboxMessage, error) {
+	query := `UPDATE outbox SET attempts = attempts + 1, next_attempt_at = now() + $2::BIGINT * INTERVAL '1 microsecond'
+	WHERE id IN (
+		SELECT id FROM outbox WHERE status = 'pending' AND next_attempt_at <= now()
+		ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+	)
+	RETURNING ` + outboxColumns
+
+	rows, err := ur.conn.QueryContext(ctx, query, limit, lease.Microseconds())
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	messages := []userrepo.OutboxMessage{}
+	for rows.Next() {
+		var m userrepo.OutboxMessage
+		if err := rows.Scan(&m.ID, &m.Kind, &m.Name, &m.Email, &m.Token, &m.Event, &m.Payload, &m.DeliveryID, &m.TraceParent, &m.Status, &m.Attempts, &m.LastError, &m.NextAttemptAt, &m.CreatedAt); err != nil {
+			return nil, err
+		}
+		messages = append(messages, m)
+	}
+
+	return messages, rows.Err()
+}
+
+// CompleteOutboxMessage removes a delivered message from the db
+func (ur *pgUserRepo) CompleteOutboxMessage(ctx context.Context, id string) error {
+	return expectOutboxRow(ur.conn.ExecContext(ctx, `DELETE FROM outbox WHERE id = $1`, id))
+}
+
+// RetryOutboxMessage reschedules a failed message in the db
+func (ur *pgUserRepo) RetryOutboxMessage(ctx context.Context, id string, lastErr string, at time.Time) error {
+	query := `UPDATE outbox SET last_error = $2, next_attempt_at = $3 WHERE id = $1`
+
+	return expectOutboxRow(ur.conn.ExecContext(ctx, query, id, lastErr, at))
+}
+
+// DeadLetterOutboxMessage stops retrying a failed message in the db
+func (ur *pgUserRepo) DeadLetterOutboxMessage(ctx context.Context, id string, lastErr string) error {
+	query := `UPDATE outbox SET last_error = $2, status = 'dead' WHERE id = $1`
+
+	return expectOutboxRow(ur.conn.ExecContext(ctx, query, id, lastErr))
+}
+
+// CreateWebhook inserts a new webhook into the db
+func (ur *pgUserRepo) CreateWebhook(ctx context.Context, webhook userrepo.Webhook) error {
+	query := `INSERT INTO webhooks (id, url, secret, events) VALUES ($1, $2, $3, $4)`
+
+	events := pq.StringArray(webhook.Events)
+	if events == nil {
+		events = pq.StringArray{}
+	}
+
+	_, err := ur.conn.ExecContext(ctx, query, webhook.ID, webhook.URL, webhook.Secret, events)
+
+	return err
+}
+
+// GetWebhook retrieves a webhook from the db given its ID
+func (ur *pgUserRepo) GetWebhook(ctx context.Context, id string) (userrepo.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks WHERE id = $1`
+
+	return scanWebhook(ur.conn.QueryRowContext(ctx, query, id))
+}
+
+// ListWebhooks retrieves every webhook from the db, oldest first
+func (ur *pgUserRepo) ListWebhooks(ctx context.Context) ([]userrepo.Webhook, error) {
+	query := `SELECT ` + webhookColumns + ` FROM webhooks ORDER BY created_at, id`
+
+	rows, err := ur.conn.QueryContext(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	webhooks := []userrepo.Webhook{}
+	for rows.Next() {
+		webhook, err := scanWebhook(rows)
+		if err != nil {
+			return nil, err
+		}
+		webhooks = append(webhooks, webhook)
+	}
+
+	return webhooks, rows.Err()
+}
+
+// DeleteWebhook removes a webhook from the db given its ID. Its
+// deliveries go with it.
+func (ur *pgUserRepo) DeleteWebhook(ctx context.Context, id string) error {
+	res, err := ur.conn.ExecContext(ctx, `DELETE FROM webhooks WHERE id = $1`, id)
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrWebhookNotFound
+	}
+
+	return nil
+}
+
+// CreateWebhookDelivery inserts a pending delivery and enqueues m in one transaction
+func (ur *pgUserRepo) CreateWebhookDelivery(ctx context.Context, delivery userrepo.WebhookDelivery, m userrepo.OutboxMessage) error {
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload) VALUES ($1, $2, $3, $4, $5)`
+
+	if _, err := tx.ExecContext(ctx, query, delivery.ID, delivery.WebhookID, delivery.EventID, delivery.Event, delivery.Payload); err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == foreignKeyViolation {
+			return userrepo.ErrWebhookNotFound
+		}
+		return err
+	}
+
+	if err := enqueueOutboxMessage(ctx, tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetWebhookDelivery retrieves a delivery from the db given its ID
+func (ur *pgUserRepo) GetWebhookDelivery(ctx context.Context, id string) (userrepo.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE id = $1`
+
+	return scanDelivery(ur.conn.QueryRowContext(ctx, query, id))
+}
+
+// ListWebhookDeliveries retrieves up to limit of a webhook's deliveries from the db, newest first
+func (ur *pgUserRepo) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]userrepo.WebhookDelivery, error) {
+	query := `SELECT ` + deliveryColumns + ` FROM webhook_deliveries WHERE webhook_id = $1 ORDER BY created_at DESC, id DESC LIMIT $2`
+
+	rows, err := ur.conn.QueryContext(ctx, query, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	deliveries := []userrepo.WebhookDelivery{}
+	for rows.Next() {
+		d, err := scanDelivery(rows)
+		if err != nil {
+			return nil, err
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+// RecordWebhookAttempts appends attempts to a delivery's log in the db and sets its status
+func (ur *pgUserRepo) RecordWebhookAttempts(ctx context.Context, id string, status userrepo.DeliveryStatus, attempts []userrepo.WebhookAttempt) error {
+	bs, err := json.Marshal(attempts)
+	if err != nil {
+		return err
+	}
+
+	query := `UPDATE webhook_deliveries SET status = $2, attempts = attempts || $3::JSONB, updated_at = now() WHERE id = $1`
+
+	return expectDeliveryRow(ur.conn.ExecContext(ctx, query, id, status, string(bs)))
+}
+
+// RedeliverWebhookDelivery makes a delivery in the db pending again and enqueues m in one transaction
+func (ur *pgUserRepo) RedeliverWebhookDelivery(ctx context.Context, id string, m userrepo.OutboxMessage) error {
+	tx, err := ur.conn.BeginTx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	query := `UPDATE webhook_deliveries SET status = 'pending', updated_at = now() WHERE id = $1`
+
+	if err := expectDeliveryRow(tx.ExecContext(ctx, query, id)); err != nil {
+		return err
+	}
+
+	if err := enqueueOutboxMessage(ctx, tx, m); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+type execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// enqueueOutboxMessage inserts m with db, which may be a transaction
+func enqueueOutboxMessage(ctx context.Context, db execer, m userrepo.OutboxMessage) error {
+	query := `INSERT INTO outbox (id, kind, name, email, token, event, payload, delivery_id, trace_parent) VALUES ($1, $2, $3, $4, $5, $6, $7, $8, $9)`
+
+	_, err := db.ExecContext(ctx, query, m.ID, m.Kind, m.Name, m.Email, m.Token, m.Event, m.Payload, m.DeliveryID, m.TraceParent)
+
+	return err
+}
+
+// storeEvents stores the events fns build from u with tx, dropping
+// deliveries to webhooks removed in the meantime along with their
+// message
+func storeEvents(ctx context.Context, tx *sql.Tx, fns []userrepo.EventFunc, u user.User) error {
+	query := `INSERT INTO webhook_deliveries (id, webhook_id, event_id, event, payload) SELECT $1, id, $3, $4, $5 FROM webhooks WHERE id = $2`
+
+	for _, fn := range fns {
+		e, err := fn(u)
+		if err != nil {
+			return err
+		}
+
+		for _, m := range e.Messages {
+			if err := enqueueOutboxMessage(ctx, tx, m); err != nil {
+				return err
+			}
+		}
+
+		for _, d := range e.Deliveries {
+			res, err := tx.ExecContext(ctx, query, d.Delivery.ID, d.Delivery.WebhookID, d.Delivery.EventID, d.Delivery.Event, d.Delivery.Payload)
+			if err != nil {
+				return err
+			}
+
+			n, err := res.RowsAffected()
+			if err != nil {
+				return err
+			}
+
+			if n == 0 {
+				continue
+			}
+
+			if err := enqueueOutboxMessage(ctx, tx, d.Message); err != nil {
+				return err
+			}
+		}
+	}
+
+	return nil
+}
+
+// expectOutboxRow maps an exec that touched no rows to ErrOutboxMessageNotFound
+func expectOutboxRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrOutboxMessageNotFound
+	}
+
+	return nil
+}
+
+// expectOneRow maps an update that touched no rows onto ErrSessionNotFound
+func expectOneRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrSessionNotFound
+	}
+
+	return nil
+}
+
+// scanSession scans a row selected as sessionColumns
+func scanSession(row scanner) (userrepo.Session, error) {
+	var s userrepo.Session
+	var revokedAt sql.NullTime
+
+	if err := row.Scan(&s.ID, &s.UserID, &s.RefreshTokenHash, &s.ExpiresAt, &revokedAt, &s.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.Session{}, userrepo.ErrSessionNotFound
+		}
+		return userrepo.Session{}, err
+	}
+
+	if revokedAt.Valid {
+		s.RevokedAt = &revokedAt.Time
+	}
+
+	return s, nil
+}
+
+// scanAPIKey scans a row selected as apiKeyColumns
+func scanAPIKey(row scanner) (userrepo.APIKey, error) {
+	var k userrepo.APIKey
+	var scopes pq.StringArray
+	var lastUsedAt sql.NullTime
+
+	if err := row.Scan(&k.ID, &k.UserID, &k.Name, &k.Prefix, &k.KeyHash, &scopes, &k.ExpiresAt, &lastUsedAt, &k.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.APIKey{}, userrepo.ErrAPIKeyNotFound
+		}
+		return userrepo.APIKey{}, err
+	}
+
+	k.Scopes = []string(scopes)
+
+	if lastUsedAt.Valid {
+		k.LastUsedAt = &lastUsedAt.Time
+	}
+
+	return k, nil
+}
+
+func scanWebhook(row scanner) (userrepo.Webhook, error) {
+	var w userrepo.Webhook
+	var events pq.StringArray
+
+	if err := row.Scan(&w.ID, &w.URL, &w.Secret, &events, &w.CreatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.Webhook{}, userrepo.ErrWebhookNotFound
+		}
+		return userrepo.Webhook{}, err
+	}
+
+	w.Events = []string(events)
+
+	return w, nil
+}
+
+func scanDelivery(row scanner) (userrepo.WebhookDelivery, error) {
+	var d userrepo.WebhookDelivery
+	var attempts []byte
+
+	if err := row.Scan(&d.ID, &d.WebhookID, &d.EventID, &d.Event, &d.Payload, &d.Status, &attempts, &d.CreatedAt, &d.UpdatedAt); err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return userrepo.WebhookDelivery{}, userrepo.ErrWebhookDeliveryNotFound
+		}
+		return userrepo.WebhookDelivery{}, err
+	}
+
+	if err := json.Unmarshal(attempts, &d.Attempts); err != nil {
+		return userrepo.WebhookDelivery{}, err
+	}
+
+	return d, nil
+}
+
+// expectDeliveryRow maps an exec that touched no rows to ErrWebhookDeliveryNotFound
+func expectDeliveryRow(res sql.Result, err error) error {
+	if err != nil {
+		return err
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+
+	if n == 0 {
+		return userrepo.ErrWebhookDeliveryNotFound
+	}
+
+	return nil
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+// scanUser scans a row selected as userColumns
+func scanUser(row scanner) (user.User, error) {
+	var u user.User
+	var roles pq.StringArray
+
+	if err := row.Scan(&u.ID, &u.Name, &u.Email, &roles, &u.EmailVerified, &u.CreatedAt); err != nil {
+		return user.User{}, err
+	}
+
+	for _, role := range roles {
+		u.Roles = append(u.Roles, user.Role(role))
+	}
+
+	return u, nil
+}
+
+// escapeLike escapes LIKE wildcards so user input matches literally
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(s)
+}
+
+// Check pings the database, giving up after the ping timeout
+func (ur *pgUserRepo) Check(ctx context.Context) error {
+	ctx, cancel := context.WithTimeout(ctx, pingTimeout(ur.options))
+	defer cancel()
+
+	return ur.conn.PingContext(ctx)
+}
+
+// Close closes the connection pool once queries in flight finish,
+// or returns when ctx is done, leaving them to finish on their own
+func (ur *pgUserRepo) Close(ctx context.Context) error {
+	done := make(chan error, 1)
+	go func() {
+		done <- ur.conn.Close()
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// NewUserRepo creates a new pgUserRepo
+func NewUserRepo(opts ...userrepo.Option) (userrepo.UserRepo, error) {
+	options := userrepo.NewOptions(opts...)
+
+	ur := &pgUserRepo{
+		options: options,
+	}
+
+	conn, err := connect(ur.options)
+	if err != nil {
+		return nil, err
+	}
+
+	if reg, ok := getRegistererFromCtx(ur.options.Context); ok && reg != nil {
+		if err := reg.Register(collectors.NewDBStatsCollector(conn, "users")); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("failed to register connection pool metrics: %w", err)
+		}
+	}
+
+	ur.conn = conn
+
+	version, err := schemaVersion(ur.options.Context, ur.conn)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to read schema version: %w", err)
+	}
 
-	if _, err := ur.conn.Exec(query); err != nil {
-		// log
-		panic(err)
+	if latest := LatestVersion(); version != latest {
+		conn.Close()
+		return nil, fmt.Errorf("%w: database is at version %d, expected %d (run `demo-go migrate up`)", ErrSchemaOutOfDate, version, latest)
 	}
 
-	return ur
+	return ur, nil
 }
@@ -0,0 +1,19 @@
+package userrepo
+
+import "time"
+
+// Session is a login, presented by clients as its refresh token.
+// Only a hash of the refresh token is stored.
+type Session struct {
+	ID               string
+	UserID           string
+	RefreshTokenHash string
+	ExpiresAt        time.Time
+	RevokedAt        *time.Time
+	CreatedAt        time.Time
+}
+
+// Active reports whether the session can still be used at now
+func (s Session) Active(now time.Time) bool {
+	return s.RevokedAt == nil && now.Before(s.ExpiresAt)
+}
@@ -0,0 +1,32 @@
+package userrepo
+
+import "time"
+
+// TOTP is a user's authenticator app enrollment. It only protects
+// logins once confirmed with a first code.
+type TOTP struct {
+	UserID string
+	Secret string
+	// LastCounter is the counter of the last code accepted, so
+	// codes can't be replayed
+	LastCounter uint64
+	// FailedAttempts counts invalid codes and recovery codes since
+	// the last valid one or the last lockout
+	FailedAttempts int
+	// LockedUntil is when a lockout after too many invalid codes
+	// ends, or zero
+	LockedUntil time.Time
+	ConfirmedAt *time.Time
+	CreatedAt   time.Time
+}
+
+// Enabled reports whether the enrollment has been confirmed
+func (t TOTP) Enabled() bool {
+	return t.ConfirmedAt != nil
+}
+
+// Locked reports whether codes are refused at now after too many
+// invalid ones
+func (t TOTP) Locked(now time.Time) bool {
+	return now.Before(t.LockedUntil)
+}
@@ -2,14 +2,106 @@ package userrepo
 
 import (
 	"context"
+	"time"
 
 	"github.com/w-h-a/demo-go/api/user"
 )
 
 // UserRepo is the interface for our user data store.
 type UserRepo interface {
-	Create(ctx context.Context, dto user.CreateUserDTO) (user.User, error)
+	Create(ctx context.Context, dto user.CreateUserDTO, opts ...CreateOption) (user.User, error)
 	GetByID(ctx context.Context, id string) (user.User, error)
 	GetByEmail(ctx context.Context, email string) (user.User, error)
 	GetAll(ctx context.Context, opts ...GetAllOption) ([]user.User, error)
+	Update(ctx context.Context, id string, dto user.UpdateUserDTO, opts ...ChangeOption) (user.User, error)
+	Delete(ctx context.Context, id string, opts ...ChangeOption) error
+	GetPasswordHash(ctx context.Context, userID string) (string, error)
+	CreateSession(ctx context.Context, session Session) error
+	GetSession(ctx context.Context, id string) (Session, error)
+	GetSessionByRefreshTokenHash(ctx context.Context, hash string) (Session, error)
+	// RotateSession swaps the refresh token hash of a live session,
+	// failing with ErrSessionNotFound if oldHash was already rotated.
+	RotateSession(ctx context.Context, id string, oldHash string, newHash string, expiresAt time.Time) error
+	RevokeSession(ctx context.Context, id string) error
+	// RevokeUserSessions revokes every session of the user.
+	RevokeUserSessions(ctx context.Context, userID string) error
+	// SetPasswordHash creates or replaces the user's credentials.
+	SetPasswordHash(ctx context.Context, userID string, hash string) error
+	// SaveVerificationToken replaces the user's verification token.
+	SaveVerificationToken(ctx context.Context, token VerificationToken) error
+	GetVerificationToken(ctx context.Context, userID string) (VerificationToken, error)
+	// VerifyEmail consumes the token with tokenHash and marks the
+	// address it was sent to verified. The token is used up even
+	// when it has expired.
+	VerifyEmail(ctx context.Context, tokenHash string, now time.Time, opts ...ChangeOption) (user.User, error)
+	// SaveOneTimeToken replaces the user's token for token.Purpose.
+	SaveOneTimeToken(ctx context.Context, token OneTimeToken) error
+	GetOneTimeToken(ctx context.Context, userID string, purpose TokenPurpose) (OneTimeToken, error)
+	// GetOneTimeTokenByHash returns the token with tokenHash, provided
+	// it was issued for purpose, leaving it to be consumed.
+	GetOneTimeTokenByHash(ctx context.Context, purpose TokenPurpose, tokenHash string) (OneTimeToken, error)
+	// ConsumeOneTimeToken deletes and returns the token with tokenHash,
+	// provided it was issued for purpose.
+	ConsumeOneTimeToken(ctx context.Context, purpose TokenPurpose, tokenHash string) (OneTimeToken, error)
+	// SaveTOTP replaces the user's enrollment, and its recovery codes.
+	SaveTOTP(ctx context.Context, totp TOTP) error
+	GetTOTP(ctx context.Context, userID string) (TOTP, error)
+	// ConfirmTOTP enables the user's enrollment, accepting counter
+	// and storing the recovery code hashes.
+	ConfirmTOTP(ctx context.Context, userID string, counter uint64, recoveryCodeHashes []string) error
+	// UseTOTPCounter accepts counter, failing with ErrTOTPCodeUsed
+	// unless it is after the last one accepted.
+	UseTOTPCounter(ctx context.Context, userID string, counter uint64) error
+	// UseRecoveryCode marks an unused recovery code used.
+	UseRecoveryCode(ctx context.Context, userID string, codeHash string) error
+	// FailTOTP counts an invalid code or recovery code. The
+	// maxFailures-th in a row locks the enrollment until lockedUntil
+	// and starts the count again. UseTOTPCounter and UseRecoveryCode
+	// start it again too.
+	FailTOTP(ctx context.Context, userID string, maxFailures int, lockedUntil time.Time) error
+	DeleteTOTP(ctx context.Context, userID string) error
+	CreateAPIKey(ctx context.Context, key APIKey) error
+	GetAPIKey(ctx context.Context, id string) (APIKey, error)
+	GetAPIKeyByHash(ctx context.Context, hash string) (APIKey, error)
+	// ListAPIKeys returns the user's keys, oldest first.
+	ListAPIKeys(ctx context.Context, userID string) ([]APIKey, error)
+	DeleteAPIKey(ctx context.Context, id string) error
+	// TouchAPIKey records the key was used at.
+	TouchAPIKey(ctx context.Context, id string, at time.Time) error
+	// EnqueueOutboxMessage stores a message to be sent now.
+	EnqueueOutboxMessage(ctx context.Context, m OutboxMessage) error
+	// ClaimOutboxMessages returns up to limit pending messages that
+	// are due, counting an attempt for each and hiding them from
+	// other claims for lease. Concurrent claims never return the
+	// same message.
+	ClaimOutboxMessages(ctx context.Context, limit int, lease time.Duration) ([]OutboxMessage, error)
+	// CompleteOutboxMessage removes a delivered message.
+	CompleteOutboxMessage(ctx context.Context, id string) error
+	// RetryOutboxMessage records why a message failed and when to try again.
+	RetryOutboxMessage(ctx context.Context, id string, lastErr string, at time.Time) error
+	// DeadLetterOutboxMessage records why a message failed and stops retrying it.
+	DeadLetterOutboxMessage(ctx context.Context, id string, lastErr string) error
+	CreateWebhook(ctx context.Context, webhook Webhook) error
+	GetWebhook(ctx context.Context, id string) (Webhook, error)
+	// ListWebhooks returns every webhook, oldest first.
+	ListWebhooks(ctx context.Context) ([]Webhook, error)
+	// DeleteWebhook deletes the webhook and its deliveries.
+	DeleteWebhook(ctx context.Context, id string) error
+	// CreateWebhookDelivery stores a pending delivery, along with the
+	// outbox message that drives it, so neither is stored without the other.
+	CreateWebhookDelivery(ctx context.Context, delivery WebhookDelivery, m OutboxMessage) error
+	GetWebhookDelivery(ctx context.Context, id string) (WebhookDelivery, error)
+	// ListWebhookDeliveries returns up to limit of the webhook's
+	// deliveries, newest first.
+	ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) ([]WebhookDelivery, error)
+	// RecordWebhookAttempts appends attempts to the delivery's log
+	// and sets its status.
+	RecordWebhookAttempts(ctx context.Context, id string, status DeliveryStatus, attempts []WebhookAttempt) error
+	// RedeliverWebhookDelivery makes the delivery pending again, along
+	// with the outbox message that drives it.
+	RedeliverWebhookDelivery(ctx context.Context, id string, m OutboxMessage) error
+	// Check returns why the store can't be reached, or nil if it can.
+	Check(ctx context.Context) error
+	// Close releases the store's resources, giving up when ctx is done.
+	Close(ctx context.Context) error
 }
@@ -0,0 +1,13 @@
+package userrepo
+
+import "time"
+
+// VerificationToken proves control of Email, the address it was sent
+// to. A user has at most one, and only a hash of it is stored.
+type VerificationToken struct {
+	UserID    string
+	Email     string
+	TokenHash string
+	ExpiresAt time.Time
+	CreatedAt time.Time
+}
@@ -0,0 +1,55 @@
+package userrepo
+
+import (
+	"slices"
+	"time"
+)
+
+// Webhook is a subscription to events, posted to URL. Secret is kept
+// in the clear, as requests are signed with it. An empty Events
+// subscribes to every event.
+type Webhook struct {
+	ID        string
+	URL       string
+	Secret    string
+	Events    []string
+	CreatedAt time.Time
+}
+
+// Subscribed reports whether event should be posted to the webhook
+func (w Webhook) Subscribed(event string) bool {
+	return len(w.Events) == 0 || slices.Contains(w.Events, event)
+}
+
+// DeliveryStatus is where a webhook delivery is.
+type DeliveryStatus string
+
+const (
+	DeliveryPending   DeliveryStatus = "pending"
+	DeliverySucceeded DeliveryStatus = "succeeded"
+	// DeliveryFailed deliveries ran out of attempts, and are
+	// only tried again when redelivered.
+	DeliveryFailed DeliveryStatus = "failed"
+)
+
+// WebhookDelivery is an event's delivery to a webhook, with a log of
+// the requests made for it. The outbox drives the attempts.
+type WebhookDelivery struct {
+	ID        string
+	WebhookID string
+	EventID   string
+	Event     string
+	Payload   []byte
+	// Status, Attempts and UpdatedAt are kept by the repo
+	Status    DeliveryStatus
+	Attempts  []WebhookAttempt
+	CreatedAt time.Time
+	UpdatedAt time.Time
+}
+
+// WebhookAttempt is one request made for a delivery
+type WebhookAttempt struct {
+	At         time.Time `json:"at"`
+	StatusCode int       `json:"status_code,omitempty"`
+	Error      string    `json:"error,omitempty"`
+}
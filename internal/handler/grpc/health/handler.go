@@ -0,0 +1,103 @@
+package health
+
+import (
+	"context"
+	"time"
+
+	healthregistry "github.com/w-h-a/demo-go/internal/health"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+// watchInterval is how often watched services are checked again
+const watchInterval = 5 * time.Second
+
+// healthHandler is the standard gRPC health service, answered from
+// the health registry. The empty service is readiness, and every
+// registered component can be asked about by name.
+type healthHandler struct {
+	healthpb.UnimplementedHealthServer
+	registry *healthregistry.Registry
+}
+
+// Check handles the grpc.health.v1.Health/Check rpc.
+func (h *healthHandler) Check(ctx context.Context, req *healthpb.HealthCheckRequest) (*healthpb.HealthCheckResponse, error) {
+	st, ok := h.status(ctx, req.GetService())
+	if !ok {
+		return nil, status.Error(codes.NotFound, "unknown service")
+	}
+
+	return &healthpb.HealthCheckResponse{Status: st}, nil
+}
+
+// List handles the grpc.health.v1.Health/List rpc.
+func (h *healthHandler) List(ctx context.Context, req *healthpb.HealthListRequest) (*healthpb.HealthListResponse, error) {
+	statuses := map[string]*healthpb.HealthCheckResponse{}
+
+	for _, name := range append([]string{""}, h.registry.Names()...) {
+		if st, ok := h.status(ctx, name); ok {
+			statuses[name] = &healthpb.HealthCheckResponse{Status: st}
+		}
+	}
+
+	return &healthpb.HealthListResponse{Statuses: statuses}, nil
+}
+
+// Watch handles the grpc.health.v1.Health/Watch rpc, sending the
+// status of the service whenever it changes, starting with the
+// current one.
+func (h *healthHandler) Watch(req *healthpb.HealthCheckRequest, stream grpc.ServerStreamingServer[healthpb.HealthCheckResponse]) error {
+	ticker := time.NewTicker(watchInterval)
+	defer ticker.Stop()
+
+	last := healthpb.HealthCheckResponse_ServingStatus(-1)
+
+	for {
+		st, ok := h.status(stream.Context(), req.GetService())
+		if !ok {
+			st = healthpb.HealthCheckResponse_SERVICE_UNKNOWN
+		}
+
+		if st != last {
+			if err := stream.Send(&healthpb.HealthCheckResponse{Status: st}); err != nil {
+				return err
+			}
+			last = st
+		}
+
+		select {
+		case <-stream.Context().Done():
+			return status.Error(codes.Canceled, "stream has ended")
+		case <-ticker.C:
+		}
+	}
+}
+
+// status checks readiness for the empty service, and the named
+// component otherwise, returning false if there is no such component
+func (h *healthHandler) status(ctx context.Context, service string) (healthpb.HealthCheckResponse_ServingStatus, bool) {
+	if len(service) == 0 {
+		return toServingStatus(h.registry.Ready(ctx).OK()), true
+	}
+
+	c, ok := h.registry.Component(ctx, service)
+	if !ok {
+		return healthpb.HealthCheckResponse_UNKNOWN, false
+	}
+
+	return toServingStatus(c.Status != healthregistry.StatusDown), true
+}
+
+func toServingStatus(ok bool) healthpb.HealthCheckResponse_ServingStatus {
+	if ok {
+		return healthpb.HealthCheckResponse_SERVING
+	}
+
+	return healthpb.HealthCheckResponse_NOT_SERVING
+}
+
+func New(r *healthregistry.Registry) *healthHandler {
+	return &healthHandler{registry: r}
+}
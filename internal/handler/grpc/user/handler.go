@@ -0,0 +1,145 @@
+package user
+
+import (
+	"context"
+	"errors"
+
+	"github.com/w-h-a/demo-go/api/user"
+	userv1 "github.com/w-h-a/demo-go/api/user/v1"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+)
+
+// userHandler is the gRPC handler for user-related requests.
+// Like its HTTP sibling, it is "trivial" code, responsible for:
+// 1. Translating protobuf requests into DTOs
+// 2. Calling the service layer
+// 3. Translating results (or errors) into protobuf responses and status codes
+type userHandler struct {
+	userv1.UnimplementedUserServiceServer
+	service *userservice.Service
+}
+
+// CreateUser handles the user.v1.UserService/CreateUser rpc.
+func (h *userHandler) CreateUser(ctx context.Context, req *userv1.CreateUserRequest) (*userv1.CreateUserResponse, error) {
+	dto := user.CreateUserDTO{
+		Name:  req.GetName(),
+		Email: req.GetEmail(),
+	}
+
+	u, err := h.service.CreateUser(ctx, dto)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.CreateUserResponse{User: toProto(u)}, nil
+}
+
+// GetUser handles the user.v1.UserService/GetUser rpc.
+func (h *userHandler) GetUser(ctx context.Context, req *userv1.GetUserRequest) (*userv1.GetUserResponse, error) {
+	u, err := h.service.GetUser(ctx, req.GetId())
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	return &userv1.GetUserResponse{User: toProto(u)}, nil
+}
+
+// ListUsers handles the user.v1.UserService/ListUsers rpc.
+func (h *userHandler) ListUsers(ctx context.Context, req *userv1.ListUsersRequest) (*userv1.ListUsersResponse, error) {
+	dto := user.ListUsersDTO{
+		Limit:  int(req.GetLimit()),
+		Cursor: req.GetCursor(),
+		Sort:   req.GetSort(),
+		Name:   req.GetName(),
+		Email:  req.GetEmail(),
+		// nil when unset, which doesn't filter
+		EmailVerified: req.EmailVerified,
+	}
+
+	page, err := h.service.GetAllUsers(ctx, dto)
+	if err != nil {
+		return nil, toStatus(err)
+	}
+
+	rsp := &userv1.ListUsersResponse{NextCursor: page.NextCursor}
+
+	for _, u := range page.Users {
+		rsp.Users = append(rsp.Users, toProto(u))
+	}
+
+	return rsp, nil
+}
+
+// StreamUsers handles the user.v1.UserService/StreamUsers rpc.
+func (h *userHandler) StreamUsers(req *userv1.StreamUsersRequest, stream grpc.ServerStreamingServer[userv1.User]) error {
+	dto := user.ListUsersDTO{
+		Limit:         userservice.MaxPageSize,
+		Sort:          req.GetSort(),
+		Name:          req.GetName(),
+		Email:         req.GetEmail(),
+		EmailVerified: req.EmailVerified,
+	}
+
+	for {
+		page, err := h.service.GetAllUsers(stream.Context(), dto)
+		if err != nil {
+			return toStatus(err)
+		}
+
+		for _, u := range page.Users {
+			if err := stream.Send(toProto(u)); err != nil {
+				return err
+			}
+		}
+
+		if page.NextCursor == "" {
+			return nil
+		}
+
+		dto.Cursor = page.NextCursor
+	}
+}
+
+func toProto(u user.User) *userv1.User {
+	pu := &userv1.User{
+		Id:            u.ID,
+		Name:          u.Name,
+		Email:         u.Email,
+		CreatedAt:     timestamppb.New(u.CreatedAt),
+		EmailVerified: u.EmailVerified,
+	}
+
+	for _, role := range u.Roles {
+		pu.Roles = append(pu.Roles, string(role))
+	}
+
+	return pu
+}
+
+// toStatus maps service errors onto gRPC status codes
+func toStatus(err error) error {
+	switch {
+	case errors.Is(err, userservice.ErrUserNotFound):
+		return status.Error(codes.NotFound, "User not found")
+	case errors.Is(err, userservice.ErrEmailInUse):
+		return status.Error(codes.AlreadyExists, err.Error())
+	case errors.Is(err, userservice.ErrForbidden):
+		return status.Error(codes.PermissionDenied, "Forbidden")
+	case errors.Is(err, userservice.ErrInvalidInput), errors.Is(err, userservice.ErrInvalidListQuery):
+		return status.Error(codes.InvalidArgument, err.Error())
+	case errors.Is(err, context.Canceled):
+		return status.Error(codes.Canceled, err.Error())
+	case errors.Is(err, context.DeadlineExceeded):
+		return status.Error(codes.DeadlineExceeded, err.Error())
+	default:
+		return status.Error(codes.Internal, "Internal server error")
+	}
+}
+
+func New(s *userservice.Service) *userHandler {
+	return &userHandler{service: s}
+}
@@ -0,0 +1,92 @@
+package apikey
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"github.com/w-h-a/demo-go/api/user"
+	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+// apiKeyHandler is the HTTP handler for API key requests.
+type apiKeyHandler struct {
+	service *userservice.Service
+}
+
+// CreateAPIKey handles the HTTP POST /api/keys request.
+func (h *apiKeyHandler) CreateAPIKey(w http.ResponseWriter, r *http.Request) {
+	var dto user.CreateAPIKeyDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	key, err := h.service.CreateAPIKey(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidAPIKey) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "CreateAPIKey", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusCreated, key)
+}
+
+// ListAPIKeys handles the HTTP GET /api/keys request.
+// The user_id query parameter defaults to the caller.
+func (h *apiKeyHandler) ListAPIKeys(w http.ResponseWriter, r *http.Request) {
+	keys, err := h.service.ListAPIKeys(r.Context(), r.URL.Query().Get("user_id"))
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ListAPIKeys", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, keys)
+}
+
+// DeleteAPIKey handles the HTTP DELETE /api/keys/{id} request.
+func (h *apiKeyHandler) DeleteAPIKey(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.service.DeleteAPIKey(r.Context(), id); err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrAPIKeyNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "API key not found")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "DeleteAPIKey", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func New(s *userservice.Service) *apiKeyHandler {
+	return &apiKeyHandler{service: s}
+}
@@ -0,0 +1,220 @@
+package auth
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+
+	"github.com/w-h-a/demo-go/api/user"
+	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+// authHandler is the HTTP handler for session requests.
+type authHandler struct {
+	service *userservice.Service
+}
+
+// Login handles the HTTP POST /api/auth/login request.
+func (h *authHandler) Login(w http.ResponseWriter, r *http.Request) {
+	var dto user.LoginDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := h.service.Login(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrInvalidCredentials) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "Invalid email or password")
+			return
+		}
+		if errors.Is(err, userservice.ErrEmailNotVerified) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Email not verified")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPRequired) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "TOTP code required")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidTOTPCode) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "Invalid TOTP code")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPLocked) {
+			httphandler.WrtErr(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "Login", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusOK, tokens)
+}
+
+// Refresh handles the HTTP POST /api/auth/refresh request.
+func (h *authHandler) Refresh(w http.ResponseWriter, r *http.Request) {
+	var dto user.RefreshDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := h.service.Refresh(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrInvalidCredentials) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "Invalid refresh token")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "Refresh", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusOK, tokens)
+}
+
+// Logout handles the HTTP POST /api/auth/logout request.
+func (h *authHandler) Logout(w http.ResponseWriter, r *http.Request) {
+	var dto user.RefreshDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.Logout(r.Context(), dto); err != nil {
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "Logout", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestPasswordReset handles the HTTP POST /api/auth/password-reset request.
+// It answers 202 whether or not the email belongs to a user.
+func (h *authHandler) RequestPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var dto user.PasswordResetRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.RequestPasswordReset(r.Context(), dto); err != nil {
+		if errors.Is(err, userservice.ErrInvalidInput) {
+			httphandler.WrtErr(w, http.StatusBadRequest, "Email is required")
+			return
+		}
+		if errors.Is(err, userservice.ErrBusy) {
+			httphandler.WrtErr(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "RequestPasswordReset", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// ConfirmPasswordReset handles the HTTP POST /api/auth/password-reset/confirm request.
+func (h *authHandler) ConfirmPasswordReset(w http.ResponseWriter, r *http.Request) {
+	var dto user.PasswordResetConfirmDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.ConfirmPasswordReset(r.Context(), dto); err != nil {
+		if errors.Is(err, userservice.ErrInvalidToken) || errors.Is(err, userservice.ErrInvalidPassword) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ConfirmPasswordReset", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// RequestMagicLink handles the HTTP POST /api/auth/magic-link request.
+// It answers 202 whether or not the email belongs to a user.
+func (h *authHandler) RequestMagicLink(w http.ResponseWriter, r *http.Request) {
+	var dto user.MagicLinkRequestDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.RequestMagicLink(r.Context(), dto); err != nil {
+		if errors.Is(err, userservice.ErrMagicLinkDisabled) {
+			httphandler.WrtErr(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidInput) {
+			httphandler.WrtErr(w, http.StatusBadRequest, "Email is required")
+			return
+		}
+		if errors.Is(err, userservice.ErrBusy) {
+			httphandler.WrtErr(w, http.StatusServiceUnavailable, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "RequestMagicLink", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// LoginWithMagicLink handles the HTTP POST /api/auth/magic-link/login request.
+func (h *authHandler) LoginWithMagicLink(w http.ResponseWriter, r *http.Request) {
+	var dto user.MagicLinkLoginDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	tokens, err := h.service.LoginWithMagicLink(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrMagicLinkDisabled) {
+			httphandler.WrtErr(w, http.StatusNotFound, "Not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidToken) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "Invalid or expired token")
+			return
+		}
+		if errors.Is(err, userservice.ErrEmailNotVerified) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Email not verified")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPRequired) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "TOTP code required")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidTOTPCode) {
+			httphandler.WrtErr(w, http.StatusUnauthorized, "Invalid TOTP code")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPLocked) {
+			httphandler.WrtErr(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "LoginWithMagicLink", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusOK, tokens)
+}
+
+func New(s *userservice.Service) *authHandler {
+	return &authHandler{service: s}
+}
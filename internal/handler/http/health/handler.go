@@ -0,0 +1,46 @@
+package health
+
+import (
+	"net/http"
+
+	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	healthregistry "github.com/w-h-a/demo-go/internal/health"
+)
+
+// healthHandler is the HTTP handler for the probes of orchestrators.
+// Reports are answered with 200, or 503 once they are down.
+type healthHandler struct {
+	registry *healthregistry.Registry
+}
+
+// Live handles the HTTP GET /livez request. It checks nothing, as
+// answering at all shows the process is alive.
+func (h *healthHandler) Live(w http.ResponseWriter, r *http.Request) {
+	write(w, healthregistry.Report{Status: healthregistry.StatusUp})
+}
+
+// Health handles the HTTP GET /healthz request, reporting on every
+// component.
+func (h *healthHandler) Health(w http.ResponseWriter, r *http.Request) {
+	write(w, h.registry.Health(r.Context()))
+}
+
+// Ready handles the HTTP GET /readyz request, which is Health until
+// shutdown starts, and down from then on.
+func (h *healthHandler) Ready(w http.ResponseWriter, r *http.Request) {
+	write(w, h.registry.Ready(r.Context()))
+}
+
+func write(w http.ResponseWriter, report healthregistry.Report) {
+	code := http.StatusOK
+	if !report.OK() {
+		code = http.StatusServiceUnavailable
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, code, report)
+}
+
+func New(r *healthregistry.Registry) *healthHandler {
+	return &healthHandler{registry: r}
+}
@@ -3,12 +3,13 @@ package user
 import (
 	"encoding/json"
 	"errors"
-	"log"
 	"net/http"
+	"strconv"
 
 	"github.com/gorilla/mux"
 	"github.com/w-h-a/demo-go/api/user"
 	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
 	userservice "github.com/w-h-a/demo-go/internal/service/user"
 )
 
@@ -31,11 +32,15 @@ func (h *userHandler) CreateUser(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.CreateUser(r.Context(), dto)
 	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
 		if errors.Is(err, userservice.ErrEmailInUse) {
 			httphandler.WrtErr(w, http.StatusConflict, err.Error())
 			return
 		}
-		if errors.Is(err, userservice.ErrInvalidInput) {
+		if errors.Is(err, userservice.ErrInvalidInput) || errors.Is(err, userservice.ErrInvalidPassword) {
 			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
 			return
 		}
@@ -53,6 +58,10 @@ func (h *userHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 
 	user, err := h.service.GetUser(r.Context(), id)
 	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
 		if errors.Is(err, userservice.ErrUserNotFound) {
 			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
 			return
@@ -64,16 +73,298 @@ func (h *userHandler) GetUserByID(w http.ResponseWriter, r *http.Request) {
 	httphandler.WrtJSON(w, http.StatusOK, user)
 }
 
-// GetAllUsers handles the HTTP GET /api/users request.
+// GetAllUsers handles the HTTP GET /api/users?limit=&cursor=&sort=&name=&email=&email_verified= request.
 func (h *userHandler) GetAllUsers(w http.ResponseWriter, r *http.Request) {
-	users, err := h.service.GetAllUsers(r.Context())
+	query := r.URL.Query()
+
+	dto := user.ListUsersDTO{
+		Cursor: query.Get("cursor"),
+		Sort:   query.Get("sort"),
+		Name:   query.Get("name"),
+		Email:  query.Get("email"),
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		n, err := strconv.Atoi(limit)
+		if err != nil {
+			httphandler.WrtErr(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		dto.Limit = n
+	}
+
+	if verified := query.Get("email_verified"); verified != "" {
+		b, err := strconv.ParseBool(verified)
+		if err != nil {
+			httphandler.WrtErr(w, http.StatusBadRequest, "Invalid email_verified")
+			return
+		}
+		dto.EmailVerified = &b
+	}
+
+	page, err := h.service.GetAllUsers(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidListQuery) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "GetAllUsers", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, page)
+}
+
+// UpdateUser handles the HTTP PUT /api/users/{id} request.
+func (h *userHandler) UpdateUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var dto user.UpdateUserDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.service.UpdateUser(r.Context(), id, dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrEmailInUse) {
+			httphandler.WrtErr(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidInput) || errors.Is(err, userservice.ErrInvalidRole) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, user)
+}
+
+// PatchUser handles the HTTP PATCH /api/users/{id} request.
+func (h *userHandler) PatchUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var dto user.PatchUserDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.service.PatchUser(r.Context(), id, dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrEmailInUse) {
+			httphandler.WrtErr(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidInput) || errors.Is(err, userservice.ErrInvalidRole) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, user)
+}
+
+// DeleteUser handles the HTTP DELETE /api/users/{id} request.
+func (h *userHandler) DeleteUser(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.service.DeleteUser(r.Context(), id); err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// VerifyEmail handles the HTTP POST /api/users/verify request.
+func (h *userHandler) VerifyEmail(w http.ResponseWriter, r *http.Request) {
+	var dto user.VerifyEmailDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	user, err := h.service.VerifyEmail(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrInvalidVerificationToken) || errors.Is(err, userservice.ErrVerificationTokenExpired) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "VerifyEmail", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, user)
+}
+
+// ResendVerification handles the HTTP POST /api/users/{id}/verify/resend request.
+func (h *userHandler) ResendVerification(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.service.ResendVerification(r.Context(), id); err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrEmailAlreadyVerified) {
+			httphandler.WrtErr(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrVerificationThrottled) {
+			httphandler.WrtErr(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ResendVerification", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusAccepted)
+}
+
+// EnrollTOTP handles the HTTP POST /api/users/{id}/totp request.
+func (h *userHandler) EnrollTOTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	enrollment, err := h.service.EnrollTOTP(r.Context(), id)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrUserNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "User not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPAlreadyEnabled) {
+			httphandler.WrtErr(w, http.StatusConflict, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "EnrollTOTP", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusOK, enrollment)
+}
+
+// ConfirmTOTP handles the HTTP POST /api/users/{id}/totp/confirm request.
+func (h *userHandler) ConfirmTOTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var dto user.TOTPCodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	codes, err := h.service.ConfirmTOTP(r.Context(), id, dto)
 	if err != nil {
-		log.Printf("Internal server error on GetAllUsers: %v", err)
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPNotEnrolled) {
+			httphandler.WrtErr(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPAlreadyEnabled) {
+			httphandler.WrtErr(w, http.StatusConflict, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidTOTPCode) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ConfirmTOTP", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusOK, codes)
+}
+
+// DisableTOTP handles the HTTP DELETE /api/users/{id}/totp request.
+func (h *userHandler) DisableTOTP(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var dto user.TOTPCodeDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	if err := h.service.DisableTOTP(r.Context(), id, dto); err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPNotEnrolled) {
+			httphandler.WrtErr(w, http.StatusNotFound, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPRequired) || errors.Is(err, userservice.ErrInvalidTOTPCode) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrTOTPLocked) {
+			httphandler.WrtErr(w, http.StatusTooManyRequests, err.Error())
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "DisableTOTP", "error", err)
 		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
 		return
 	}
 
-	httphandler.WrtJSON(w, http.StatusOK, users)
+	w.WriteHeader(http.StatusNoContent)
 }
 
 func New(s *userservice.Service) *userHandler {
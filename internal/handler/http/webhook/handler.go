@@ -0,0 +1,156 @@
+package webhook
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gorilla/mux"
+	"github.com/w-h-a/demo-go/api/user"
+	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+// webhookHandler is the HTTP handler for webhook subscription requests.
+type webhookHandler struct {
+	service *userservice.Service
+}
+
+// CreateWebhook handles the HTTP POST /api/webhooks request.
+func (h *webhookHandler) CreateWebhook(w http.ResponseWriter, r *http.Request) {
+	var dto user.CreateWebhookDTO
+	if err := json.NewDecoder(r.Body).Decode(&dto); err != nil {
+		httphandler.WrtErr(w, http.StatusBadRequest, "Invalid request body")
+		return
+	}
+
+	webhook, err := h.service.CreateWebhook(r.Context(), dto)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidWebhook) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrWebhooksDisabled) {
+			httphandler.WrtErr(w, http.StatusNotImplemented, "Webhooks are disabled")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "CreateWebhook", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.Header().Set("Cache-Control", "no-store")
+	httphandler.WrtJSON(w, http.StatusCreated, webhook)
+}
+
+// ListWebhooks handles the HTTP GET /api/webhooks request.
+func (h *webhookHandler) ListWebhooks(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := h.service.ListWebhooks(r.Context())
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ListWebhooks", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, webhooks)
+}
+
+// DeleteWebhook handles the HTTP DELETE /api/webhooks/{id} request.
+func (h *webhookHandler) DeleteWebhook(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	if err := h.service.DeleteWebhook(r.Context(), id); err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrWebhookNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "DeleteWebhook", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// ListDeliveries handles the HTTP GET /api/webhooks/{id}/deliveries?limit= request.
+func (h *webhookHandler) ListDeliveries(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+	id := vars["id"]
+
+	var limit int
+
+	if l := r.URL.Query().Get("limit"); l != "" {
+		n, err := strconv.Atoi(l)
+		if err != nil {
+			httphandler.WrtErr(w, http.StatusBadRequest, "Invalid limit")
+			return
+		}
+		limit = n
+	}
+
+	deliveries, err := h.service.ListWebhookDeliveries(r.Context(), id, limit)
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrInvalidListQuery) {
+			httphandler.WrtErr(w, http.StatusBadRequest, err.Error())
+			return
+		}
+		if errors.Is(err, userservice.ErrWebhookNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "Webhook not found")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "ListDeliveries", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusOK, deliveries)
+}
+
+// Redeliver handles the HTTP POST /api/webhooks/{id}/deliveries/{delivery_id}/redeliver request.
+func (h *webhookHandler) Redeliver(w http.ResponseWriter, r *http.Request) {
+	vars := mux.Vars(r)
+
+	delivery, err := h.service.RedeliverWebhookDelivery(r.Context(), vars["id"], vars["delivery_id"])
+	if err != nil {
+		if errors.Is(err, userservice.ErrForbidden) {
+			httphandler.WrtErr(w, http.StatusForbidden, "Forbidden")
+			return
+		}
+		if errors.Is(err, userservice.ErrWebhookDeliveryNotFound) {
+			httphandler.WrtErr(w, http.StatusNotFound, "Webhook delivery not found")
+			return
+		}
+		if errors.Is(err, userservice.ErrWebhookDeliveryPending) {
+			httphandler.WrtErr(w, http.StatusConflict, "Webhook delivery still pending")
+			return
+		}
+		logger.FromContext(r.Context()).ErrorContext(r.Context(), "internal server error", "handler", "Redeliver", "error", err)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
+
+	httphandler.WrtJSON(w, http.StatusAccepted, delivery)
+}
+
+func New(s *userservice.Service) *webhookHandler {
+	return &webhookHandler{service: s}
+}
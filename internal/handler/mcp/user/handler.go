@@ -0,0 +1,146 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/logger"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+const (
+	ResourceScheme = "users://"
+	jsonMIMEType   = "application/json"
+)
+
+// userHandler is the MCP handler for user-related tools and resources.
+// Like its HTTP sibling, it is "trivial" code, responsible for:
+// 1. Binding tool arguments and resource URIs
+// 2. Calling the service layer
+// 3. Encoding tool results and resource contents (or errors)
+type userHandler struct {
+	service *userservice.Service
+}
+
+// CreateUser handles the create_user tool.
+func (h *userHandler) CreateUser(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var dto user.CreateUserDTO
+	if err := req.BindArguments(&dto); err != nil {
+		return mcp.NewToolResultError("Invalid arguments"), nil
+	}
+
+	u, err := h.service.CreateUser(ctx, dto)
+	if err != nil {
+		return toolErr(ctx, err), nil
+	}
+
+	return mcp.NewToolResultJSON(u)
+}
+
+// GetUser handles the get_user tool.
+func (h *userHandler) GetUser(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	id, err := req.RequireString("id")
+	if err != nil {
+		return mcp.NewToolResultError(err.Error()), nil
+	}
+
+	u, err := h.service.GetUser(ctx, id)
+	if err != nil {
+		return toolErr(ctx, err), nil
+	}
+
+	return mcp.NewToolResultJSON(u)
+}
+
+// ListUsers handles the list_users tool.
+func (h *userHandler) ListUsers(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+	var dto user.ListUsersDTO
+	if err := req.BindArguments(&dto); err != nil {
+		return mcp.NewToolResultError("Invalid arguments"), nil
+	}
+
+	page, err := h.service.GetAllUsers(ctx, dto)
+	if err != nil {
+		return toolErr(ctx, err), nil
+	}
+
+	return mcp.NewToolResultJSON(page)
+}
+
+// ReadUser handles reads of the users://{id} resource template.
+func (h *userHandler) ReadUser(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	id := strings.TrimPrefix(req.Params.URI, ResourceScheme)
+
+	u, err := h.service.GetUser(ctx, id)
+	if err != nil {
+		return nil, resourceErr(ctx, err)
+	}
+
+	return jsonContents(req.Params.URI, u)
+}
+
+// ReadUsers handles reads of the users:// resource, returning the first page of users.
+func (h *userHandler) ReadUsers(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+	page, err := h.service.GetAllUsers(ctx, user.ListUsersDTO{Limit: userservice.MaxPageSize})
+	if err != nil {
+		return nil, resourceErr(ctx, err)
+	}
+
+	return jsonContents(req.Params.URI, page)
+}
+
+// toolErr reports service errors as tool results so the model can
+// read them, hiding anything unexpected behind a generic message
+func toolErr(ctx context.Context, err error) *mcp.CallToolResult {
+	switch {
+	case errors.Is(err, userservice.ErrUserNotFound):
+		return mcp.NewToolResultError("User not found")
+	case errors.Is(err, userservice.ErrForbidden):
+		return mcp.NewToolResultError("Forbidden")
+	case errors.Is(err, userservice.ErrEmailInUse),
+		errors.Is(err, userservice.ErrInvalidInput),
+		errors.Is(err, userservice.ErrInvalidPassword),
+		errors.Is(err, userservice.ErrInvalidListQuery):
+		return mcp.NewToolResultError(err.Error())
+	default:
+		logger.FromContext(ctx).ErrorContext(ctx, "internal server error", "handler", "mcp tool", "error", err)
+		return mcp.NewToolResultError("Internal server error")
+	}
+}
+
+func resourceErr(ctx context.Context, err error) error {
+	if errors.Is(err, userservice.ErrUserNotFound) {
+		return mcp.ErrResourceNotFound
+	}
+
+	if errors.Is(err, userservice.ErrForbidden) {
+		return errors.New("Forbidden")
+	}
+
+	logger.FromContext(ctx).ErrorContext(ctx, "internal server error", "handler", "mcp resource", "error", err)
+
+	return errors.New("Internal server error")
+}
+
+func jsonContents(uri string, data any) ([]mcp.ResourceContents, error) {
+	bs, err := json.Marshal(data)
+	if err != nil {
+		return nil, err
+	}
+
+	return []mcp.ResourceContents{
+		mcp.TextResourceContents{
+			URI:      uri,
+			MIMEType: jsonMIMEType,
+			Text:     string(bs),
+		},
+	}, nil
+}
+
+func New(s *userservice.Service) *userHandler {
+	return &userHandler{service: s}
+}
@@ -0,0 +1,48 @@
+package health
+
+import "context"
+
+// Checker is implemented by components that can tell whether they
+// work, such as stores, notifiers and servers.
+type Checker interface {
+	// Check returns why the component doesn't work, or nil if it
+	// does, giving up when ctx is done.
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc adapts a func to Checker.
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+type Status string
+
+const (
+	StatusUp Status = "up"
+	// StatusDegraded means only optional components are down
+	StatusDegraded Status = "degraded"
+	StatusDown     Status = "down"
+)
+
+// Component is the outcome of one component's check.
+type Component struct {
+	Status Status `json:"status"`
+	// Optional components being down degrades rather than fails the report
+	Optional bool   `json:"optional,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// Report is the outcome of checking every registered component.
+type Report struct {
+	Status       Status               `json:"status"`
+	ShuttingDown bool                 `json:"shutting_down,omitempty"`
+	Components   map[string]Component `json:"components,omitempty"`
+}
+
+// OK is true unless the report is down, degraded reports being good
+// enough to serve traffic.
+func (r Report) OK() bool {
+	return r.Status != StatusDown
+}
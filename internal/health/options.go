@@ -0,0 +1,31 @@
+package health
+
+import "time"
+
+const defaultTimeout = 2 * time.Second
+
+type Option func(*Options)
+
+type Options struct {
+	// Timeout bounds each component's check, so one hanging
+	// dependency can't hold up a probe
+	Timeout time.Duration
+}
+
+func WithTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.Timeout = d
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Timeout: defaultTimeout,
+	}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
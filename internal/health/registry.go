@@ -0,0 +1,188 @@
+package health
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"sync/atomic"
+)
+
+type entry struct {
+	name     string
+	checker  Checker
+	optional bool
+}
+
+// Registry checks the components registered with it, reporting on
+// them together.
+type Registry struct {
+	options Options
+	entries []entry
+	// shuttingDown fails readiness, whatever the components say
+	shuttingDown atomic.Bool
+	mtx          sync.RWMutex
+}
+
+// Register adds a component whose being down fails the report.
+func (r *Registry) Register(name string, c Checker) error {
+	return r.register(entry{name: name, checker: c})
+}
+
+// RegisterOptional adds a component whose being down only degrades
+// the report, as the others cope without it.
+func (r *Registry) RegisterOptional(name string, c Checker) error {
+	return r.register(entry{name: name, checker: c, optional: true})
+}
+
+func (r *Registry) register(e entry) error {
+	r.mtx.Lock()
+	defer r.mtx.Unlock()
+
+	if len(e.name) == 0 {
+		return errors.New("health check needs a name")
+	}
+
+	if e.checker == nil {
+		return fmt.Errorf("health check %q needs a checker", e.name)
+	}
+
+	for _, existing := range r.entries {
+		if existing.name == e.name {
+			return fmt.Errorf("health check %q already registered", e.name)
+		}
+	}
+
+	r.entries = append(r.entries, e)
+
+	return nil
+}
+
+// Names returns the names of the registered components, in the order
+// they were registered.
+func (r *Registry) Names() []string {
+	r.mtx.RLock()
+	defer r.mtx.RUnlock()
+
+	names := make([]string, 0, len(r.entries))
+	for _, e := range r.entries {
+		names = append(names, e.name)
+	}
+
+	return names
+}
+
+// Shutdown fails readiness from now on, so traffic is routed away
+// while the servers drain.
+func (r *Registry) Shutdown() {
+	r.shuttingDown.Store(true)
+}
+
+func (r *Registry) ShuttingDown() bool {
+	return r.shuttingDown.Load()
+}
+
+// Health checks every component at once.
+func (r *Registry) Health(ctx context.Context) Report {
+	r.mtx.RLock()
+	entries := append([]entry(nil), r.entries...)
+	r.mtx.RUnlock()
+
+	results := make([]Component, len(entries))
+
+	var wg sync.WaitGroup
+	for i, e := range entries {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			results[i] = r.check(ctx, e)
+		}()
+	}
+	wg.Wait()
+
+	report := Report{
+		Status:     StatusUp,
+		Components: make(map[string]Component, len(entries)),
+	}
+
+	for i, e := range entries {
+		report.Components[e.name] = results[i]
+
+		if results[i].Status != StatusDown {
+			continue
+		}
+
+		switch {
+		case !e.optional:
+			report.Status = StatusDown
+		case report.Status == StatusUp:
+			report.Status = StatusDegraded
+		}
+	}
+
+	return report
+}
+
+// Ready is Health, except that it is down without checking anything
+// once Shutdown is called.
+func (r *Registry) Ready(ctx context.Context) Report {
+	if r.ShuttingDown() {
+		return Report{Status: StatusDown, ShuttingDown: true}
+	}
+
+	return r.Health(ctx)
+}
+
+// Component checks the named component alone, returning false if no
+// such component is registered.
+func (r *Registry) Component(ctx context.Context, name string) (Component, bool) {
+	r.mtx.RLock()
+	i := slices.IndexFunc(r.entries, func(e entry) bool { return e.name == name })
+	var e entry
+	if i >= 0 {
+		e = r.entries[i]
+	}
+	r.mtx.RUnlock()
+
+	if i < 0 {
+		return Component{}, false
+	}
+
+	return r.check(ctx, e), true
+}
+
+// check runs e's checker, bounded by the timeout even if the checker
+// ignores ctx
+func (r *Registry) check(ctx context.Context, e entry) Component {
+	ctx, cancel := context.WithTimeout(ctx, r.options.Timeout)
+	defer cancel()
+
+	done := make(chan error, 1)
+	go func() {
+		done <- e.checker.Check(ctx)
+	}()
+
+	var err error
+
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+
+	c := Component{Status: StatusUp, Optional: e.optional}
+
+	if err != nil {
+		c.Status = StatusDown
+		c.Error = err.Error()
+	}
+
+	return c
+}
+
+func NewRegistry(opts ...Option) *Registry {
+	return &Registry{
+		options: NewOptions(opts...),
+	}
+}
@@ -0,0 +1,169 @@
+package logger
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log/slog"
+	"strings"
+	"sync"
+
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Format string
+
+const (
+	FormatJSON Format = "json"
+	FormatText Format = "text"
+)
+
+// New returns a logger writing records at or above level to w, as
+// JSON or text. Records logged with a context carry its request fields.
+func New(w io.Writer, format Format, level string) (*slog.Logger, error) {
+	var lvl slog.Level
+	if err := lvl.UnmarshalText([]byte(level)); err != nil {
+		return nil, fmt.Errorf("invalid log level %q: %w", level, err)
+	}
+
+	opts := &slog.HandlerOptions{Level: lvl}
+
+	var h slog.Handler
+
+	switch Format(strings.ToLower(string(format))) {
+	case FormatJSON:
+		h = slog.NewJSONHandler(w, opts)
+	case FormatText:
+		h = slog.NewTextHandler(w, opts)
+	default:
+		return nil, fmt.Errorf("unsupported log format %q", format)
+	}
+
+	return slog.New(NewHandler(h)), nil
+}
+
+// NewHandler wraps h so records get the request fields of their
+// context, along with the ids of its span, if it has one.
+func NewHandler(h slog.Handler) slog.Handler {
+	return &contextHandler{Handler: h}
+}
+
+type contextHandler struct {
+	slog.Handler
+}
+
+func (h *contextHandler) Handle(ctx context.Context, r slog.Record) error {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		r.AddAttrs(f.attrs()...)
+	}
+
+	if sc := trace.SpanContextFromContext(ctx); sc.IsValid() {
+		r.AddAttrs(
+			slog.String("trace_id", sc.TraceID().String()),
+			slog.String("span_id", sc.SpanID().String()),
+		)
+	}
+
+	return h.Handler.Handle(ctx, r)
+}
+
+func (h *contextHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithAttrs(attrs)}
+}
+
+func (h *contextHandler) WithGroup(name string) slog.Handler {
+	return &contextHandler{Handler: h.Handler.WithGroup(name)}
+}
+
+type fieldsKey struct{}
+
+type loggerKey struct{}
+
+// fields describe the request a context belongs to. Middleware further
+// in fills in the route and principal, once known, so they are shared
+// by pointer with the middleware that logs the request when it ends.
+type fields struct {
+	requestID string
+	route     string
+	principal string
+	mtx       sync.RWMutex
+}
+
+func (f *fields) attrs() []slog.Attr {
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	attrs := []slog.Attr{slog.String("request_id", f.requestID)}
+
+	if len(f.route) > 0 {
+		attrs = append(attrs, slog.String("route", f.route))
+	}
+
+	if len(f.principal) > 0 {
+		attrs = append(attrs, slog.String("principal", f.principal))
+	}
+
+	return attrs
+}
+
+// NewContext returns a context for the request with requestID, whose
+// handlers log with l.
+func NewContext(ctx context.Context, l *slog.Logger, requestID string) context.Context {
+	ctx = context.WithValue(ctx, loggerKey{}, l)
+	return context.WithValue(ctx, fieldsKey{}, &fields{requestID: requestID})
+}
+
+// FromContext returns the logger of the request ctx belongs to, or the
+// default logger outside of one.
+func FromContext(ctx context.Context) *slog.Logger {
+	if l, ok := ctx.Value(loggerKey{}).(*slog.Logger); ok {
+		return l
+	}
+
+	return slog.Default()
+}
+
+// RequestID returns the id of the request ctx belongs to, if any.
+func RequestID(ctx context.Context) string {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return ""
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.requestID
+}
+
+// Route returns the route of the request ctx belongs to, once known.
+func Route(ctx context.Context) string {
+	f, ok := ctx.Value(fieldsKey{}).(*fields)
+	if !ok {
+		return ""
+	}
+
+	f.mtx.RLock()
+	defer f.mtx.RUnlock()
+
+	return f.route
+}
+
+// SetRoute records the route, such as a path template or rpc method,
+// of the request ctx belongs to.
+func SetRoute(ctx context.Context, route string) {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		f.mtx.Lock()
+		f.route = route
+		f.mtx.Unlock()
+	}
+}
+
+// SetPrincipal records who made the request ctx belongs to.
+func SetPrincipal(ctx context.Context, id string) {
+	if f, ok := ctx.Value(fieldsKey{}).(*fields); ok {
+		f.mtx.Lock()
+		f.principal = id
+		f.mtx.Unlock()
+	}
+}
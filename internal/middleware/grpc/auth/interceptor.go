@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/w-h-a/demo-go/internal/logger"
+	"github.com/w-h-a/demo-go/internal/middleware"
+	grpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc"
+	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// healthService is called by orchestrators, which hold no credentials,
+// so it is left open like the probes on the admin listener
+var healthService = "/" + healthpb.Health_ServiceDesc.ServiceName + "/"
+
+// NewUnary authenticates each call against the incoming metadata with
+// the same authenticators as the HTTP auth middleware and puts the
+// principal into the context under middleware.UserKey. Calls without
+// valid credentials fail with Unauthenticated, except those to the
+// standard health service.
+func NewUnary(as ...authhttpmiddleware.Authenticator) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(ctx, req)
+		}
+		ctx, err := withUser(ctx, as)
+		if err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// NewStream is the streaming counterpart of NewUnary.
+func NewStream(as ...authhttpmiddleware.Authenticator) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if strings.HasPrefix(info.FullMethod, healthService) {
+			return handler(srv, ss)
+		}
+		ctx, err := withUser(ss.Context(), as)
+		if err != nil {
+			return err
+		}
+		return handler(srv, grpcmiddleware.WrapServerStream(ss, ctx))
+	}
+}
+
+func withUser(ctx context.Context, as []authhttpmiddleware.Authenticator) (context.Context, error) {
+	// metadata keys are lower-cased, so Add canonicalises them for the authenticators
+	header := http.Header{}
+	md, _ := metadata.FromIncomingContext(ctx)
+	for k, vs := range md {
+		for _, v := range vs {
+			header.Add(k, v)
+		}
+	}
+
+	authenticatedUser, err := authhttpmiddleware.Authenticate(ctx, header, as...)
+	if errors.Is(err, authhttpmiddleware.ErrNoCredentials) || errors.Is(err, authhttpmiddleware.ErrInvalidCredentials) {
+		return nil, status.Error(codes.Unauthenticated, "Unauthenticated")
+	} else if err != nil {
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to authenticate call", "error", err)
+		return nil, status.Error(codes.Internal, "Internal server error")
+	}
+
+	logger.SetPrincipal(ctx, authenticatedUser.ID)
+
+	return context.WithValue(ctx, middleware.UserKey{}, authenticatedUser), nil
+}
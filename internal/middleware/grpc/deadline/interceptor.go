@@ -0,0 +1,38 @@
+package deadline
+
+import (
+	"context"
+	"time"
+
+	grpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc"
+	"google.golang.org/grpc"
+)
+
+// NewUnary caps each rpc at limit. Callers may ask for a shorter deadline
+// but not a longer one, and calls without a deadline get limit.
+func NewUnary(limit time.Duration) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		ctx, cancel := withLimit(ctx, limit)
+		defer cancel()
+
+		return handler(ctx, req)
+	}
+}
+
+// NewStream is the streaming counterpart of NewUnary.
+func NewStream(limit time.Duration) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, cancel := withLimit(ss.Context(), limit)
+		defer cancel()
+
+		return handler(srv, grpcmiddleware.WrapServerStream(ss, ctx))
+	}
+}
+
+func withLimit(ctx context.Context, limit time.Duration) (context.Context, context.CancelFunc) {
+	if d, ok := ctx.Deadline(); ok && time.Until(d) <= limit {
+		return context.WithCancel(ctx)
+	}
+
+	return context.WithTimeout(ctx, limit)
+}
@@ -0,0 +1,83 @@
+package logging
+
+import (
+	"context"
+	"log/slog"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/internal/logger"
+	grpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+// RequestIDKey is the metadata key carrying the request id, both ways.
+// One sent by the caller is kept.
+const RequestIDKey = "x-request-id"
+
+const maxRequestIDLength = 128
+
+// NewUnary gives each rpc an id and a context whose handlers log with
+// l, and logs its method, status code and duration. It must come before
+// interceptors that log, so their records carry the rpc's fields.
+func NewUnary(l *slog.Logger) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		ctx = withRequest(ctx, l, info.FullMethod)
+
+		rsp, err := handler(ctx, req)
+
+		logCall(ctx, l, status.Code(err), time.Since(start))
+
+		return rsp, err
+	}
+}
+
+// NewStream is the streaming counterpart of NewUnary.
+func NewStream(l *slog.Logger) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		ctx := withRequest(ss.Context(), l, info.FullMethod)
+
+		err := handler(srv, grpcmiddleware.WrapServerStream(ss, ctx))
+
+		logCall(ctx, l, status.Code(err), time.Since(start))
+
+		return err
+	}
+}
+
+func withRequest(ctx context.Context, l *slog.Logger, method string) context.Context {
+	var id string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(RequestIDKey); len(ids) > 0 && len(ids[0]) > 0 && len(ids[0]) <= maxRequestIDLength {
+			id = ids[0]
+		}
+	}
+	if len(id) == 0 {
+		id = uuid.NewString()
+	}
+
+	// best effort, as the caller may not wait for headers
+	_ = grpc.SetHeader(ctx, metadata.Pairs(RequestIDKey, id))
+
+	ctx = logger.NewContext(ctx, l, id)
+	logger.SetRoute(ctx, method)
+
+	return ctx
+}
+
+func logCall(ctx context.Context, l *slog.Logger, code codes.Code, d time.Duration) {
+	level := slog.LevelInfo
+	switch code {
+	case codes.Internal, codes.Unknown, codes.DataLoss, codes.Unavailable:
+		level = slog.LevelError
+	}
+
+	l.Log(ctx, level, "grpc request", "code", code.String(), "duration", d)
+}
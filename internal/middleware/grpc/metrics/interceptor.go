@@ -0,0 +1,70 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/w-h-a/demo-go/internal/metrics"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+type collectors struct {
+	requests  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+}
+
+func newCollectors(reg prometheus.Registerer) collectors {
+	labels := []string{"method", "code"}
+
+	return collectors{
+		requests: metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "grpc_server_requests_total",
+			Help: "gRPC calls answered, by method and status code.",
+		}, labels)),
+		durations: metrics.Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Name:    "grpc_server_request_duration_seconds",
+			Help:    "Time taken to answer gRPC calls, by method and status code.",
+			Buckets: prometheus.DefBuckets,
+		}, labels)),
+	}
+}
+
+func (c collectors) observe(method string, code codes.Code, d time.Duration) {
+	c.requests.WithLabelValues(method, code.String()).Inc()
+	c.durations.WithLabelValues(method, code.String()).Observe(d.Seconds())
+}
+
+// NewUnary counts calls and observes their durations in reg, by method
+// and status code. It must come after the recovery interceptor, so
+// panics are counted as Internal.
+func NewUnary(reg prometheus.Registerer) grpc.UnaryServerInterceptor {
+	c := newCollectors(reg)
+
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		start := time.Now()
+
+		rsp, err := handler(ctx, req)
+
+		c.observe(info.FullMethod, status.Code(err), time.Since(start))
+
+		return rsp, err
+	}
+}
+
+// NewStream is the streaming counterpart of NewUnary.
+func NewStream(reg prometheus.Registerer) grpc.StreamServerInterceptor {
+	c := newCollectors(reg)
+
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		start := time.Now()
+
+		err := handler(srv, ss)
+
+		c.observe(info.FullMethod, status.Code(err), time.Since(start))
+
+		return err
+	}
+}
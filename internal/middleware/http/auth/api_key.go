@@ -0,0 +1,104 @@
+package auth
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/w-h-a/demo-go/api/user"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+const apiKeyHeader = "X-API-Key"
+
+type apiKeyAuthenticator struct {
+	// keyed by digest so lookups don't leak key contents through timing
+	keys map[[sha256.Size]byte]user.User
+}
+
+func (a *apiKeyAuthenticator) Authenticate(ctx context.Context, header http.Header) (user.User, error) {
+	key := header.Get(apiKeyHeader)
+	if len(key) == 0 {
+		return user.User{}, ErrNoCredentials
+	}
+
+	u, ok := a.keys[sha256.Sum256([]byte(key))]
+	if !ok {
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	return u, nil
+}
+
+func (a *apiKeyAuthenticator) Scheme() string {
+	return "ApiKey"
+}
+
+// NewAPIKeyAuthenticator accepts the static keys in keys, sent in the
+// X-API-Key header, and resolves each to the principal it maps to.
+func NewAPIKeyAuthenticator(keys map[string]user.User) Authenticator {
+	a := &apiKeyAuthenticator{
+		keys: make(map[[sha256.Size]byte]user.User, len(keys)),
+	}
+
+	for k, u := range keys {
+		a.keys[sha256.Sum256([]byte(k))] = u
+	}
+
+	return a
+}
+
+// APIKeyVerifier checks API keys created through the API. The user
+// service implements it, failing with userservice.ErrInvalidCredentials
+// for keys it rejects.
+type APIKeyVerifier interface {
+	IsAPIKey(key string) bool
+	VerifyAPIKey(ctx context.Context, key string) (user.User, error)
+}
+
+type managedAPIKeyAuthenticator struct {
+	verifier APIKeyVerifier
+}
+
+func (a *managedAPIKeyAuthenticator) Authenticate(ctx context.Context, header http.Header) (user.User, error) {
+	key := header.Get(apiKeyHeader)
+
+	if len(key) == 0 {
+		scheme, token, ok := strings.Cut(header.Get("Authorization"), " ")
+		if ok && strings.EqualFold(scheme, "Bearer") {
+			key = token
+		}
+	}
+
+	// static keys and bearer tokens from other issuers
+	// belong to other authenticators
+	if len(key) == 0 || !a.verifier.IsAPIKey(key) {
+		return user.User{}, ErrNoCredentials
+	}
+
+	u, err := a.verifier.VerifyAPIKey(ctx, key)
+	if errors.Is(err, userservice.ErrInvalidCredentials) {
+		return user.User{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	} else if err != nil {
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (a *managedAPIKeyAuthenticator) Scheme() string {
+	return "ApiKey"
+}
+
+// NewManagedAPIKeyAuthenticator accepts API keys created through the
+// API, sent as bearer tokens or in the X-API-Key header. It must come
+// before the static key and JWT authenticators, which would otherwise
+// reject them.
+func NewManagedAPIKeyAuthenticator(v APIKeyVerifier) Authenticator {
+	return &managedAPIKeyAuthenticator{
+		verifier: v,
+	}
+}
@@ -0,0 +1,39 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"net/http"
+
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+var (
+	// ErrNoCredentials means the request carries nothing the authenticator
+	// recognises, so the next authenticator gets a turn.
+	ErrNoCredentials = errors.New("no credentials")
+	// ErrInvalidCredentials means the request carries credentials the
+	// authenticator recognises but rejects.
+	ErrInvalidCredentials = errors.New("invalid credentials")
+)
+
+// Authenticator resolves the principal a request's headers speak for.
+type Authenticator interface {
+	Authenticate(ctx context.Context, header http.Header) (user.User, error)
+	// Scheme is the auth scheme advertised in WWW-Authenticate on a 401.
+	Scheme() string
+}
+
+// Authenticate returns the verdict of the first authenticator in as
+// that recognises the credentials in header.
+func Authenticate(ctx context.Context, header http.Header, as ...Authenticator) (user.User, error) {
+	for _, a := range as {
+		u, err := a.Authenticate(ctx, header)
+		if errors.Is(err, ErrNoCredentials) {
+			continue
+		}
+		return u, err
+	}
+
+	return user.User{}, ErrNoCredentials
+}
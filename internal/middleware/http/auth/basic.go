@@ -0,0 +1,125 @@
+package auth
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+
+	"github.com/w-h-a/demo-go/api/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// CredentialStore verifies a username and password pair.
+type CredentialStore interface {
+	Verify(ctx context.Context, username string, password string) (user.User, error)
+}
+
+type basicAuthenticator struct {
+	store CredentialStore
+}
+
+func (a *basicAuthenticator) Authenticate(ctx context.Context, header http.Header) (user.User, error) {
+	r := &http.Request{Header: header}
+
+	username, password, ok := r.BasicAuth()
+	if !ok {
+		return user.User{}, ErrNoCredentials
+	}
+
+	return a.store.Verify(ctx, username, password)
+}
+
+func (a *basicAuthenticator) Scheme() string {
+	return "Basic"
+}
+
+// NewBasicAuthenticator accepts HTTP Basic credentials verified by store.
+func NewBasicAuthenticator(store CredentialStore) Authenticator {
+	return &basicAuthenticator{
+		store: store,
+	}
+}
+
+type htpasswdEntry struct {
+	hash  []byte
+	roles []user.Role
+}
+
+type htpasswdStore struct {
+	entries map[string]htpasswdEntry
+}
+
+// dummyHash is compared against for unknown usernames, so they
+// take as long to reject as a wrong password does.
+var dummyHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("dummy"), bcrypt.DefaultCost)
+	return hash
+})
+
+func (s *htpasswdStore) Verify(ctx context.Context, username string, password string) (user.User, error) {
+	entry, ok := s.entries[username]
+	if !ok {
+		bcrypt.CompareHashAndPassword(dummyHash(), []byte(password))
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	if err := bcrypt.CompareHashAndPassword(entry.hash, []byte(password)); err != nil {
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	return user.User{ID: username, Name: username, Roles: entry.roles}, nil
+}
+
+// LoadHtpasswdFile reads username:hash lines with bcrypt hashes, as
+// written by `htpasswd -B`, optionally followed by :role,role. The
+// username becomes the principal's ID.
+func LoadHtpasswdFile(path string) (CredentialStore, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	s := &htpasswdStore{
+		entries: map[string]htpasswdEntry{},
+	}
+
+	scanner := bufio.NewScanner(f)
+	for n := 1; scanner.Scan(); n++ {
+		line := strings.TrimSpace(scanner.Text())
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, ":")
+		if len(fields) < 2 || len(fields) > 3 || len(fields[0]) == 0 {
+			return nil, fmt.Errorf("%s:%d: expected username:hash[:roles]", path, n)
+		}
+
+		if _, err := bcrypt.Cost([]byte(fields[1])); err != nil {
+			return nil, fmt.Errorf("%s:%d: only bcrypt hashes are supported", path, n)
+		}
+
+		entry := htpasswdEntry{hash: []byte(fields[1])}
+
+		if len(fields) == 3 {
+			for _, role := range strings.Split(fields[2], ",") {
+				if role = strings.TrimSpace(role); len(role) > 0 {
+					entry.roles = append(entry.roles, user.Role(role))
+				}
+			}
+		}
+
+		s.entries[fields[0]] = entry
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return s, nil
+}
@@ -0,0 +1,214 @@
+package auth
+
+import (
+	"bytes"
+	"context"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+// JWTKeys holds the keys bearer tokens may be signed with. Tokens
+// carrying a kid header are checked against ByKeyID only.
+type JWTKeys struct {
+	HMAC    []byte
+	RSA     *rsa.PublicKey
+	ByKeyID map[string]any
+}
+
+type jwtClaims struct {
+	jwt.RegisteredClaims
+	Name  string      `json:"name,omitempty"`
+	Email string      `json:"email,omitempty"`
+	Roles []user.Role `json:"roles,omitempty"`
+}
+
+type jwtAuthenticator struct {
+	keys   JWTKeys
+	parser *jwt.Parser
+}
+
+func (a *jwtAuthenticator) Authenticate(ctx context.Context, header http.Header) (user.User, error) {
+	scheme, token, ok := strings.Cut(header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return user.User{}, ErrNoCredentials
+	}
+
+	// opaque bearer tokens belong to other authenticators
+	if strings.Count(token, ".") != 2 {
+		return user.User{}, ErrNoCredentials
+	}
+
+	var claims jwtClaims
+
+	if _, err := a.parser.ParseWithClaims(token, &claims, a.key); err != nil {
+		return user.User{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	if len(claims.Subject) == 0 {
+		return user.User{}, fmt.Errorf("%w: token has no subject", ErrInvalidCredentials)
+	}
+
+	return user.User{
+		ID:    claims.Subject,
+		Name:  claims.Name,
+		Email: claims.Email,
+		Roles: claims.Roles,
+	}, nil
+}
+
+func (a *jwtAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+func (a *jwtAuthenticator) key(token *jwt.Token) (any, error) {
+	if kid, ok := token.Header["kid"].(string); ok {
+		key, ok := a.keys.ByKeyID[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown key id %q", kid)
+		}
+		return key, nil
+	}
+
+	switch token.Method.(type) {
+	case *jwt.SigningMethodHMAC:
+		if a.keys.HMAC != nil {
+			return a.keys.HMAC, nil
+		}
+	case *jwt.SigningMethodRSA:
+		if a.keys.RSA != nil {
+			return a.keys.RSA, nil
+		}
+	}
+
+	return nil, fmt.Errorf("no key for %s", token.Method.Alg())
+}
+
+// NewJWTAuthenticator accepts HMAC or RSA signed bearer tokens, mapping
+// sub, name, email and roles claims onto the principal. Empty issuer and
+// audience are not checked.
+func NewJWTAuthenticator(keys JWTKeys, issuer string, audience string) Authenticator {
+	opts := []jwt.ParserOption{
+		jwt.WithValidMethods([]string{"HS256", "HS384", "HS512", "RS256", "RS384", "RS512"}),
+		jwt.WithExpirationRequired(),
+	}
+
+	if len(issuer) > 0 {
+		opts = append(opts, jwt.WithIssuer(issuer))
+	}
+
+	if len(audience) > 0 {
+		opts = append(opts, jwt.WithAudience(audience))
+	}
+
+	return &jwtAuthenticator{
+		keys:   keys,
+		parser: jwt.NewParser(opts...),
+	}
+}
+
+// LoadJWTKeys reads the verification keys. keyFile holds either a PEM
+// RSA public key or a raw HMAC secret; jwksFile holds a JWK Set with
+// RSA and oct keys. Either may be empty.
+func LoadJWTKeys(keyFile string, jwksFile string) (JWTKeys, error) {
+	var keys JWTKeys
+
+	if len(keyFile) > 0 {
+		bs, err := os.ReadFile(keyFile)
+		if err != nil {
+			return JWTKeys{}, err
+		}
+
+		if bytes.HasPrefix(bytes.TrimSpace(bs), []byte("-----BEGIN")) {
+			keys.RSA, err = jwt.ParseRSAPublicKeyFromPEM(bs)
+			if err != nil {
+				return JWTKeys{}, fmt.Errorf("failed to parse %s: %w", keyFile, err)
+			}
+		} else {
+			keys.HMAC = bytes.TrimSpace(bs)
+			if len(keys.HMAC) == 0 {
+				return JWTKeys{}, fmt.Errorf("%s is empty", keyFile)
+			}
+		}
+	}
+
+	if len(jwksFile) > 0 {
+		bs, err := os.ReadFile(jwksFile)
+		if err != nil {
+			return JWTKeys{}, err
+		}
+
+		keys.ByKeyID, err = parseJWKS(bs)
+		if err != nil {
+			return JWTKeys{}, fmt.Errorf("failed to parse %s: %w", jwksFile, err)
+		}
+	}
+
+	return keys, nil
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	K   string `json:"k"`
+}
+
+func parseJWKS(bs []byte) (map[string]any, error) {
+	var set struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.Unmarshal(bs, &set); err != nil {
+		return nil, err
+	}
+
+	keys := map[string]any{}
+
+	for _, k := range set.Keys {
+		if len(k.Kid) == 0 {
+			return nil, errors.New("every key requires a kid")
+		}
+
+		if len(k.Use) > 0 && k.Use != "sig" {
+			continue
+		}
+
+		switch k.Kty {
+		case "RSA":
+			n, err := base64.RawURLEncoding.DecodeString(k.N)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid n: %w", k.Kid, err)
+			}
+			e, err := base64.RawURLEncoding.DecodeString(k.E)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid e: %w", k.Kid, err)
+			}
+			keys[k.Kid] = &rsa.PublicKey{
+				N: new(big.Int).SetBytes(n),
+				E: int(new(big.Int).SetBytes(e).Int64()),
+			}
+		case "oct":
+			secret, err := base64.RawURLEncoding.DecodeString(k.K)
+			if err != nil {
+				return nil, fmt.Errorf("key %q: invalid k: %w", k.Kid, err)
+			}
+			keys[k.Kid] = secret
+		default:
+			return nil, fmt.Errorf("key %q: unsupported kty %q", k.Kid, k.Kty)
+		}
+	}
+
+	return keys, nil
+}
@@ -2,36 +2,123 @@ package auth
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"net/http"
+	"strings"
 
-	"github.com/w-h-a/demo-go/api/user"
 	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
 	"github.com/w-h-a/demo-go/internal/middleware"
 )
 
+type publicRoute struct {
+	method string
+	path   string
+	prefix bool
+}
+
+func (p publicRoute) matches(r *http.Request) bool {
+	if len(p.method) > 0 && p.method != r.Method {
+		return false
+	}
+
+	if p.prefix {
+		return r.URL.Path == p.path || strings.HasPrefix(r.URL.Path, p.path+"/")
+	}
+
+	return r.URL.Path == p.path
+}
+
 type authMiddleware struct {
-	handler http.Handler
+	options      Options
+	handler      http.Handler
+	publicRoutes []publicRoute
 }
 
 func (m *authMiddleware) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	ctx := httphandler.ReqToCtx(r)
 
-	var authenticatedUser user.User
-	// var authErr error
+	authenticatedUser, authErr := Authenticate(ctx, r.Header, m.options.Authenticators...)
 
-	// TODO: fill in authenticatedUser & handle authErr
+	switch {
+	case authErr == nil:
+		logger.SetPrincipal(ctx, authenticatedUser.ID)
+		ctx = context.WithValue(ctx, middleware.UserKey{}, authenticatedUser)
+	case errors.Is(authErr, ErrNoCredentials) && m.isPublic(r):
+		// anonymous access, so no principal in ctx
+	case errors.Is(authErr, ErrNoCredentials) || errors.Is(authErr, ErrInvalidCredentials):
+		m.unauthorized(w)
+		return
+	default:
+		// the credentials couldn't be checked, so don't call them bad
+		logger.FromContext(ctx).ErrorContext(ctx, "failed to authenticate request", "error", authErr)
+		httphandler.WrtErr(w, http.StatusInternalServerError, "Internal server error")
+		return
+	}
 
-	ctxWithUser := context.WithValue(ctx, middleware.UserKey{}, authenticatedUser)
-	rWithUser := r.WithContext(ctxWithUser)
+	m.handler.ServeHTTP(w, r.WithContext(ctx))
+}
 
-	m.handler.ServeHTTP(w, rWithUser)
+func (m *authMiddleware) isPublic(r *http.Request) bool {
+	for _, p := range m.publicRoutes {
+		if p.matches(r) {
+			return true
+		}
+	}
+
+	return false
 }
 
-// TODO: pass in dependencies (auth service)
-func New() func(h http.Handler) http.Handler {
+func (m *authMiddleware) unauthorized(w http.ResponseWriter) {
+	seen := map[string]bool{}
+	for _, a := range m.options.Authenticators {
+		// session and JWT authenticators share the Bearer scheme
+		if seen[a.Scheme()] {
+			continue
+		}
+		seen[a.Scheme()] = true
+		w.Header().Add("WWW-Authenticate", fmt.Sprintf("%s realm=%q", a.Scheme(), m.options.Realm))
+	}
+
+	httphandler.WrtErr(w, http.StatusUnauthorized, "Unauthorized")
+}
+
+func parsePublicRoute(route string) publicRoute {
+	var p publicRoute
+
+	if method, path, ok := strings.Cut(strings.TrimSpace(route), " "); ok {
+		p.method = strings.ToUpper(method)
+		route = path
+	}
+
+	p.path = strings.TrimSpace(route)
+
+	if strings.HasSuffix(p.path, "/*") {
+		p.prefix = true
+		p.path = strings.TrimSuffix(p.path, "/*")
+	}
+
+	return p
+}
+
+// New authenticates each request with the configured authenticators and
+// puts the principal into the context under middleware.UserKey. Requests
+// without valid credentials get a 401 unless their route is public, and
+// requests whose credentials couldn't be checked get a 500.
+func New(opts ...Option) func(h http.Handler) http.Handler {
+	options := NewOptions(opts...)
+
+	var publicRoutes []publicRoute
+	for _, route := range options.PublicRoutes {
+		publicRoutes = append(publicRoutes, parsePublicRoute(route))
+	}
+
 	return func(handler http.Handler) http.Handler {
 		return &authMiddleware{
-			handler: handler,
+			options:      options,
+			handler:      handler,
+			publicRoutes: publicRoutes,
 		}
 	}
 }
@@ -0,0 +1,44 @@
+package auth
+
+type Option func(o *Options)
+
+type Options struct {
+	Authenticators []Authenticator
+	PublicRoutes   []string
+	Realm          string
+}
+
+// WithAuthenticators sets the authenticators tried, in order, on each request.
+func WithAuthenticators(as ...Authenticator) Option {
+	return func(o *Options) {
+		o.Authenticators = append(o.Authenticators, as...)
+	}
+}
+
+// WithPublicRoutes exempts routes from authentication. A route is a
+// path, optionally prefixed by a method ("POST /api/users"); a path
+// ending in /* matches everything below it.
+func WithPublicRoutes(routes ...string) Option {
+	return func(o *Options) {
+		o.PublicRoutes = append(o.PublicRoutes, routes...)
+	}
+}
+
+// WithRealm sets the realm advertised in WWW-Authenticate.
+func WithRealm(realm string) Option {
+	return func(o *Options) {
+		o.Realm = realm
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Realm: "demo-go",
+	}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
@@ -0,0 +1,58 @@
+package auth
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+
+	"github.com/w-h-a/demo-go/api/user"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+)
+
+// SessionVerifier checks access tokens issued at login. The user
+// service implements it, failing with userservice.ErrInvalidCredentials
+// for tokens it rejects.
+type SessionVerifier interface {
+	IsAccessToken(token string) bool
+	VerifyAccessToken(ctx context.Context, token string) (user.User, error)
+}
+
+type sessionAuthenticator struct {
+	verifier SessionVerifier
+}
+
+func (a *sessionAuthenticator) Authenticate(ctx context.Context, header http.Header) (user.User, error) {
+	scheme, token, ok := strings.Cut(header.Get("Authorization"), " ")
+	if !ok || !strings.EqualFold(scheme, "Bearer") {
+		return user.User{}, ErrNoCredentials
+	}
+
+	// bearer tokens from other issuers belong to other authenticators
+	if !a.verifier.IsAccessToken(token) {
+		return user.User{}, ErrNoCredentials
+	}
+
+	u, err := a.verifier.VerifyAccessToken(ctx, token)
+	if errors.Is(err, userservice.ErrInvalidCredentials) {
+		return user.User{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	} else if err != nil {
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+func (a *sessionAuthenticator) Scheme() string {
+	return "Bearer"
+}
+
+// NewSessionAuthenticator accepts bearer access tokens issued at login.
+// It must come before the JWT authenticator, which would otherwise
+// reject them.
+func NewSessionAuthenticator(v SessionVerifier) Authenticator {
+	return &sessionAuthenticator{
+		verifier: v,
+	}
+}
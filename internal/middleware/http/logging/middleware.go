@@ -0,0 +1,137 @@
+package logging
+
+import (
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/gorilla/mux"
+	httphandler "github.com/w-h-a/demo-go/internal/handler/http"
+	"github.com/w-h-a/demo-go/internal/logger"
+)
+
+// RequestIDHeader carries the request id. One sent by the caller is
+// kept, so a request can be followed across services.
+const RequestIDHeader = "X-Request-Id"
+
+const maxRequestIDLength = 128
+
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(bs []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	n, err := r.ResponseWriter.Write(bs)
+	r.bytes += n
+	return n, err
+}
+
+// Flush lets streaming responses, such as server-sent events, through
+func (r *responseRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// New gives each request an id and a context whose handlers log with l,
+// and logs each request once it is answered. A panic in a downstream
+// handler is logged and answered with a 500 instead of dropping the
+// connection.
+func New(l *slog.Logger) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+
+			id := r.Header.Get(RequestIDHeader)
+			if !validRequestID(id) {
+				id = uuid.NewString()
+			}
+
+			w.Header().Set(RequestIDHeader, id)
+
+			ctx := logger.NewContext(r.Context(), l, id)
+			rec := &responseRecorder{ResponseWriter: w}
+
+			defer func() {
+				if p := recover(); p != nil {
+					if p == http.ErrAbortHandler {
+						panic(p)
+					}
+					l.ErrorContext(ctx, "recovered from panic", "panic", p, "stack", string(debug.Stack()))
+					if rec.status == 0 {
+						httphandler.WrtErr(rec, http.StatusInternalServerError, "Internal server error")
+					}
+				}
+
+				// handlers that write nothing answer 200
+				status := rec.status
+				if status == 0 {
+					status = http.StatusOK
+				}
+
+				level := slog.LevelInfo
+				if status >= http.StatusInternalServerError {
+					level = slog.LevelError
+				}
+
+				l.Log(ctx, level, "http request",
+					"method", r.Method,
+					"path", r.URL.Path,
+					"status", status,
+					"bytes", rec.bytes,
+					"duration", time.Since(start),
+				)
+			}()
+
+			h.ServeHTTP(rec, r.WithContext(ctx))
+		})
+	}
+}
+
+// Route records the path template of the route a mux.Router matched,
+// for the logs of the request. It must be used on the router.
+func Route(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				logger.SetRoute(r.Context(), r.Method+" "+tmpl)
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
+
+// validRequestID accepts ids of printable ascii of a sane length, so
+// callers can't forge log lines through them
+func validRequestID(id string) bool {
+	if len(id) == 0 || len(id) > maxRequestIDLength {
+		return false
+	}
+
+	for i := 0; i < len(id); i++ {
+		if id[i] < 0x21 || id[i] > 0x7e {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/w-h-a/demo-go/internal/logger"
+	"github.com/w-h-a/demo-go/internal/metrics"
+)
+
+// unmatched labels requests no route matched, rather than their paths,
+// which callers choose
+const unmatched = "unmatched"
+
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+}
+
+func (r *statusRecorder) WriteHeader(status int) {
+	if r.status == 0 {
+		r.status = status
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *statusRecorder) Write(bs []byte) (int, error) {
+	if r.status == 0 {
+		r.status = http.StatusOK
+	}
+	return r.ResponseWriter.Write(bs)
+}
+
+// Flush lets streaming responses, such as server-sent events, through
+func (r *statusRecorder) Flush() {
+	if f, ok := r.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (r *statusRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+// New counts requests and observes their durations in reg, by route,
+// such as "GET /api/users/{id}", and status code. Routes come from the
+// logging middleware's Route, so it must come after the logging
+// middleware. Panics are recorded as 500s on their way to its recovery.
+func New(reg prometheus.Registerer) func(h http.Handler) http.Handler {
+	labels := []string{"route", "code"}
+
+	requests := metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "http_server_requests_total",
+		Help: "HTTP requests answered, by route and status code.",
+	}, labels))
+
+	durations := metrics.Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "http_server_request_duration_seconds",
+		Help:    "Time taken to answer HTTP requests, by route and status code.",
+		Buckets: prometheus.DefBuckets,
+	}, labels))
+
+	return func(h http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			start := time.Now()
+			rec := &statusRecorder{ResponseWriter: w}
+
+			defer func() {
+				status := rec.status
+
+				p := recover()
+				switch {
+				case p != nil && p != http.ErrAbortHandler:
+					status = http.StatusInternalServerError
+				case status == 0:
+					// handlers that write nothing answer 200
+					status = http.StatusOK
+				}
+
+				route := logger.Route(r.Context())
+				if len(route) == 0 {
+					route = unmatched
+				}
+
+				values := []string{route, strconv.Itoa(status)}
+				requests.WithLabelValues(values...).Inc()
+				durations.WithLabelValues(values...).Observe(time.Since(start).Seconds())
+
+				if p != nil {
+					panic(p)
+				}
+			}()
+
+			h.ServeHTTP(rec, r)
+		})
+	}
+}
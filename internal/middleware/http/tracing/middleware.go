@@ -0,0 +1,41 @@
+package tracing
+
+import (
+	"net/http"
+
+	"github.com/gorilla/mux"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// New starts a server span with tp for each request, continuing the
+// trace of the caller's traceparent header, if any. The span is named
+// for the method alone until Route names it for the route. It must come
+// before the logging middleware, so logs carry the span's trace id.
+func New(tp trace.TracerProvider) func(h http.Handler) http.Handler {
+	return func(h http.Handler) http.Handler {
+		return otelhttp.NewHandler(h, "http",
+			otelhttp.WithTracerProvider(tp),
+			otelhttp.WithSpanNameFormatter(func(_ string, r *http.Request) string {
+				return r.Method
+			}),
+		)
+	}
+}
+
+// Route names the span of the request for the path template of the
+// route a mux.Router matched. It must be used on the router.
+func Route(h http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if route := mux.CurrentRoute(r); route != nil {
+			if tmpl, err := route.GetPathTemplate(); err == nil {
+				span := trace.SpanFromContext(r.Context())
+				span.SetName(r.Method + " " + tmpl)
+				span.SetAttributes(semconv.HTTPRoute(tmpl))
+			}
+		}
+
+		h.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,47 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/w-h-a/demo-go/internal/metrics"
+)
+
+// NewTool counts tool calls and observes their durations in reg, by
+// tool and result, which is "error" for calls answered with an error
+// result or returning an error, and "ok" otherwise.
+func NewTool(reg prometheus.Registerer) mcpserver.ToolHandlerMiddleware {
+	labels := []string{"tool", "result"}
+
+	calls := metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "mcp_tool_calls_total",
+		Help: "MCP tool calls answered, by tool and result.",
+	}, labels))
+
+	durations := metrics.Register(reg, prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "mcp_tool_call_duration_seconds",
+		Help:    "Time taken to answer MCP tool calls, by tool and result.",
+		Buckets: prometheus.DefBuckets,
+	}, labels))
+
+	return func(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			start := time.Now()
+
+			rsp, err := next(ctx, req)
+
+			result := "ok"
+			if err != nil || (rsp != nil && rsp.IsError) {
+				result = "error"
+			}
+
+			calls.WithLabelValues(req.Params.Name, result).Inc()
+			durations.WithLabelValues(req.Params.Name, result).Observe(time.Since(start).Seconds())
+
+			return rsp, err
+		}
+	}
+}
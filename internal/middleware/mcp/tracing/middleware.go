@@ -0,0 +1,65 @@
+package tracing
+
+import (
+	"context"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpserver "github.com/mark3labs/mcp-go/server"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/w-h-a/demo-go/internal/middleware/mcp/tracing"
+
+// NewTool starts a span with tp for each tool call, named for the tool.
+// Calls answered with an error result mark the span failed, like those
+// that return an error.
+func NewTool(tp trace.TracerProvider) mcpserver.ToolHandlerMiddleware {
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(next mcpserver.ToolHandlerFunc) mcpserver.ToolHandlerFunc {
+		return func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			ctx, span := tracer.Start(ctx, "tools/call "+req.Params.Name,
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("mcp.tool.name", req.Params.Name)),
+			)
+			defer span.End()
+
+			rsp, err := next(ctx, req)
+
+			switch {
+			case err != nil:
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			case rsp != nil && rsp.IsError:
+				span.SetStatus(codes.Error, "tool call failed")
+			}
+
+			return rsp, err
+		}
+	}
+}
+
+// NewResource starts a span with tp for each resource read.
+func NewResource(tp trace.TracerProvider) mcpserver.ResourceHandlerMiddleware {
+	tracer := tp.Tracer(instrumentationName)
+
+	return func(next mcpserver.ResourceHandlerFunc) mcpserver.ResourceHandlerFunc {
+		return func(ctx context.Context, req mcp.ReadResourceRequest) ([]mcp.ResourceContents, error) {
+			ctx, span := tracer.Start(ctx, "resources/read",
+				trace.WithSpanKind(trace.SpanKindServer),
+				trace.WithAttributes(attribute.String("mcp.resource.uri", req.Params.URI)),
+			)
+			defer span.End()
+
+			contents, err := next(ctx, req)
+			if err != nil {
+				span.RecordError(err)
+				span.SetStatus(codes.Error, err.Error())
+			}
+
+			return contents, err
+		}
+	}
+}
@@ -5,6 +5,9 @@ import (
 
 	"github.com/w-h-a/demo-go/internal/server"
 	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+	"google.golang.org/grpc/stats"
 )
 
 type unaryInterceptorKey struct{}
@@ -32,3 +35,85 @@ func getStreamInterceptorsFromCtx(ctx context.Context) ([]grpc.StreamServerInter
 	streamies, ok := ctx.Value(streamInterceptorKey{}).([]grpc.StreamServerInterceptor)
 	return streamies, ok
 }
+
+type statsHandlerKey struct{}
+
+// WithStatsHandlers sets handlers that see every rpc from before the
+// interceptors run, such as tracing instrumentation.
+func WithStatsHandlers(hs ...stats.Handler) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, statsHandlerKey{}, hs)
+	}
+}
+
+func getStatsHandlersFromCtx(ctx context.Context) ([]stats.Handler, bool) {
+	hs, ok := ctx.Value(statsHandlerKey{}).([]stats.Handler)
+	return hs, ok
+}
+
+type keepaliveParamsKey struct{}
+
+func WithKeepaliveParams(kp keepalive.ServerParameters) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, keepaliveParamsKey{}, kp)
+	}
+}
+
+func getKeepaliveParamsFromCtx(ctx context.Context) (keepalive.ServerParameters, bool) {
+	kp, ok := ctx.Value(keepaliveParamsKey{}).(keepalive.ServerParameters)
+	return kp, ok
+}
+
+type keepaliveEnforcementPolicyKey struct{}
+
+func WithKeepaliveEnforcementPolicy(kep keepalive.EnforcementPolicy) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, keepaliveEnforcementPolicyKey{}, kep)
+	}
+}
+
+func getKeepaliveEnforcementPolicyFromCtx(ctx context.Context) (keepalive.EnforcementPolicy, bool) {
+	kep, ok := ctx.Value(keepaliveEnforcementPolicyKey{}).(keepalive.EnforcementPolicy)
+	return kep, ok
+}
+
+type maxRecvMsgSizeKey struct{}
+
+func WithMaxRecvMsgSize(n int) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, maxRecvMsgSizeKey{}, n)
+	}
+}
+
+func getMaxRecvMsgSizeFromCtx(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxRecvMsgSizeKey{}).(int)
+	return n, ok
+}
+
+type maxSendMsgSizeKey struct{}
+
+func WithMaxSendMsgSize(n int) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, maxSendMsgSizeKey{}, n)
+	}
+}
+
+func getMaxSendMsgSizeFromCtx(ctx context.Context) (int, bool) {
+	n, ok := ctx.Value(maxSendMsgSizeKey{}).(int)
+	return n, ok
+}
+
+type transportCredentialsKey struct{}
+
+// WithTransportCredentials serves with the given credentials, e.g.
+// credentials.NewServerTLSFromFile, instead of plaintext.
+func WithTransportCredentials(creds credentials.TransportCredentials) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, transportCredentialsKey{}, creds)
+	}
+}
+
+func getTransportCredentialsFromCtx(ctx context.Context) (credentials.TransportCredentials, bool) {
+	creds, ok := ctx.Value(transportCredentialsKey{}).(credentials.TransportCredentials)
+	return creds, ok
+}
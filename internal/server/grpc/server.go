@@ -86,6 +86,8 @@ func (s *grpcServer) Start() error {
 	s.exit = make(chan struct{})
 	s.errCh = make(chan error, 1)
 
+	s.options.Logger.Info("grpc server listening", "address", s.options.Address)
+
 	go func() {
 		if err := s.server.Serve(listener); err != nil {
 			s.errCh <- fmt.Errorf("grpc server Serve error: %w", err)
@@ -98,6 +100,17 @@ func (s *grpcServer) Start() error {
 	return nil
 }
 
+func (s *grpcServer) Check(ctx context.Context) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if !s.isRunning {
+		return errors.New("server not running")
+	}
+
+	return nil
+}
+
 func (s *grpcServer) Stop() error {
 	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer stopCancel()
@@ -144,11 +157,66 @@ func (s *grpcServer) stop(ctx context.Context) error {
 	}
 }
 
+// serverOptions translates the options set via this package's
+// With* functions into grpc.ServerOptions
+func serverOptions(options server.Options) []grpc.ServerOption {
+	var serverOpts []grpc.ServerOption
+
+	if unaries, ok := getUnaryInterceptorsFromCtx(options.Context); ok && len(unaries) > 0 {
+		var chain []grpc.UnaryServerInterceptor
+		for _, u := range unaries {
+			if u != nil {
+				chain = append(chain, u)
+			}
+		}
+		serverOpts = append(serverOpts, grpc.ChainUnaryInterceptor(chain...))
+	}
+
+	if streamies, ok := getStreamInterceptorsFromCtx(options.Context); ok && len(streamies) > 0 {
+		var chain []grpc.StreamServerInterceptor
+		for _, s := range streamies {
+			if s != nil {
+				chain = append(chain, s)
+			}
+		}
+		serverOpts = append(serverOpts, grpc.ChainStreamInterceptor(chain...))
+	}
+
+	if hs, ok := getStatsHandlersFromCtx(options.Context); ok {
+		for _, h := range hs {
+			if h != nil {
+				serverOpts = append(serverOpts, grpc.StatsHandler(h))
+			}
+		}
+	}
+
+	if kp, ok := getKeepaliveParamsFromCtx(options.Context); ok {
+		serverOpts = append(serverOpts, grpc.KeepaliveParams(kp))
+	}
+
+	if kep, ok := getKeepaliveEnforcementPolicyFromCtx(options.Context); ok {
+		serverOpts = append(serverOpts, grpc.KeepaliveEnforcementPolicy(kep))
+	}
+
+	if n, ok := getMaxRecvMsgSizeFromCtx(options.Context); ok {
+		serverOpts = append(serverOpts, grpc.MaxRecvMsgSize(n))
+	}
+
+	if n, ok := getMaxSendMsgSizeFromCtx(options.Context); ok {
+		serverOpts = append(serverOpts, grpc.MaxSendMsgSize(n))
+	}
+
+	if creds, ok := getTransportCredentialsFromCtx(options.Context); ok && creds != nil {
+		serverOpts = append(serverOpts, grpc.Creds(creds))
+	}
+
+	return serverOpts
+}
+
 func NewServer(opts ...server.Option) server.Server {
 	options := server.NewOptions(opts...)
 
-	// add interceptor 'middleware' and otel instrumentation here
-	srv := grpc.NewServer()
+	srv := grpc.NewServer(serverOptions(options)...)
 
 	s := &grpcServer{
 		options: options,
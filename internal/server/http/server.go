@@ -4,6 +4,7 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"log/slog"
 	"net"
 	"net/http"
 	"sync"
@@ -98,6 +99,8 @@ func (s *httpServer) Start() error {
 	s.exit = make(chan struct{})
 	s.errCh = make(chan error, 1)
 
+	s.options.Logger.Info("http server listening", "address", s.options.Address)
+
 	go func() {
 		if err := s.server.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			s.errCh <- fmt.Errorf("http server ListenAndServe error: %w", err)
@@ -110,6 +113,17 @@ func (s *httpServer) Start() error {
 	return nil
 }
 
+func (s *httpServer) Check(ctx context.Context) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if !s.isRunning {
+		return errors.New("server not running")
+	}
+
+	return nil
+}
+
 func (s *httpServer) Stop() error {
 	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
 	defer stopCancel()
@@ -157,8 +171,9 @@ func NewServer(opts ...server.Option) server.Server {
 
 	s := &httpServer{
 		options: options,
-		server:  &http.Server{},
-		mtx:     sync.RWMutex{},
+		// errors net/http can only log, such as handler panics it recovers
+		server: &http.Server{ErrorLog: slog.NewLogLogger(options.Logger.Handler(), slog.LevelError)},
+		mtx:    sync.RWMutex{},
 	}
 
 	return s
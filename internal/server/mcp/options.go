@@ -2,6 +2,8 @@ package mcp
 
 import (
 	"context"
+	"io"
+	"net/http"
 
 	mcpserver "github.com/mark3labs/mcp-go/server"
 	"github.com/w-h-a/demo-go/internal/server"
@@ -32,3 +34,73 @@ func getResourceMiddlewareFromCtx(ctx context.Context) ([]mcpserver.ResourceHand
 	ms, ok := ctx.Value(resourceMiddlewareKey{}).([]mcpserver.ResourceHandlerMiddleware)
 	return ms, ok
 }
+
+type httpMiddlewareKey struct{}
+
+// WithHTTPMiddleware wraps the HTTP transports' handler. It has
+// no effect on stdio.
+func WithHTTPMiddleware(ms ...func(h http.Handler) http.Handler) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, httpMiddlewareKey{}, ms)
+	}
+}
+
+func getHTTPMiddlewareFromCtx(ctx context.Context) ([]func(h http.Handler) http.Handler, bool) {
+	ms, ok := ctx.Value(httpMiddlewareKey{}).([]func(h http.Handler) http.Handler)
+	return ms, ok
+}
+
+type Transport string
+
+const (
+	TransportStreamableHTTP Transport = "streamable-http"
+	TransportSSE            Transport = "sse"
+	TransportStdio          Transport = "stdio"
+)
+
+type transportKey struct{}
+
+func WithTransport(t Transport) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, transportKey{}, t)
+	}
+}
+
+func getTransportFromCtx(ctx context.Context) (Transport, bool) {
+	t, ok := ctx.Value(transportKey{}).(Transport)
+	return t, ok
+}
+
+type basePathKey struct{}
+
+// WithBasePath sets where the HTTP transports are mounted. Streamable HTTP
+// serves the base path itself, SSE serves <base>/sse and <base>/message.
+func WithBasePath(p string) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, basePathKey{}, p)
+	}
+}
+
+func getBasePathFromCtx(ctx context.Context) (string, bool) {
+	p, ok := ctx.Value(basePathKey{}).(string)
+	return p, ok
+}
+
+type stdio struct {
+	in  io.Reader
+	out io.Writer
+}
+
+type stdioKey struct{}
+
+// WithStdio overrides os.Stdin and os.Stdout for the stdio transport.
+func WithStdio(in io.Reader, out io.Writer) server.Option {
+	return func(o *server.Options) {
+		o.Context = context.WithValue(o.Context, stdioKey{}, stdio{in, out})
+	}
+}
+
+func getStdioFromCtx(ctx context.Context) (stdio, bool) {
+	s, ok := ctx.Value(stdioKey{}).(stdio)
+	return s, ok
+}
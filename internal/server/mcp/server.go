@@ -4,8 +4,13 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"io"
+	"log/slog"
 	"net"
 	"net/http"
+	"net/url"
+	"os"
+	"strings"
 	"sync"
 	"time"
 
@@ -13,10 +18,19 @@ import (
 	"github.com/w-h-a/demo-go/internal/server"
 )
 
+const defaultBasePath = "/mcp"
+
 type mcpServer struct {
-	options    server.Options
+	options   server.Options
+	transport Transport
+	basePath  string
+	// err is why the options are invalid. NewServer can't return
+	// it, so Handle and Start do.
+	err        error
 	mcpServer  *mcpserver.MCPServer
 	httpServer *http.Server
+	sseServer  *mcpserver.SSEServer
+	cancel     context.CancelFunc
 	errCh      chan error
 	exit       chan struct{}
 	isRunning  bool
@@ -27,6 +41,10 @@ func (s *mcpServer) Handle(handler any) error {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	if s.err != nil {
+		return s.err
+	}
+
 	if s.isRunning {
 		return errors.New("cannot set handler after server has started")
 	}
@@ -54,6 +72,17 @@ func (s *mcpServer) Handle(handler any) error {
 		}
 		h.Handler = finalHandler
 		s.mcpServer.AddResource(h.Resource, h.Handler)
+	case mcpserver.ServerResourceTemplate:
+		finalHandler := mcpserver.ResourceHandlerFunc(h.Handler)
+		if ms, ok := getResourceMiddlewareFromCtx(s.options.Context); ok && len(ms) > 0 {
+			for i := len(ms) - 1; i >= 0; i-- {
+				if ms[i] != nil {
+					finalHandler = ms[i](finalHandler)
+				}
+			}
+		}
+		h.Handler = mcpserver.ResourceTemplateHandlerFunc(finalHandler)
+		s.mcpServer.AddResourceTemplate(h.Template, h.Handler)
 	default:
 		return fmt.Errorf("invalid handler type for MCP server: %T", handler)
 	}
@@ -90,10 +119,29 @@ func (s *mcpServer) Start() error {
 	s.mtx.Lock()
 	defer s.mtx.Unlock()
 
+	if s.err != nil {
+		return s.err
+	}
+
 	if s.isRunning {
 		return errors.New("server already started")
 	}
 
+	s.exit = make(chan struct{})
+	s.errCh = make(chan error, 1)
+
+	if s.transport == TransportStdio {
+		s.startStdio()
+	} else if err := s.startHTTP(s.transport); err != nil {
+		return err
+	}
+
+	s.isRunning = true
+
+	return nil
+}
+
+func (s *mcpServer) startHTTP(transport Transport) error {
 	listener, err := net.Listen("tcp", s.options.Address)
 	if err != nil {
 		return err
@@ -101,23 +149,85 @@ func (s *mcpServer) Start() error {
 
 	s.options.Address = listener.Addr().String()
 
-	s.exit = make(chan struct{})
-	s.errCh = make(chan error, 1)
+	basePath := s.basePath
+
+	s.httpServer = &http.Server{ErrorLog: slog.NewLogLogger(s.options.Logger.Handler(), slog.LevelError)}
+	s.sseServer = nil
 
 	mux := http.NewServeMux()
-	streamHandler := mcpserver.NewStreamableHTTPServer(s.mcpServer)
-	mux.Handle("/mcp", streamHandler)
 
-	s.httpServer = &http.Server{Handler: mux}
+	if transport == TransportSSE {
+		// the sse server owns httpServer so its Shutdown can end open streams
+		s.sseServer = mcpserver.NewSSEServer(
+			s.mcpServer,
+			mcpserver.WithStaticBasePath(basePath),
+			mcpserver.WithHTTPServer(s.httpServer),
+		)
+		mux.Handle(s.sseServer.CompleteSsePath(), s.sseServer)
+		mux.Handle(s.sseServer.CompleteMessagePath(), s.sseServer)
+	} else {
+		streamHandler := mcpserver.NewStreamableHTTPServer(
+			s.mcpServer,
+			mcpserver.WithEndpointPath(basePath),
+		)
+		mux.Handle(basePath, streamHandler)
+	}
+
+	var handler http.Handler = mux
+	if ms, ok := getHTTPMiddlewareFromCtx(s.options.Context); ok && len(ms) > 0 {
+		for i := len(ms) - 1; i >= 0; i-- {
+			if ms[i] != nil {
+				handler = ms[i](handler)
+			}
+		}
+	}
+
+	s.httpServer.Handler = handler
+
+	s.options.Logger.Info("mcp server listening", "address", s.options.Address, "transport", string(transport), "path", basePath)
 
 	go func() {
 		if err := s.httpServer.Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
-			s.errCh <- fmt.Errorf("mcp sse error: %w", err)
+			s.errCh <- fmt.Errorf("mcp %s error: %w", transport, err)
 		}
 		close(s.exit)
 	}()
 
-	s.isRunning = true
+	return nil
+}
+
+func (s *mcpServer) startStdio() {
+	in, out := io.Reader(os.Stdin), io.Writer(os.Stdout)
+	if stdio, ok := getStdioFromCtx(s.options.Context); ok {
+		in, out = stdio.in, stdio.out
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+
+	stdioServer := mcpserver.NewStdioServer(s.mcpServer)
+	stdioServer.SetErrorLogger(slog.NewLogLogger(s.options.Logger.Handler(), slog.LevelError))
+
+	go func() {
+		err := stdioServer.Listen(ctx, in, out)
+		// a nil error means the client closed stdin, which ends Run cleanly
+		if ctx.Err() == nil {
+			if err != nil {
+				err = fmt.Errorf("mcp stdio error: %w", err)
+			}
+			s.errCh <- err
+		}
+		close(s.exit)
+	}()
+}
+
+func (s *mcpServer) Check(ctx context.Context) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if !s.isRunning {
+		return errors.New("server not running")
+	}
 
 	return nil
 }
@@ -138,11 +248,22 @@ func (s *mcpServer) stop(ctx context.Context) error {
 
 	s.isRunning = false
 	httpServer := s.httpServer
+	sseServer := s.sseServer
+	cancel := s.cancel
 	exit := s.exit
 
 	s.mtx.Unlock()
 
-	shutdownErr := httpServer.Shutdown(ctx)
+	var shutdownErr error
+
+	switch {
+	case cancel != nil:
+		cancel()
+	case sseServer != nil:
+		shutdownErr = sseServer.Shutdown(ctx)
+	default:
+		shutdownErr = httpServer.Shutdown(ctx)
+	}
 
 	var stopErr error
 
@@ -167,13 +288,43 @@ func (s *mcpServer) stop(ctx context.Context) error {
 func NewServer(opts ...server.Option) server.Server {
 	options := server.NewOptions(opts...)
 
-	// TODO: validate options
+	transport, basePath, err := validateOptions(options)
 
 	s := &mcpServer{
 		options:   options,
+		transport: transport,
+		basePath:  basePath,
+		err:       err,
 		mcpServer: mcpserver.NewMCPServer(options.Name, options.Version),
 		mtx:       sync.RWMutex{},
 	}
 
 	return s
 }
+
+// validateOptions returns the transport and base path to serve,
+// failing on ones Start couldn't
+func validateOptions(options server.Options) (Transport, string, error) {
+	transport := TransportStreamableHTTP
+	if t, ok := getTransportFromCtx(options.Context); ok {
+		transport = t
+	}
+
+	switch transport {
+	case TransportStreamableHTTP, TransportSSE, TransportStdio:
+	default:
+		return "", "", fmt.Errorf("unsupported mcp transport: %q", transport)
+	}
+
+	basePath := defaultBasePath
+	if p, ok := getBasePathFromCtx(options.Context); ok && p != "" {
+		basePath = "/" + strings.Trim(p, "/")
+	}
+
+	// the path becomes a ServeMux pattern, so it can't hold wildcards
+	if u, err := url.Parse(basePath); err != nil || u.Path != basePath || strings.ContainsAny(basePath, "{} \t") {
+		return "", "", fmt.Errorf("invalid mcp base path: %q", basePath)
+	}
+
+	return transport, basePath, nil
+}
@@ -1,6 +1,9 @@
 package server
 
-import "context"
+import (
+	"context"
+	"log/slog"
+)
 
 type Option func(*Options)
 
@@ -8,6 +11,7 @@ type Options struct {
 	Address string
 	Name    string
 	Version string
+	Logger  *slog.Logger
 	Context context.Context
 }
 
@@ -29,9 +33,17 @@ func WithVersion(version string) Option {
 	}
 }
 
+// WithLogger sets the logger the server reports its own errors with.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
 func NewOptions(opts ...Option) Options {
 	options := Options{
 		Address: ":0",
+		Logger:  slog.Default(),
 		Context: context.Background(),
 	}
 
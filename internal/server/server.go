@@ -1,8 +1,12 @@
 package server
 
+import "context"
+
 type Server interface {
 	Handle(handler any) error
 	Run(stop chan struct{}) error
 	Start() error
 	Stop() error
+	// Check returns an error unless the server is serving.
+	Check(ctx context.Context) error
 }
@@ -0,0 +1,219 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	"github.com/w-h-a/demo-go/internal/middleware"
+)
+
+const (
+	// apiKeyPrefix marks keys as ours, so authenticators can tell
+	// them from other bearer tokens without a lookup
+	apiKeyPrefix = "dgk_"
+	// apiKeyPrefixLength is how much of a key is kept in the clear
+	apiKeyPrefixLength = len(apiKeyPrefix) + 8
+	// apiKeyTouchInterval is how stale last used times may get,
+	// sparing a write on every request
+	apiKeyTouchInterval = time.Minute
+)
+
+// CreateAPIKey is the business logic for creating an API key for a
+// service to act as its owner, limited to the scopes given. The key
+// is returned this once; only its hash is stored.
+func (s *Service) CreateAPIKey(ctx context.Context, dto user.CreateAPIKeyDTO) (_ user.CreatedAPIKey, err error) {
+	ctx, span := s.startSpan(ctx, "CreateAPIKey")
+	defer endSpan(span, &err)
+
+	principal, _ := middleware.GetUserFromCtx(ctx)
+
+	ownerID := dto.UserID
+	if len(ownerID) == 0 {
+		ownerID = principal.ID
+	}
+
+	if err := s.authorize(ctx, authz.ActionManageAPIKeys, ownerID); err != nil {
+		return user.CreatedAPIKey{}, err
+	}
+
+	name := strings.TrimSpace(dto.Name)
+	if name == "" || len(dto.Scopes) == 0 {
+		return user.CreatedAPIKey{}, ErrInvalidAPIKey
+	}
+
+	for _, scope := range dto.Scopes {
+		if !authz.Action(scope).Valid() {
+			return user.CreatedAPIKey{}, fmt.Errorf("%w: unknown scope %q", ErrInvalidAPIKey, scope)
+		}
+		// a key can't mint keys reaching further than itself
+		if principal.Scopes != nil && !slices.Contains(principal.Scopes, scope) {
+			return user.CreatedAPIKey{}, fmt.Errorf("%w: %s", ErrForbidden, scope)
+		}
+	}
+
+	now := time.Now()
+	latest := now.Add(s.options.APIKeyMaxTTL)
+
+	expiresAt := latest
+	if dto.ExpiresAt != nil {
+		expiresAt = *dto.ExpiresAt
+	}
+
+	if !expiresAt.After(now) || expiresAt.After(latest) {
+		return user.CreatedAPIKey{}, ErrInvalidAPIKey
+	}
+
+	if _, err := s.get(ctx, ownerID); err != nil {
+		return user.CreatedAPIKey{}, err
+	}
+
+	token, err := newToken()
+	if err != nil {
+		return user.CreatedAPIKey{}, err
+	}
+
+	key := apiKeyPrefix + token
+
+	stored := userrepo.APIKey{
+		ID:        uuid.NewString(),
+		UserID:    ownerID,
+		Name:      name,
+		Prefix:    key[:apiKeyPrefixLength],
+		KeyHash:   hashToken(key),
+		Scopes:    slices.Compact(slices.Sorted(slices.Values(dto.Scopes))),
+		ExpiresAt: expiresAt.UTC(),
+	}
+
+	err = s.repo.CreateAPIKey(ctx, stored)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.CreatedAPIKey{}, ErrUserNotFound
+	}
+	if err != nil {
+		return user.CreatedAPIKey{}, err
+	}
+
+	stored.CreatedAt = now.UTC()
+
+	return user.CreatedAPIKey{APIKey: toAPIKey(stored), Key: key}, nil
+}
+
+// ListAPIKeys is the business logic for listing the API keys of the
+// user with userID, or of the caller when it is empty.
+func (s *Service) ListAPIKeys(ctx context.Context, userID string) (_ []user.APIKey, err error) {
+	ctx, span := s.startSpan(ctx, "ListAPIKeys")
+	defer endSpan(span, &err)
+
+	if len(userID) == 0 {
+		principal, _ := middleware.GetUserFromCtx(ctx)
+		userID = principal.ID
+	}
+
+	if err := s.authorize(ctx, authz.ActionManageAPIKeys, userID); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.repo.ListAPIKeys(ctx, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	keys := make([]user.APIKey, 0, len(stored))
+	for _, k := range stored {
+		keys = append(keys, toAPIKey(k))
+	}
+
+	return keys, nil
+}
+
+// DeleteAPIKey is the business logic for revoking an API key.
+func (s *Service) DeleteAPIKey(ctx context.Context, id string) (err error) {
+	ctx, span := s.startSpan(ctx, "DeleteAPIKey")
+	defer endSpan(span, &err)
+
+	key, err := s.repo.GetAPIKey(ctx, id)
+	if errors.Is(err, userrepo.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := s.authorize(ctx, authz.ActionManageAPIKeys, key.UserID); err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteAPIKey(ctx, id)
+	if errors.Is(err, userrepo.ErrAPIKeyNotFound) {
+		return ErrAPIKeyNotFound
+	}
+
+	return err
+}
+
+// IsAPIKey reports whether key looks like one of our API keys.
+// It doesn't check the key is valid.
+func (s *Service) IsAPIKey(key string) bool {
+	return strings.HasPrefix(key, apiKeyPrefix)
+}
+
+// VerifyAPIKey returns the owner of an unexpired API key, carrying
+// the key's scopes, and records that the key was used.
+func (s *Service) VerifyAPIKey(ctx context.Context, key string) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "VerifyAPIKey")
+	defer endSpan(span, &err)
+
+	stored, err := s.repo.GetAPIKeyByHash(ctx, hashToken(key))
+	if errors.Is(err, userrepo.ErrAPIKeyNotFound) {
+		return user.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	now := time.Now()
+
+	if !stored.Active(now) {
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	u, err := s.repo.GetByID(ctx, stored.UserID)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if stored.LastUsedAt == nil || now.Sub(*stored.LastUsedAt) >= apiKeyTouchInterval {
+		// failing to record use shouldn't fail the request
+		if err := s.repo.TouchAPIKey(ctx, stored.ID, now); err != nil {
+			s.options.Logger.ErrorContext(ctx, "failed to record use of api key", "api_key_id", stored.ID, "error", err)
+		}
+	}
+
+	// never nil, so an empty scope list still restricts
+	u.Scopes = append([]string{}, stored.Scopes...)
+
+	return u, nil
+}
+
+func toAPIKey(k userrepo.APIKey) user.APIKey {
+	return user.APIKey{
+		ID:         k.ID,
+		UserID:     k.UserID,
+		Name:       k.Name,
+		Prefix:     k.Prefix,
+		Scopes:     k.Scopes,
+		ExpiresAt:  k.ExpiresAt,
+		LastUsedAt: k.LastUsedAt,
+		CreatedAt:  k.CreatedAt,
+	}
+}
@@ -3,7 +3,47 @@ package user
 import "errors"
 
 var (
-	ErrUserNotFound = errors.New("user not found")
-	ErrEmailInUse   = errors.New("email already in use")
-	ErrInvalidInput = errors.New("invalid input: name and email are required")
+	ErrUserNotFound     = errors.New("user not found")
+	ErrEmailInUse       = errors.New("email already in use")
+	ErrInvalidInput     = errors.New("invalid input: name and email are required")
+	ErrInvalidListQuery = errors.New("invalid list query")
+	ErrInvalidRole      = errors.New("invalid role")
+	ErrForbidden        = errors.New("forbidden")
+	ErrInvalidPassword  = errors.New("invalid password: must be at least 8 characters")
+	// ErrInvalidCredentials covers a wrong email or password and
+	// invalid, expired or revoked tokens alike
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrEmailNotVerified   = errors.New("email not verified")
+	// ErrInvalidVerificationToken covers unknown and already used tokens
+	ErrInvalidVerificationToken = errors.New("invalid verification token")
+	ErrVerificationTokenExpired = errors.New("verification token expired")
+	ErrVerificationThrottled    = errors.New("verification email sent too recently")
+	ErrEmailAlreadyVerified     = errors.New("email already verified")
+	// ErrInvalidToken covers unknown, used and expired password
+	// reset and magic link tokens alike
+	ErrInvalidToken      = errors.New("invalid or expired token")
+	ErrMagicLinkDisabled = errors.New("magic link login is disabled")
+	// ErrTOTPRequired means the credentials were right but a second
+	// factor is needed
+	ErrTOTPRequired    = errors.New("totp code required")
+	ErrInvalidTOTPCode = errors.New("invalid totp code")
+	// ErrTOTPLocked means too many invalid codes were tried in a row,
+	// so every code is refused for a while
+	ErrTOTPLocked         = errors.New("too many invalid totp codes, try again later")
+	ErrTOTPNotEnrolled    = errors.New("totp not enrolled")
+	ErrTOTPAlreadyEnabled = errors.New("totp already enabled")
+	ErrAPIKeyNotFound     = errors.New("api key not found")
+	// ErrInvalidAPIKey covers a missing name, unknown or missing scopes
+	// and expiries in the past or beyond the longest allowed
+	ErrInvalidAPIKey = errors.New("invalid api key: name, valid scopes and an expiry within the allowed lifetime are required")
+	// ErrInvalidWebhook covers bad urls, unknown events and short secrets
+	ErrInvalidWebhook          = errors.New("invalid webhook: an http(s) url, known events and a secret of at least 16 characters are required")
+	ErrWebhookNotFound         = errors.New("webhook not found")
+	ErrWebhookDeliveryNotFound = errors.New("webhook delivery not found")
+	// ErrWebhookDeliveryPending means the delivery is still being tried
+	ErrWebhookDeliveryPending = errors.New("webhook delivery still pending")
+	ErrWebhooksDisabled       = errors.New("webhooks are disabled")
+	// ErrBusy means background work couldn't be queued, because
+	// the queue stayed full or the service is stopping
+	ErrBusy = errors.New("too busy, try again later")
 )
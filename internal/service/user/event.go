@@ -0,0 +1,84 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+// The events sent to the event notifier and subscribed webhooks,
+// each with the user as it is after the change as its payload
+const (
+	EventUserCreated = "user.created"
+	EventUserUpdated = "user.updated"
+	EventUserDeleted = "user.deleted"
+)
+
+// Events returns every event, for validating subscriptions.
+func Events() []string {
+	return []string{EventUserCreated, EventUserUpdated, EventUserDeleted}
+}
+
+// event returns the EventFunc that builds event for the event notifier
+// and every webhook subscribed to it, or nil when nobody listens. It
+// is passed to the repo with the change, so the event is stored if
+// and only if the change is. Each delivery of the event shares its ID.
+func (s *Service) event(ctx context.Context, event string) (userrepo.EventFunc, error) {
+	if s.options.EventNotifier == nil && s.options.WebhookNotifier == nil {
+		return nil, nil
+	}
+
+	var webhooks []userrepo.Webhook
+
+	if s.options.WebhookNotifier != nil {
+		all, err := s.repo.ListWebhooks(ctx)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, w := range all {
+			if w.Subscribed(event) {
+				webhooks = append(webhooks, w)
+			}
+		}
+	}
+
+	return func(u user.User) (userrepo.Event, error) {
+		payload, err := json.Marshal(u)
+		if err != nil {
+			return userrepo.Event{}, err
+		}
+
+		eventID := uuid.NewString()
+
+		var e userrepo.Event
+
+		if s.options.EventNotifier != nil {
+			e.Messages = append(e.Messages, userrepo.OutboxMessage{
+				ID:          eventID,
+				Name:        u.Name,
+				Email:       u.Email,
+				Event:       event,
+				Payload:     payload,
+				TraceParent: traceParent(ctx),
+			})
+		}
+
+		for _, w := range webhooks {
+			d := userrepo.WebhookDelivery{
+				ID:        uuid.NewString(),
+				WebhookID: w.ID,
+				EventID:   eventID,
+				Event:     event,
+				Payload:   payload,
+			}
+
+			e.Deliveries = append(e.Deliveries, userrepo.EventDelivery{Delivery: d, Message: newDeliveryMessage(ctx, d)})
+		}
+
+		return e, nil
+	}, nil
+}
@@ -0,0 +1,61 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"slices"
+
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	"github.com/w-h-a/demo-go/internal/health"
+)
+
+// Check returns an error unless the service is running, and so
+// relaying notifications from the outbox.
+func (s *Service) Check(ctx context.Context) error {
+	s.mtx.RLock()
+	defer s.mtx.RUnlock()
+
+	if !s.isRunning {
+		return errors.New("user service not running")
+	}
+
+	return nil
+}
+
+// RegisterChecks adds the service and its clients to r. Only the
+// service and its repo are critical, as notifications wait in the
+// outbox while notifiers are down.
+func (s *Service) RegisterChecks(r *health.Registry) error {
+	if err := r.Register("user_service", s); err != nil {
+		return err
+	}
+
+	if err := r.Register("user_repo", s.repo); err != nil {
+		return err
+	}
+
+	notifiers := []struct {
+		name     string
+		notifier notifier.Notifier
+	}{
+		{"notifier", s.notifier},
+		{"event_notifier", s.options.EventNotifier},
+		{"webhook_notifier", s.options.WebhookNotifier},
+	}
+
+	var checked []notifier.Notifier
+
+	// each notifier once, like closeClients
+	for _, n := range notifiers {
+		if n.notifier == nil || slices.Contains(checked, n.notifier) {
+			continue
+		}
+		checked = append(checked, n.notifier)
+
+		if err := r.RegisterOptional(n.name, n.notifier); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
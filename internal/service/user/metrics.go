@@ -0,0 +1,35 @@
+package user
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/w-h-a/demo-go/internal/metrics"
+)
+
+// serviceMetrics count the side effects of the service, which callers
+// never see fail. Services sharing a registry share them.
+type serviceMetrics struct {
+	// notifications counts outbox messages sent, by channel (email,
+	// event or webhook) and result (sent, failed or dropped)
+	notifications *prometheus.CounterVec
+	// queuedTasks counts background work waiting for a worker
+	queuedTasks prometheus.Gauge
+	// rejectedTasks counts background work turned away with ErrBusy
+	rejectedTasks prometheus.Counter
+}
+
+func newServiceMetrics(reg prometheus.Registerer) serviceMetrics {
+	return serviceMetrics{
+		notifications: metrics.Register(reg, prometheus.NewCounterVec(prometheus.CounterOpts{
+			Name: "user_notifications_total",
+			Help: "Attempts at sending notifications, by channel and result.",
+		}, []string{"channel", "result"})),
+		queuedTasks: metrics.Register(reg, prometheus.NewGauge(prometheus.GaugeOpts{
+			Name: "user_background_tasks_queued",
+			Help: "Background side effects, such as queueing emails, waiting for a worker.",
+		})),
+		rejectedTasks: metrics.Register(reg, prometheus.NewCounter(prometheus.CounterOpts{
+			Name: "user_background_tasks_rejected_total",
+			Help: "Background side effects turned away, as the queue was full.",
+		})),
+	}
+}
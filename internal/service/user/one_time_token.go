@@ -0,0 +1,228 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+// RequestPasswordReset is the business logic for emailing a password
+// reset token. It succeeds whether or not the email belongs to a user,
+// and sends in the background, so callers can't tell which it was.
+// It fails with ErrBusy when too much is already waiting to be sent.
+func (s *Service) RequestPasswordReset(ctx context.Context, dto user.PasswordResetRequestDTO) (err error) {
+	ctx, span := s.startSpan(ctx, "RequestPasswordReset")
+	defer endSpan(span, &err)
+
+	email := strings.ToLower(strings.TrimSpace(dto.Email))
+	if email == "" {
+		return ErrInvalidInput
+	}
+
+	return s.sendOneTimeToken(ctx, email, userrepo.PurposePasswordReset, s.options.PasswordResetTTL, notifier.KindPasswordReset)
+}
+
+// ConfirmPasswordReset is the business logic for setting a new password
+// with a reset token. The token is used up, and every session of the
+// user is revoked.
+func (s *Service) ConfirmPasswordReset(ctx context.Context, dto user.PasswordResetConfirmDTO) (err error) {
+	ctx, span := s.startSpan(ctx, "ConfirmPasswordReset")
+	defer endSpan(span, &err)
+
+	// checked first, so a too-short password doesn't use up the token
+	if len(dto.Password) < minPasswordLength {
+		return ErrInvalidPassword
+	}
+
+	u, err := s.consumeOneTimeToken(ctx, userrepo.PurposePasswordReset, dto.Token)
+	if err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(dto.Password)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetPasswordHash(ctx, u.ID, hash); err != nil {
+		return err
+	}
+
+	return s.repo.RevokeUserSessions(ctx, u.ID)
+}
+
+// RequestMagicLink is the business logic for emailing a login token.
+// Like RequestPasswordReset, it doesn't reveal whether the email
+// belongs to a user.
+func (s *Service) RequestMagicLink(ctx context.Context, dto user.MagicLinkRequestDTO) (err error) {
+	ctx, span := s.startSpan(ctx, "RequestMagicLink")
+	defer endSpan(span, &err)
+
+	if !s.options.MagicLinkEnabled {
+		return ErrMagicLinkDisabled
+	}
+
+	email := strings.ToLower(strings.TrimSpace(dto.Email))
+	if email == "" {
+		return ErrInvalidInput
+	}
+
+	return s.sendOneTimeToken(ctx, email, userrepo.PurposeMagicLink, s.options.MagicLinkTTL, notifier.KindMagicLink)
+}
+
+// LoginWithMagicLink is the business logic for exchanging a login token
+// for a new session's tokens, just like Login.
+func (s *Service) LoginWithMagicLink(ctx context.Context, dto user.MagicLinkLoginDTO) (_ user.Tokens, err error) {
+	ctx, span := s.startSpan(ctx, "LoginWithMagicLink")
+	defer endSpan(span, &err)
+
+	if !s.options.MagicLinkEnabled {
+		return user.Tokens{}, ErrMagicLinkDisabled
+	}
+
+	// looked up rather than consumed, so a missing or mistyped second
+	// factor leaves the link to be tried again
+	u, err := s.findOneTimeToken(ctx, userrepo.PurposeMagicLink, dto.Token)
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	// receiving the token proves control of the email, but not in a
+	// way that marks it verified, so the same rule as Login applies
+	if s.options.RequireVerifiedEmail && !u.EmailVerified {
+		return user.Tokens{}, ErrEmailNotVerified
+	}
+
+	// an emailed link is only one factor, so a second is still needed
+	if err := s.checkSecondFactor(ctx, u.ID, dto.TOTPCode, dto.RecoveryCode); err != nil {
+		return user.Tokens{}, err
+	}
+
+	// consumed only now, and only once, should the link be used twice at once
+	_, err = s.repo.ConsumeOneTimeToken(ctx, userrepo.PurposeMagicLink, hashToken(dto.Token))
+	if errors.Is(err, userrepo.ErrTokenNotFound) {
+		return user.Tokens{}, ErrInvalidToken
+	}
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	return s.startSession(ctx, u.ID)
+}
+
+// sendOneTimeToken queues an email with a new token for purpose to the
+// user with email, if there is one, in the background. Requests arriving within the
+// resend interval of the last token are dropped. It fails only when the
+// background work can't be queued.
+func (s *Service) sendOneTimeToken(ctx context.Context, email string, purpose userrepo.TokenPurpose, ttl time.Duration, kind notifier.Kind) error {
+	// the pool's context, as the request is answered before this runs
+	return s.background(ctx, func(ctx context.Context) {
+		ctx, span := s.startSpan(ctx, "sendOneTimeToken")
+
+		var err error
+		defer endSpan(span, &err)
+
+		err = func() error {
+			u, err := s.repo.GetByEmail(ctx, email)
+			if errors.Is(err, userrepo.ErrUserNotFound) {
+				return nil
+			}
+			if err != nil {
+				return err
+			}
+
+			last, err := s.repo.GetOneTimeToken(ctx, u.ID, purpose)
+			if err != nil && !errors.Is(err, userrepo.ErrTokenNotFound) {
+				return err
+			}
+			if err == nil && time.Since(last.CreatedAt) < s.options.OneTimeTokenResendInterval {
+				return nil
+			}
+
+			token, err := newToken()
+			if err != nil {
+				return err
+			}
+
+			err = s.repo.SaveOneTimeToken(ctx, userrepo.OneTimeToken{
+				UserID:    u.ID,
+				Purpose:   purpose,
+				Email:     u.Email,
+				TokenHash: hashToken(token),
+				ExpiresAt: time.Now().Add(ttl),
+			})
+			if err != nil {
+				return err
+			}
+
+			return s.enqueue(ctx, u, kind, token)
+		}()
+		if err != nil {
+			// In a real app, we'd log this to a proper monitoring service.
+			s.options.Logger.ErrorContext(ctx, "failed to queue email", "kind", kind, "error", err)
+		}
+	})
+}
+
+// consumeOneTimeToken uses up a token issued for purpose and returns
+// its user, provided it is valid, like findOneTimeToken
+func (s *Service) consumeOneTimeToken(ctx context.Context, purpose userrepo.TokenPurpose, token string) (user.User, error) {
+	if token == "" {
+		return user.User{}, ErrInvalidToken
+	}
+
+	t, err := s.repo.ConsumeOneTimeToken(ctx, purpose, hashToken(token))
+	if errors.Is(err, userrepo.ErrTokenNotFound) {
+		return user.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	return s.oneTimeTokenUser(ctx, t)
+}
+
+// findOneTimeToken returns the user of a token issued for purpose,
+// provided it is valid, without using it up
+func (s *Service) findOneTimeToken(ctx context.Context, purpose userrepo.TokenPurpose, token string) (user.User, error) {
+	if token == "" {
+		return user.User{}, ErrInvalidToken
+	}
+
+	t, err := s.repo.GetOneTimeTokenByHash(ctx, purpose, hashToken(token))
+	if errors.Is(err, userrepo.ErrTokenNotFound) {
+		return user.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	return s.oneTimeTokenUser(ctx, t)
+}
+
+// oneTimeTokenUser returns the user of t, provided t hasn't expired and
+// the user still has the email it was sent to
+func (s *Service) oneTimeTokenUser(ctx context.Context, t userrepo.OneTimeToken) (user.User, error) {
+	if !time.Now().Before(t.ExpiresAt) {
+		return user.User{}, ErrInvalidToken
+	}
+
+	u, err := s.repo.GetByID(ctx, t.UserID)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, ErrInvalidToken
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if u.Email != t.Email {
+		return user.User{}, ErrInvalidToken
+	}
+
+	return u, nil
+}
@@ -0,0 +1,255 @@
+package user
+
+import (
+	"log/slog"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/w-h-a/demo-go/internal/authz"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+type Option func(*Options)
+
+type Options struct {
+	Logger          *slog.Logger
+	TracerProvider  trace.TracerProvider
+	Registerer      prometheus.Registerer
+	Authorizer      authz.Authorizer
+	TokenSecret     []byte
+	TokenIssuer     string
+	AccessTokenTTL  time.Duration
+	RefreshTokenTTL time.Duration
+	VerificationTTL time.Duration
+	// VerificationResendInterval is the least time between
+	// verification emails to the same user
+	VerificationResendInterval time.Duration
+	RequireVerifiedEmail       bool
+	PasswordResetTTL           time.Duration
+	MagicLinkEnabled           bool
+	MagicLinkTTL               time.Duration
+	// OneTimeTokenResendInterval is the least time between password
+	// reset emails, or magic link emails, to the same user
+	OneTimeTokenResendInterval time.Duration
+	// TOTPMaxFailures is how many invalid second factor codes in a
+	// row lock it for TOTPLockout
+	TOTPMaxFailures int
+	TOTPLockout     time.Duration
+	// APIKeyMaxTTL is the longest an API key may live
+	APIKeyMaxTTL time.Duration
+	// OutboxPollInterval is how often the relay looks for due
+	// notifications, besides whenever one is enqueued
+	OutboxPollInterval time.Duration
+	// OutboxMaxAttempts is how many times a notification is tried
+	// before it is dead-lettered
+	OutboxMaxAttempts int
+	// OutboxBackoff is the wait after a first failed attempt,
+	// doubling with each further one
+	OutboxBackoff time.Duration
+	// EventNotifier is told about changes to users, through the
+	// outbox. Without one, no events are sent.
+	EventNotifier notifier.Notifier
+	// WebhookNotifier posts events to the webhooks subscribed through
+	// the API. Without one, subscriptions can't be made.
+	WebhookNotifier notifier.Notifier
+	// Workers is how many side effects, such as queueing emails,
+	// run at once in the background
+	Workers int
+	// WorkerQueueSize is how many side effects may wait for a worker
+	// before callers wait for room
+	WorkerQueueSize int
+	// ShutdownTimeout bounds how long Stop waits for background
+	// work before abandoning it
+	ShutdownTimeout time.Duration
+}
+
+// WithLogger sets the logger the service reports with.
+func WithLogger(l *slog.Logger) Option {
+	return func(o *Options) {
+		o.Logger = l
+	}
+}
+
+// WithTracerProvider sets where the service's spans go. Without one,
+// they go to the global tracer provider.
+func WithTracerProvider(tp trace.TracerProvider) Option {
+	return func(o *Options) {
+		o.TracerProvider = tp
+	}
+}
+
+// WithRegisterer sets where the service's metrics, such as sent and
+// failed notifications, are registered. Without one, they are kept
+// but not exposed.
+func WithRegisterer(reg prometheus.Registerer) Option {
+	return func(o *Options) {
+		o.Registerer = reg
+	}
+}
+
+// WithAuthorizer sets the authorizer consulted before every operation.
+// Without one, every operation is allowed.
+func WithAuthorizer(a authz.Authorizer) Option {
+	return func(o *Options) {
+		o.Authorizer = a
+	}
+}
+
+// WithTokenSecret sets the HMAC key access tokens are signed with.
+// Without one, a random key is generated, so tokens don't survive
+// a restart or work across replicas.
+func WithTokenSecret(secret []byte) Option {
+	return func(o *Options) {
+		o.TokenSecret = secret
+	}
+}
+
+// WithTokenIssuer sets the iss claim of access tokens.
+func WithTokenIssuer(issuer string) Option {
+	return func(o *Options) {
+		o.TokenIssuer = issuer
+	}
+}
+
+// WithTokenTTLs sets how long access and refresh tokens last.
+func WithTokenTTLs(access time.Duration, refresh time.Duration) Option {
+	return func(o *Options) {
+		o.AccessTokenTTL = access
+		o.RefreshTokenTTL = refresh
+	}
+}
+
+// WithVerification sets how long email verification tokens last and
+// how often they may be resent.
+func WithVerification(ttl time.Duration, resendInterval time.Duration) Option {
+	return func(o *Options) {
+		o.VerificationTTL = ttl
+		o.VerificationResendInterval = resendInterval
+	}
+}
+
+// WithRequireVerifiedEmail sets whether users must verify their
+// email before they can log in.
+func WithRequireVerifiedEmail(require bool) Option {
+	return func(o *Options) {
+		o.RequireVerifiedEmail = require
+	}
+}
+
+// WithPasswordResetTTL sets how long password reset tokens last.
+func WithPasswordResetTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.PasswordResetTTL = ttl
+	}
+}
+
+// WithMagicLink enables passwordless login with emailed tokens
+// that last ttl.
+func WithMagicLink(enabled bool, ttl time.Duration) Option {
+	return func(o *Options) {
+		o.MagicLinkEnabled = enabled
+		o.MagicLinkTTL = ttl
+	}
+}
+
+// WithOneTimeTokenResendInterval sets how often password reset and
+// magic link tokens may be emailed to the same user.
+func WithOneTimeTokenResendInterval(interval time.Duration) Option {
+	return func(o *Options) {
+		o.OneTimeTokenResendInterval = interval
+	}
+}
+
+// WithTOTPLockout sets how many invalid second factor codes in a row
+// refuse every code for lockout, valid or not.
+func WithTOTPLockout(maxFailures int, lockout time.Duration) Option {
+	return func(o *Options) {
+		o.TOTPMaxFailures = maxFailures
+		o.TOTPLockout = lockout
+	}
+}
+
+// WithAPIKeyMaxTTL sets the longest an API key may live,
+// which is also how long keys without an expiry get.
+func WithAPIKeyMaxTTL(ttl time.Duration) Option {
+	return func(o *Options) {
+		o.APIKeyMaxTTL = ttl
+	}
+}
+
+// WithOutboxRelay sets how often the relay polls for notifications,
+// how many attempts each gets, and the backoff after the first failure.
+func WithOutboxRelay(pollInterval time.Duration, maxAttempts int, backoff time.Duration) Option {
+	return func(o *Options) {
+		o.OutboxPollInterval = pollInterval
+		o.OutboxMaxAttempts = maxAttempts
+		o.OutboxBackoff = backoff
+	}
+}
+
+// WithEventNotifier sets a notifier, such as a webhook one, to tell
+// other systems when users are created, updated and deleted.
+func WithEventNotifier(n notifier.Notifier) Option {
+	return func(o *Options) {
+		o.EventNotifier = n
+	}
+}
+
+// WithWebhookNotifier sets the notifier events are posted to subscribed
+// webhooks with. It must honour notifier.WithEndpoint.
+func WithWebhookNotifier(n notifier.Notifier) Option {
+	return func(o *Options) {
+		o.WebhookNotifier = n
+	}
+}
+
+// WithWorkers sets how many background side effects run at once
+// and how many may wait for a worker.
+func WithWorkers(workers int, queueSize int) Option {
+	return func(o *Options) {
+		o.Workers = workers
+		o.WorkerQueueSize = queueSize
+	}
+}
+
+// WithShutdownTimeout sets how long Stop waits for background work
+// to finish before abandoning it.
+func WithShutdownTimeout(d time.Duration) Option {
+	return func(o *Options) {
+		o.ShutdownTimeout = d
+	}
+}
+
+func NewOptions(opts ...Option) Options {
+	options := Options{
+		Logger:                     slog.Default(),
+		TracerProvider:             otel.GetTracerProvider(),
+		Registerer:                 prometheus.NewRegistry(),
+		Authorizer:                 authz.AllowAll(),
+		TokenIssuer:                "demo-go",
+		AccessTokenTTL:             15 * time.Minute,
+		RefreshTokenTTL:            30 * 24 * time.Hour,
+		VerificationTTL:            24 * time.Hour,
+		VerificationResendInterval: time.Minute,
+		PasswordResetTTL:           time.Hour,
+		MagicLinkTTL:               15 * time.Minute,
+		OneTimeTokenResendInterval: time.Minute,
+		TOTPMaxFailures:            5,
+		TOTPLockout:                15 * time.Minute,
+		APIKeyMaxTTL:               90 * 24 * time.Hour,
+		OutboxPollInterval:         time.Second,
+		OutboxMaxAttempts:          8,
+		OutboxBackoff:              time.Second,
+		Workers:                    4,
+		WorkerQueueSize:            256,
+		ShutdownTimeout:            10 * time.Second,
+	}
+
+	for _, fn := range opts {
+		fn(&options)
+	}
+
+	return options
+}
@@ -0,0 +1,186 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+const (
+	outboxBatchSize = 10
+	// outboxLease hides a claimed message from other relays while
+	// it is sent. It must outlast outboxSendTimeout.
+	outboxLease       = time.Minute
+	outboxSendTimeout = 30 * time.Second
+	outboxMaxBackoff  = time.Hour
+)
+
+// enqueue stores a message of kind for u in the outbox, for the relay
+// to send. Tokens are kept in the clear until it does, as they can't
+// be sent otherwise.
+func (s *Service) enqueue(ctx context.Context, u user.User, kind notifier.Kind, token string) error {
+	if err := s.repo.EnqueueOutboxMessage(ctx, newOutboxMessage(ctx, u.Name, u.Email, kind, token)); err != nil {
+		return err
+	}
+
+	s.wakeRelay()
+
+	return nil
+}
+
+func newOutboxMessage(ctx context.Context, name string, email string, kind notifier.Kind, token string) userrepo.OutboxMessage {
+	return userrepo.OutboxMessage{
+		ID:          uuid.NewString(),
+		Kind:        string(kind),
+		Name:        name,
+		Email:       email,
+		Token:       token,
+		TraceParent: traceParent(ctx),
+	}
+}
+
+// wakeRelay has the relay look for messages now rather than at its
+// next poll. It never blocks; a wake already pending covers this one.
+func (s *Service) wakeRelay() {
+	select {
+	case s.wake <- struct{}{}:
+	default:
+	}
+}
+
+// relay sends outbox messages until stop is closed, then closes done
+func (s *Service) relay(stop <-chan struct{}, done chan<- struct{}) {
+	defer close(done)
+
+	ticker := time.NewTicker(s.options.OutboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		s.drainOutbox(stop)
+
+		select {
+		case <-stop:
+			// a last pass for what was queued while stopping, which
+			// a nil stop lets run to the end
+			s.drainOutbox(nil)
+			return
+		case <-ticker.C:
+		case <-s.wake:
+		}
+	}
+}
+
+// drainOutbox sends due messages a batch at a time until none are left
+func (s *Service) drainOutbox(stop <-chan struct{}) {
+	for {
+		select {
+		case <-stop:
+			return
+		default:
+		}
+
+		messages, err := s.repo.ClaimOutboxMessages(context.Background(), outboxBatchSize, outboxLease)
+		if err != nil {
+			s.options.Logger.Error("failed to claim outbox messages", "error", err)
+			return
+		}
+
+		for _, m := range messages {
+			s.deliver(m)
+		}
+
+		if len(messages) < outboxBatchSize {
+			return
+		}
+	}
+}
+
+// deliver sends m, then removes it, schedules a retry or dead-letters
+// it. Each attempt is a span in the trace of the request that queued m.
+func (s *Service) deliver(m userrepo.OutboxMessage) {
+	ctx, cancel := context.WithTimeout(withTraceParent(context.Background(), m.TraceParent), outboxSendTimeout)
+	defer cancel()
+
+	var sendErr error
+
+	ctx, span := s.startSpan(ctx, "deliver")
+	defer endSpan(span, &sendErr)
+
+	span.SetAttributes(
+		attribute.String("outbox.id", m.ID),
+		attribute.Int("outbox.attempt", m.Attempts),
+	)
+
+	what := m.Kind + " email"
+	channel, result := "email", "sent"
+	// record tells webhook deliveries how they went
+	record := func(userrepo.DeliveryStatus) {}
+
+	switch {
+	case len(m.DeliveryID) > 0:
+		what = m.Event + " webhook delivery"
+		channel = "webhook"
+		record, sendErr = s.sendWebhook(ctx, m)
+	case len(m.Event) > 0:
+		what = m.Event + " event"
+		channel = "event"
+		if s.options.EventNotifier == nil {
+			// events queued before a restart without an event notifier
+			s.options.Logger.WarnContext(ctx, "dropping event, as there is no event notifier", "event", m.Event, "outbox_id", m.ID)
+			result = "dropped"
+			break
+		}
+		sendErr = s.options.EventNotifier.Notify(ctx, m.Name, m.Email,
+			notifier.WithEvent(m.Event),
+			notifier.WithPayload(json.RawMessage(m.Payload)),
+			notifier.WithIdempotencyKey(m.ID),
+		)
+	default:
+		sendErr = s.notifier.Notify(ctx, m.Name, m.Email, notifier.WithKind(notifier.Kind(m.Kind)), notifier.WithToken(m.Token))
+	}
+
+	if sendErr != nil {
+		result = "failed"
+	}
+
+	s.metrics.notifications.WithLabelValues(channel, result).Inc()
+
+	var err error
+
+	switch {
+	case sendErr == nil:
+		record(userrepo.DeliverySucceeded)
+		err = s.repo.CompleteOutboxMessage(ctx, m.ID)
+	case m.Attempts >= s.options.OutboxMaxAttempts:
+		// In a real app, we'd alert on this through a proper monitoring service.
+		s.options.Logger.ErrorContext(ctx, "giving up sending "+what, "outbox_id", m.ID, "attempts", m.Attempts, "error", sendErr)
+		record(userrepo.DeliveryFailed)
+		err = s.repo.DeadLetterOutboxMessage(ctx, m.ID, sendErr.Error())
+	default:
+		s.options.Logger.WarnContext(ctx, "failed to send "+what, "outbox_id", m.ID, "attempt", m.Attempts, "error", sendErr)
+		record(userrepo.DeliveryPending)
+		err = s.repo.RetryOutboxMessage(ctx, m.ID, sendErr.Error(), time.Now().Add(s.backoff(m.Attempts)))
+	}
+
+	if err != nil {
+		s.options.Logger.ErrorContext(ctx, "failed to update outbox message", "outbox_id", m.ID, "error", err)
+	}
+}
+
+// backoff is how long to wait after the given number of failed
+// attempts: OutboxBackoff, doubled for each attempt after the first
+func (s *Service) backoff(attempts int) time.Duration {
+	d := s.options.OutboxBackoff
+
+	for i := 1; i < attempts && d < outboxMaxBackoff; i++ {
+		d *= 2
+	}
+
+	return min(d, outboxMaxBackoff)
+}
@@ -0,0 +1,167 @@
+package user
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// queueWait is the longest a caller waits for room in a full queue
+const queueWait = time.Second
+
+// task is a side effect run by the pool. ctx is cancelled once the
+// pool gives up draining.
+type task func(ctx context.Context)
+
+// background runs t on the pool once there is room, so the caller can
+// be answered first. t gets the pool's context, but stays in the
+// caller's trace. It fails with ErrBusy rather than wait long.
+func (s *Service) background(ctx context.Context, t task) error {
+	sc := trace.SpanContextFromContext(ctx)
+
+	ctx, cancel := context.WithTimeout(ctx, queueWait)
+	defer cancel()
+
+	err := s.pool.submit(ctx, func(ctx context.Context) {
+		t(trace.ContextWithSpanContext(ctx, sc))
+	})
+	if err != nil {
+		s.metrics.rejectedTasks.Inc()
+	}
+
+	return err
+}
+
+// pool runs tasks on up to workers goroutines, started as tasks arrive
+// and stopped when the queue is empty, so an idle pool costs nothing.
+// Up to the queue's capacity of tasks wait for a worker; submitters
+// beyond that wait for room.
+type pool struct {
+	workers int
+	queue   chan task
+	// queued tracks len(queue), shared with other pools on the registry
+	queued prometheus.Gauge
+	// ctx is handed to tasks, and cancel abandons them
+	ctx    context.Context
+	cancel context.CancelFunc
+	// pending counts tasks submitted but not yet finished
+	pending atomic.Int64
+	wg      sync.WaitGroup
+	running int
+	closed  bool
+	mtx     sync.Mutex
+}
+
+// submit queues t, waiting for room while ctx allows. It fails with
+// ErrBusy when there is no room in time or the pool is stopping.
+func (p *pool) submit(ctx context.Context, t task) error {
+	p.mtx.Lock()
+	if p.closed {
+		p.mtx.Unlock()
+		return ErrBusy
+	}
+	// counted before the pool can close, so a drain waits for it
+	p.pending.Add(1)
+	p.wg.Add(1)
+	p.mtx.Unlock()
+
+	select {
+	case p.queue <- t:
+		p.queued.Inc()
+	case <-ctx.Done():
+		p.done()
+		return ErrBusy
+	case <-p.ctx.Done():
+		p.done()
+		return ErrBusy
+	}
+
+	p.mtx.Lock()
+	if p.running < p.workers {
+		p.running++
+		go p.work()
+	}
+	p.mtx.Unlock()
+
+	return nil
+}
+
+// work runs queued tasks until there are none left
+func (p *pool) work() {
+	for {
+		select {
+		case t := <-p.queue:
+			p.queued.Dec()
+			p.run(t)
+			continue
+		default:
+		}
+
+		// checked again under the lock, as a submitter only starts
+		// a worker when fewer than workers are running
+		p.mtx.Lock()
+		if len(p.queue) == 0 {
+			p.running--
+			p.mtx.Unlock()
+			return
+		}
+		p.mtx.Unlock()
+	}
+}
+
+func (p *pool) run(t task) {
+	defer p.done()
+
+	// tasks of an abandoned pool are dropped unstarted
+	if p.ctx.Err() != nil {
+		return
+	}
+
+	t(p.ctx)
+}
+
+func (p *pool) done() {
+	p.pending.Add(-1)
+	p.wg.Done()
+}
+
+// stop refuses new tasks and waits for those submitted to finish.
+// When ctx is done first, it cancels them and returns how many were
+// abandoned, queued or running.
+func (p *pool) stop(ctx context.Context) (int, error) {
+	p.mtx.Lock()
+	p.closed = true
+	p.mtx.Unlock()
+
+	drained := make(chan struct{})
+	go func() {
+		p.wg.Wait()
+		close(drained)
+	}()
+
+	select {
+	case <-drained:
+		p.cancel()
+		return 0, nil
+	case <-ctx.Done():
+		abandoned := int(p.pending.Load())
+		p.cancel()
+		return abandoned, ctx.Err()
+	}
+}
+
+func newPool(workers int, queueSize int, queued prometheus.Gauge) *pool {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	return &pool{
+		workers: max(workers, 1),
+		queue:   make(chan task, max(queueSize, 1)),
+		queued:  queued,
+		ctx:     ctx,
+		cancel:  cancel,
+	}
+}
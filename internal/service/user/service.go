@@ -2,21 +2,39 @@ package user
 
 import (
 	"context"
+	"crypto/rand"
 	"errors"
 	"fmt"
-	"log"
+	"slices"
 	"strings"
 	"sync"
-	"time"
 
 	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
 	"github.com/w-h-a/demo-go/internal/client/notifier"
 	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	"github.com/w-h-a/demo-go/internal/middleware"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const (
+	DefaultPageSize = 50
+	MaxPageSize     = 200
 )
 
 type Service struct {
-	repo      userrepo.UserRepo
-	notifier  notifier.Notifier
+	options  Options
+	repo     userrepo.UserRepo
+	notifier notifier.Notifier
+	tracer   trace.Tracer
+	metrics  serviceMetrics
+	// wake nudges the outbox relay, which stopRelay stops
+	// and which closes relayDone once it has
+	wake      chan struct{}
+	stopRelay chan struct{}
+	relayDone chan struct{}
+	// pool runs side effects in the background
+	pool      *pool
 	isRunning bool
 	mtx       sync.RWMutex
 }
@@ -48,11 +66,15 @@ func (s *Service) Start() error {
 
 	s.isRunning = true
 
+	s.stopRelay = make(chan struct{})
+	s.relayDone = make(chan struct{})
+	go s.relay(s.stopRelay, s.relayDone)
+
 	return nil
 }
 
 func (s *Service) Stop() error {
-	stopCtx, stopCancel := context.WithTimeout(context.Background(), 10*time.Second)
+	stopCtx, stopCancel := context.WithTimeout(context.Background(), s.options.ShutdownTimeout)
 	defer stopCancel()
 	return s.stop(stopCtx)
 }
@@ -67,37 +89,90 @@ func (s *Service) stop(ctx context.Context) error {
 
 	s.isRunning = false
 
+	stopRelay := s.stopRelay
+	relayDone := s.relayDone
+
 	s.mtx.Unlock()
 
+	var stopErr error
+
+	// drained while the relay still runs, so it sends what they queue
+	if abandoned, err := s.pool.stop(ctx); err != nil {
+		s.options.Logger.ErrorContext(ctx, "abandoned background tasks", "count", abandoned, "error", err)
+		stopErr = fmt.Errorf("abandoned %d background tasks: %w", abandoned, err)
+	}
+
+	close(stopRelay)
+
 	gracefulStopDone := make(chan struct{})
 	go func() {
-		// TODO: close clients gracefully
+		// let a send in flight finish, so it isn't sent again
+		<-relayDone
 		close(gracefulStopDone)
 	}()
 
-	var stopErr error
-
 	select {
 	case <-gracefulStopDone:
 	case <-ctx.Done():
-		stopErr = ctx.Err()
+		if stopErr == nil {
+			stopErr = ctx.Err()
+		}
 	}
 
-	return stopErr
+	return errors.Join(stopErr, s.closeClients(ctx))
+}
+
+// closeClients closes the repo and every notifier, once each
+func (s *Service) closeClients(ctx context.Context) error {
+	var errs []error
+
+	if err := s.repo.Close(ctx); err != nil {
+		errs = append(errs, fmt.Errorf("failed to close user repo: %w", err))
+	}
+
+	var closed []notifier.Notifier
+
+	for _, n := range []notifier.Notifier{s.notifier, s.options.EventNotifier, s.options.WebhookNotifier} {
+		if n == nil || slices.Contains(closed, n) {
+			continue
+		}
+		closed = append(closed, n)
+
+		if err := n.Close(ctx); err != nil {
+			errs = append(errs, fmt.Errorf("failed to close notifier: %w", err))
+		}
+	}
+
+	return errors.Join(errs...)
 }
 
 // CreateUser contains the business logic for creating a new user.
-func (s *Service) CreateUser(ctx context.Context, dto user.CreateUserDTO) (user.User, error) {
+func (s *Service) CreateUser(ctx context.Context, dto user.CreateUserDTO) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "CreateUser")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionCreateUser, ""); err != nil {
+		return user.User{}, err
+	}
+
 	// 1. Business Logic: Validation
-	if dto.Name == "" || dto.Email == "" {
-		return user.User{}, ErrInvalidInput
+	name, email, err := normalise(dto.Name, dto.Email)
+	if err != nil {
+		return user.User{}, err
 	}
 
-	dto.Email = strings.ToLower(strings.TrimSpace(dto.Email))
-	dto.Name = strings.TrimSpace(dto.Name)
+	dto.Name = name
+	dto.Email = email
+
+	password := dto.Password
+	dto.Password = ""
+
+	if password != "" && len(password) < minPasswordLength {
+		return user.User{}, ErrInvalidPassword
+	}
 
 	// 2. Business Logic: Check for duplicates
-	_, err := s.repo.GetByEmail(ctx, dto.Email)
+	_, err = s.repo.GetByEmail(ctx, dto.Email)
 	if err == nil {
 		return user.User{}, ErrEmailInUse
 	}
@@ -105,35 +180,339 @@ func (s *Service) CreateUser(ctx context.Context, dto user.CreateUserDTO) (user.
 		return user.User{}, err
 	}
 
-	// 3. Call the repository to create the user
-	u, err := s.repo.Create(ctx, dto)
+	// 3. Call the repository to create the user, and their credentials
+	var opts []userrepo.CreateOption
+
+	if password != "" {
+		hash, err := hashPassword(password)
+		if err != nil {
+			return user.User{}, err
+		}
+		opts = append(opts, userrepo.WithPasswordHash(hash))
+	}
+
+	token, verification, err := s.newVerificationToken()
 	if err != nil {
 		return user.User{}, err
 	}
+	opts = append(opts, userrepo.WithVerificationToken(verification))
 
-	// 4. Orchestration: Send a welcome email (fire-and-forget)
-	// We run this in a goroutine so it doesn't block the HTTP response.
-	// We also create a new background context in case the original request is cancelled.
-	go func() {
-		if err := s.notifier.Notify(context.Background(), u.Name, u.Email); err != nil {
-			// In a real app, we'd log this to a proper monitoring service.
-			log.Printf("Error sending welcome email: %v\n", err)
-		}
-	}()
+	// 4. Orchestration: Queue a welcome email with the verification token,
+	// stored with the user so neither is lost without the other
+	opts = append(opts, userrepo.WithOutboxMessage(newOutboxMessage(ctx, dto.Name, dto.Email, notifier.KindWelcome, token)))
+
+	// 5. Orchestration: Announce the new user, stored with them too
+	event, err := s.event(ctx, EventUserCreated)
+	if err != nil {
+		return user.User{}, err
+	}
+	opts = append(opts, userrepo.WithEvent(event))
+
+	u, err := s.repo.Create(ctx, dto, opts...)
+	if errors.Is(err, userrepo.ErrEmailInUse) {
+		return user.User{}, ErrEmailInUse
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	s.wakeRelay()
 
 	return u, nil
 }
 
 // GetUser is the business logic for retrieving a single user.
-func (s *Service) GetUser(ctx context.Context, id string) (user.User, error) {
-	return s.repo.GetByID(ctx, id)
+func (s *Service) GetUser(ctx context.Context, id string) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "GetUser")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionReadUser, id); err != nil {
+		return user.User{}, err
+	}
+
+	return s.get(ctx, id)
+}
+
+func (s *Service) get(ctx context.Context, id string) (user.User, error) {
+	u, err := s.repo.GetByID(ctx, id)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, ErrUserNotFound
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	return u, nil
+}
+
+// GetAllUsers is the business logic for retrieving a page of users.
+func (s *Service) GetAllUsers(ctx context.Context, dto user.ListUsersDTO) (_ user.UsersPage, err error) {
+	ctx, span := s.startSpan(ctx, "GetAllUsers")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionListUsers, ""); err != nil {
+		return user.UsersPage{}, err
+	}
+
+	limit := dto.Limit
+	if limit == 0 {
+		limit = DefaultPageSize
+	}
+	if limit < 0 || limit > MaxPageSize {
+		return user.UsersPage{}, fmt.Errorf("%w: limit must be between 1 and %d", ErrInvalidListQuery, MaxPageSize)
+	}
+
+	field, desc := userrepo.SortByCreatedAt, false
+	if dto.Sort != "" {
+		field, desc = userrepo.SortField(strings.TrimPrefix(dto.Sort, "-")), strings.HasPrefix(dto.Sort, "-")
+	}
+	if !field.Valid() {
+		return user.UsersPage{}, fmt.Errorf("%w: unsupported sort %q", ErrInvalidListQuery, dto.Sort)
+	}
+
+	// fetch one extra to learn whether another page exists
+	opts := []userrepo.GetAllOption{
+		userrepo.WithLimit(limit + 1),
+		userrepo.WithSort(field, desc),
+	}
+
+	if dto.Cursor != "" {
+		cursor, err := userrepo.DecodeCursor(dto.Cursor)
+		if err != nil || cursor.Field != field || cursor.Desc != desc {
+			return user.UsersPage{}, fmt.Errorf("%w: cursor does not match this listing", ErrInvalidListQuery)
+		}
+		opts = append(opts, userrepo.WithCursor(cursor))
+	}
+
+	if name := strings.TrimSpace(dto.Name); name != "" {
+		opts = append(opts, userrepo.WithNamePrefix(name))
+	}
+
+	if email := strings.ToLower(strings.TrimSpace(dto.Email)); email != "" {
+		opts = append(opts, userrepo.WithEmailPrefix(email))
+	}
+
+	if dto.EmailVerified != nil {
+		opts = append(opts, userrepo.WithEmailVerified(*dto.EmailVerified))
+	}
+
+	us, err := s.repo.GetAll(ctx, opts...)
+	if err != nil {
+		return user.UsersPage{}, err
+	}
+
+	page := user.UsersPage{Users: us}
+
+	if len(us) > limit {
+		page.Users = us[:limit]
+		page.NextCursor = userrepo.CursorAfter(us[limit-1], field, desc).Encode()
+	}
+
+	if page.Users == nil {
+		page.Users = []user.User{}
+	}
+
+	return page, nil
+}
+
+// UpdateUser is the business logic for replacing a user's name and email,
+// and their roles when dto.Roles is set.
+func (s *Service) UpdateUser(ctx context.Context, id string, dto user.UpdateUserDTO) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "UpdateUser")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return user.User{}, err
+	}
+
+	name, email, err := normalise(dto.Name, dto.Email)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	update := user.UpdateUserDTO{Name: name, Email: email}
+
+	if dto.Roles != nil {
+		existing, err := s.get(ctx, id)
+		if err != nil {
+			return user.User{}, err
+		}
+
+		update.Roles, err = s.assignRoles(ctx, existing, dto.Roles)
+		if err != nil {
+			return user.User{}, err
+		}
+	}
+
+	return s.update(ctx, id, update)
+}
+
+// PatchUser is the business logic for partially updating a user.
+// Fields left nil in the dto keep their current values.
+func (s *Service) PatchUser(ctx context.Context, id string, dto user.PatchUserDTO) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "PatchUser")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return user.User{}, err
+	}
+
+	existing, err := s.get(ctx, id)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	name, email := existing.Name, existing.Email
+
+	if dto.Name != nil {
+		name = *dto.Name
+	}
+
+	if dto.Email != nil {
+		email = *dto.Email
+	}
+
+	name, email, err = normalise(name, email)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	update := user.UpdateUserDTO{Name: name, Email: email}
+
+	if dto.Roles != nil {
+		update.Roles, err = s.assignRoles(ctx, existing, *dto.Roles)
+		if err != nil {
+			return user.User{}, err
+		}
+	}
+
+	return s.update(ctx, id, update)
+}
+
+// DeleteUser is the business logic for removing a user.
+func (s *Service) DeleteUser(ctx context.Context, id string) (err error) {
+	ctx, span := s.startSpan(ctx, "DeleteUser")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionDeleteUser, id); err != nil {
+		return err
+	}
+
+	event, err := s.event(ctx, EventUserDeleted)
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.Delete(ctx, id, userrepo.WithChangeEvent(event))
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	s.wakeRelay()
+
+	return nil
 }
 
-// GetAllUsers is the business logic for retrieving all users.
-func (s *Service) GetAllUsers(ctx context.Context) ([]user.User, error) {
-	return s.repo.GetAll(ctx)
+// update expects an already normalised dto. It rejects the change
+// when the email belongs to a different user.
+func (s *Service) update(ctx context.Context, id string, dto user.UpdateUserDTO) (user.User, error) {
+	owner, err := s.repo.GetByEmail(ctx, dto.Email)
+	if err == nil && owner.ID != id {
+		return user.User{}, ErrEmailInUse
+	}
+	if err != nil && !errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, err
+	}
+
+	// nobody has the email yet, so this user is changing theirs
+	emailChanged := err != nil
+
+	event, err := s.event(ctx, EventUserUpdated)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	u, err := s.repo.Update(ctx, id, dto, userrepo.WithChangeEvent(event))
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, ErrUserNotFound
+	}
+	if errors.Is(err, userrepo.ErrEmailInUse) {
+		return user.User{}, ErrEmailInUse
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if emailChanged {
+		// the update stands either way, and the user can ask for a resend
+		if err := s.sendVerification(ctx, u); err != nil {
+			s.options.Logger.ErrorContext(ctx, "failed to send verification email", "user_id", u.ID, "error", err)
+		}
+	}
+
+	s.wakeRelay()
+
+	return u, nil
+}
+
+// assignRoles normalises roles and, when they differ from the
+// existing user's, checks the principal may assign them.
+func (s *Service) assignRoles(ctx context.Context, existing user.User, roles []user.Role) ([]user.Role, error) {
+	roles, err := normaliseRoles(roles)
+	if err != nil {
+		return nil, err
+	}
+
+	if slices.Equal(roles, existing.Roles) {
+		return roles, nil
+	}
+
+	if err := s.authorize(ctx, authz.ActionAssignRoles, existing.ID); err != nil {
+		return nil, err
+	}
+
+	return roles, nil
 }
 
-func New(repo userrepo.UserRepo, notifier notifier.Notifier) *Service {
-	return &Service{repo, notifier, false, sync.RWMutex{}}
+// authorize checks the principal in ctx may perform action on the user
+// with ownerID. Callers without a principal are treated as anonymous.
+func (s *Service) authorize(ctx context.Context, action authz.Action, ownerID string) error {
+	principal, _ := middleware.GetUserFromCtx(ctx)
+
+	// API keys only reach what their scopes allow, whatever
+	// their owner's roles would
+	if principal.Scopes != nil && !slices.Contains(principal.Scopes, string(action)) {
+		return fmt.Errorf("%w: %s", ErrForbidden, action)
+	}
+
+	err := s.options.Authorizer.Authorize(ctx, principal, action, ownerID)
+	if errors.Is(err, authz.ErrForbidden) {
+		return fmt.Errorf("%w: %s", ErrForbidden, action)
+	}
+
+	return err
+}
+
+func New(repo userrepo.UserRepo, notifier notifier.Notifier, opts ...Option) *Service {
+	options := NewOptions(opts...)
+
+	if len(options.TokenSecret) == 0 {
+		options.TokenSecret = make([]byte, 32)
+		rand.Read(options.TokenSecret)
+	}
+
+	m := newServiceMetrics(options.Registerer)
+
+	return &Service{
+		options:  options,
+		repo:     repo,
+		notifier: notifier,
+		tracer:   options.TracerProvider.Tracer(instrumentationName),
+		metrics:  m,
+		wake:     make(chan struct{}, 1),
+		pool:     newPool(options.Workers, options.WorkerQueueSize, m.queuedTasks),
+	}
 }
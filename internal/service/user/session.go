@@ -0,0 +1,248 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+type accessClaims struct {
+	jwt.RegisteredClaims
+	SessionID string `json:"sid"`
+}
+
+// Login is the business logic for exchanging an email and
+// password for a new session's tokens.
+func (s *Service) Login(ctx context.Context, dto user.LoginDTO) (_ user.Tokens, err error) {
+	ctx, span := s.startSpan(ctx, "Login")
+	defer endSpan(span, &err)
+
+	email := strings.ToLower(strings.TrimSpace(dto.Email))
+
+	u, err := s.repo.GetByEmail(ctx, email)
+	if err != nil && !errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.Tokens{}, err
+	}
+
+	hash, found := dummyPasswordHash(), false
+
+	if err == nil {
+		stored, err := s.repo.GetPasswordHash(ctx, u.ID)
+		if err != nil && !errors.Is(err, userrepo.ErrCredentialsNotFound) {
+			return user.Tokens{}, err
+		}
+		if err == nil {
+			hash, found = stored, true
+		}
+	}
+
+	// always verify, so unknown emails take as long as wrong passwords
+	ok, err := verifyPassword(dto.Password, hash)
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	if !ok || !found {
+		return user.Tokens{}, ErrInvalidCredentials
+	}
+
+	// only checked once the password is known to be right, so it
+	// reveals nothing about accounts to those who don't know it
+	if s.options.RequireVerifiedEmail && !u.EmailVerified {
+		return user.Tokens{}, ErrEmailNotVerified
+	}
+
+	if err := s.checkSecondFactor(ctx, u.ID, dto.TOTPCode, dto.RecoveryCode); err != nil {
+		return user.Tokens{}, err
+	}
+
+	return s.startSession(ctx, u.ID)
+}
+
+// Refresh is the business logic for exchanging a refresh token for
+// new tokens. The refresh token is rotated, so it only works once.
+func (s *Service) Refresh(ctx context.Context, dto user.RefreshDTO) (_ user.Tokens, err error) {
+	ctx, span := s.startSpan(ctx, "Refresh")
+	defer endSpan(span, &err)
+
+	oldHash := hashToken(dto.RefreshToken)
+
+	session, err := s.repo.GetSessionByRefreshTokenHash(ctx, oldHash)
+	if errors.Is(err, userrepo.ErrSessionNotFound) {
+		return user.Tokens{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	if !session.Active(time.Now()) {
+		return user.Tokens{}, ErrInvalidCredentials
+	}
+
+	refreshToken, err := newToken()
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	err = s.repo.RotateSession(ctx, session.ID, oldHash, hashToken(refreshToken), time.Now().Add(s.options.RefreshTokenTTL))
+	if errors.Is(err, userrepo.ErrSessionNotFound) {
+		// lost a race with another refresh or a logout
+		return user.Tokens{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	return s.tokens(session.UserID, session.ID, refreshToken)
+}
+
+// Logout is the business logic for revoking the session a refresh
+// token belongs to, along with its access tokens. Unknown tokens are
+// ignored so logging out twice succeeds.
+func (s *Service) Logout(ctx context.Context, dto user.RefreshDTO) (err error) {
+	ctx, span := s.startSpan(ctx, "Logout")
+	defer endSpan(span, &err)
+
+	session, err := s.repo.GetSessionByRefreshTokenHash(ctx, hashToken(dto.RefreshToken))
+	if errors.Is(err, userrepo.ErrSessionNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	err = s.repo.RevokeSession(ctx, session.ID)
+	if errors.Is(err, userrepo.ErrSessionNotFound) {
+		return nil
+	}
+
+	return err
+}
+
+// IsAccessToken reports whether token claims to have been issued
+// by Login or Refresh, without verifying it.
+func (s *Service) IsAccessToken(token string) bool {
+	var claims accessClaims
+
+	if _, _, err := jwt.NewParser().ParseUnverified(token, &claims); err != nil {
+		return false
+	}
+
+	return claims.Issuer == s.options.TokenIssuer && len(claims.SessionID) > 0
+}
+
+// VerifyAccessToken returns the current state of the user an access
+// token was issued to, provided its session is still active.
+func (s *Service) VerifyAccessToken(ctx context.Context, token string) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "VerifyAccessToken")
+	defer endSpan(span, &err)
+
+	var claims accessClaims
+
+	_, err = jwt.NewParser(
+		jwt.WithValidMethods([]string{jwt.SigningMethodHS256.Alg()}),
+		jwt.WithIssuer(s.options.TokenIssuer),
+		jwt.WithExpirationRequired(),
+	).ParseWithClaims(token, &claims, func(*jwt.Token) (any, error) {
+		return s.options.TokenSecret, nil
+	})
+	if err != nil {
+		return user.User{}, fmt.Errorf("%w: %v", ErrInvalidCredentials, err)
+	}
+
+	session, err := s.repo.GetSession(ctx, claims.SessionID)
+	if errors.Is(err, userrepo.ErrSessionNotFound) {
+		return user.User{}, ErrInvalidCredentials
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	if !session.Active(time.Now()) || session.UserID != claims.Subject {
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	// re-read the user so role changes apply to existing sessions
+	u, err := s.repo.GetByID(ctx, session.UserID)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.User{}, ErrInvalidCredentials
+	}
+
+	return u, err
+}
+
+// startSession creates a session for the user and returns its tokens
+func (s *Service) startSession(ctx context.Context, userID string) (user.Tokens, error) {
+	refreshToken, err := newToken()
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	session := userrepo.Session{
+		ID:               uuid.NewString(),
+		UserID:           userID,
+		RefreshTokenHash: hashToken(refreshToken),
+		ExpiresAt:        time.Now().Add(s.options.RefreshTokenTTL),
+	}
+
+	if err := s.repo.CreateSession(ctx, session); err != nil {
+		return user.Tokens{}, err
+	}
+
+	return s.tokens(userID, session.ID, refreshToken)
+}
+
+// tokens signs an access token for the session and pairs it with refreshToken
+func (s *Service) tokens(userID string, sessionID string, refreshToken string) (user.Tokens, error) {
+	now := time.Now()
+
+	claims := accessClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			Issuer:    s.options.TokenIssuer,
+			Subject:   userID,
+			IssuedAt:  jwt.NewNumericDate(now),
+			ExpiresAt: jwt.NewNumericDate(now.Add(s.options.AccessTokenTTL)),
+		},
+		SessionID: sessionID,
+	}
+
+	accessToken, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString(s.options.TokenSecret)
+	if err != nil {
+		return user.Tokens{}, err
+	}
+
+	return user.Tokens{
+		AccessToken:  accessToken,
+		RefreshToken: refreshToken,
+		TokenType:    "Bearer",
+		ExpiresIn:    int(s.options.AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// newToken returns a random, URL safe token
+func newToken() (string, error) {
+	bs := make([]byte, 32)
+	if _, err := rand.Read(bs); err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(bs), nil
+}
+
+// hashToken digests a high-entropy token for storage. Unlike passwords,
+// such tokens can't be brute forced, so a fast hash is enough.
+func hashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,233 @@
+package user
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	"github.com/w-h-a/demo-go/internal/totp"
+)
+
+const (
+	recoveryCodeCount = 10
+	// recoveryCodeBytes is 80 bits, 16 base32 characters
+	recoveryCodeBytes = 10
+)
+
+var recoveryCodeEncoding = base32.StdEncoding.WithPadding(base32.NoPadding)
+
+// EnrollTOTP is the business logic for starting two-factor
+// authentication. It returns a new secret, which only protects
+// logins once ConfirmTOTP is called with a code generated from it.
+// Enrolling again before then replaces the secret.
+func (s *Service) EnrollTOTP(ctx context.Context, id string) (_ user.TOTPEnrollment, err error) {
+	ctx, span := s.startSpan(ctx, "EnrollTOTP")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return user.TOTPEnrollment{}, err
+	}
+
+	u, err := s.get(ctx, id)
+	if err != nil {
+		return user.TOTPEnrollment{}, err
+	}
+
+	current, err := s.repo.GetTOTP(ctx, id)
+	if err != nil && !errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return user.TOTPEnrollment{}, err
+	}
+	if err == nil && current.Enabled() {
+		return user.TOTPEnrollment{}, ErrTOTPAlreadyEnabled
+	}
+
+	secret, err := totp.GenerateSecret()
+	if err != nil {
+		return user.TOTPEnrollment{}, err
+	}
+
+	err = s.repo.SaveTOTP(ctx, userrepo.TOTP{UserID: id, Secret: secret})
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return user.TOTPEnrollment{}, ErrUserNotFound
+	}
+	if err != nil {
+		return user.TOTPEnrollment{}, err
+	}
+
+	return user.TOTPEnrollment{
+		Secret: secret,
+		URI:    totp.URI(s.options.TokenIssuer, u.Email, secret),
+	}, nil
+}
+
+// ConfirmTOTP is the business logic for enabling two-factor
+// authentication with a first code. It returns recovery codes,
+// which are stored hashed so can't be shown again.
+func (s *Service) ConfirmTOTP(ctx context.Context, id string, dto user.TOTPCodeDTO) (_ user.RecoveryCodes, err error) {
+	ctx, span := s.startSpan(ctx, "ConfirmTOTP")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return user.RecoveryCodes{}, err
+	}
+
+	t, err := s.repo.GetTOTP(ctx, id)
+	if errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return user.RecoveryCodes{}, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return user.RecoveryCodes{}, err
+	}
+
+	if t.Enabled() {
+		return user.RecoveryCodes{}, ErrTOTPAlreadyEnabled
+	}
+
+	counter, ok := totp.Validate(t.Secret, strings.TrimSpace(dto.Code), time.Now())
+	if !ok {
+		return user.RecoveryCodes{}, ErrInvalidTOTPCode
+	}
+
+	codes, hashes, err := newRecoveryCodes()
+	if err != nil {
+		return user.RecoveryCodes{}, err
+	}
+
+	err = s.repo.ConfirmTOTP(ctx, id, counter, hashes)
+	if errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return user.RecoveryCodes{}, ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return user.RecoveryCodes{}, err
+	}
+
+	return user.RecoveryCodes{Codes: codes}, nil
+}
+
+// DisableTOTP is the business logic for turning two-factor
+// authentication off. Once enabled, it takes a code or recovery
+// code, so a stolen session alone can't remove the second factor.
+func (s *Service) DisableTOTP(ctx context.Context, id string, dto user.TOTPCodeDTO) (err error) {
+	ctx, span := s.startSpan(ctx, "DisableTOTP")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return err
+	}
+
+	t, err := s.repo.GetTOTP(ctx, id)
+	if errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return ErrTOTPNotEnrolled
+	}
+	if err != nil {
+		return err
+	}
+
+	if t.Enabled() {
+		if err := s.checkSecondFactor(ctx, id, dto.Code, dto.RecoveryCode); err != nil {
+			return err
+		}
+	}
+
+	err = s.repo.DeleteTOTP(ctx, id)
+	if errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return ErrTOTPNotEnrolled
+	}
+
+	return err
+}
+
+// checkSecondFactor passes users without two-factor authentication
+// enabled, and otherwise requires a code not used before or an
+// unused recovery code. Too many invalid ones in a row lock it, so
+// codes can't be guessed.
+func (s *Service) checkSecondFactor(ctx context.Context, userID string, code string, recoveryCode string) error {
+	t, err := s.repo.GetTOTP(ctx, userID)
+	if errors.Is(err, userrepo.ErrTOTPNotFound) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+
+	if !t.Enabled() {
+		return nil
+	}
+
+	now := time.Now()
+
+	if t.Locked(now) {
+		return ErrTOTPLocked
+	}
+
+	err = s.useSecondFactor(ctx, t, code, recoveryCode, now)
+	if errors.Is(err, ErrInvalidTOTPCode) {
+		failErr := s.repo.FailTOTP(ctx, userID, s.options.TOTPMaxFailures, now.Add(s.options.TOTPLockout))
+		if failErr != nil && !errors.Is(failErr, userrepo.ErrTOTPNotFound) {
+			return failErr
+		}
+	}
+
+	return err
+}
+
+// useSecondFactor accepts a code not used before or an unused
+// recovery code for t
+func (s *Service) useSecondFactor(ctx context.Context, t userrepo.TOTP, code string, recoveryCode string, now time.Time) error {
+	code = strings.TrimSpace(code)
+
+	switch {
+	case code != "":
+		counter, ok := totp.Validate(t.Secret, code, now)
+		if !ok {
+			return ErrInvalidTOTPCode
+		}
+
+		err := s.repo.UseTOTPCounter(ctx, t.UserID, counter)
+		if errors.Is(err, userrepo.ErrTOTPCodeUsed) || errors.Is(err, userrepo.ErrTOTPNotFound) {
+			return ErrInvalidTOTPCode
+		}
+		return err
+	case recoveryCode != "":
+		err := s.repo.UseRecoveryCode(ctx, t.UserID, hashToken(normalizeRecoveryCode(recoveryCode)))
+		if errors.Is(err, userrepo.ErrRecoveryCodeNotFound) {
+			return ErrInvalidTOTPCode
+		}
+		return err
+	default:
+		return ErrTOTPRequired
+	}
+}
+
+// newRecoveryCodes returns codes formatted for reading, like
+// abcd-efgh-ijkl-mnop, and the hashes to store for them
+func newRecoveryCodes() ([]string, []string, error) {
+	codes := make([]string, 0, recoveryCodeCount)
+	hashes := make([]string, 0, recoveryCodeCount)
+
+	for range recoveryCodeCount {
+		bs := make([]byte, recoveryCodeBytes)
+		if _, err := rand.Read(bs); err != nil {
+			return nil, nil, err
+		}
+
+		raw := strings.ToLower(recoveryCodeEncoding.EncodeToString(bs))
+
+		codes = append(codes, raw[0:4]+"-"+raw[4:8]+"-"+raw[8:12]+"-"+raw[12:16])
+		hashes = append(hashes, hashToken(raw))
+	}
+
+	return codes, hashes, nil
+}
+
+// normalizeRecoveryCode undoes formatting, so codes can be typed
+// with or without dashes, in either case
+func normalizeRecoveryCode(code string) string {
+	return strings.NewReplacer("-", "", " ", "").Replace(strings.ToLower(strings.TrimSpace(code)))
+}
@@ -0,0 +1,45 @@
+package user
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const instrumentationName = "github.com/w-h-a/demo-go/internal/service/user"
+
+// traceParentKey is the W3C header outbox messages keep their trace in
+const traceParentKey = "traceparent"
+
+// startSpan starts the span of the service method name
+func (s *Service) startSpan(ctx context.Context, name string) (context.Context, trace.Span) {
+	return s.tracer.Start(ctx, "UserService."+name)
+}
+
+// endSpan ends span, marking it failed when the method returned an
+// error in *err
+func endSpan(span trace.Span, err *error) {
+	if *err != nil {
+		span.RecordError(*err)
+		span.SetStatus(codes.Error, (*err).Error())
+	}
+
+	span.End()
+}
+
+// traceParent is the span ctx carries, as a W3C traceparent, for work
+// done on its behalf after the caller is answered
+func traceParent(ctx context.Context) string {
+	carrier := propagation.MapCarrier{}
+	propagation.TraceContext{}.Inject(ctx, carrier)
+
+	return carrier.Get(traceParentKey)
+}
+
+// withTraceParent returns ctx carrying the span of traceParent, so the
+// spans started with it join that span's trace
+func withTraceParent(ctx context.Context, traceParent string) context.Context {
+	return propagation.TraceContext{}.Extract(ctx, propagation.MapCarrier{traceParentKey: traceParent})
+}
@@ -1 +1,41 @@
 package user
+
+import (
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/w-h-a/demo-go/api/user"
+)
+
+// normalise trims the name and trims and lower-cases the email.
+// It returns ErrInvalidInput when either ends up empty.
+func normalise(name string, email string) (string, string, error) {
+	name = strings.TrimSpace(name)
+	email = strings.ToLower(strings.TrimSpace(email))
+
+	if name == "" || email == "" {
+		return "", "", ErrInvalidInput
+	}
+
+	return name, email, nil
+}
+
+// normaliseRoles sorts and dedupes roles. It returns ErrInvalidRole
+// for anything but a storable role.
+func normaliseRoles(roles []user.Role) ([]user.Role, error) {
+	normalised := []user.Role{}
+
+	for _, role := range roles {
+		switch role {
+		case user.RoleAdmin, user.RoleOperator:
+		default:
+			return nil, fmt.Errorf("%w: %q", ErrInvalidRole, role)
+		}
+		normalised = append(normalised, role)
+	}
+
+	slices.Sort(normalised)
+
+	return slices.Compact(normalised), nil
+}
@@ -0,0 +1,107 @@
+package user
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+// VerifyEmail is the business logic for consuming the token sent to a
+// user's email, marking the address verified. Tokens work once.
+func (s *Service) VerifyEmail(ctx context.Context, dto user.VerifyEmailDTO) (_ user.User, err error) {
+	ctx, span := s.startSpan(ctx, "VerifyEmail")
+	defer endSpan(span, &err)
+
+	if dto.Token == "" {
+		return user.User{}, ErrInvalidVerificationToken
+	}
+
+	event, err := s.event(ctx, EventUserUpdated)
+	if err != nil {
+		return user.User{}, err
+	}
+
+	u, err := s.repo.VerifyEmail(ctx, hashToken(dto.Token), time.Now(), userrepo.WithChangeEvent(event))
+	if errors.Is(err, userrepo.ErrVerificationTokenNotFound) {
+		return user.User{}, ErrInvalidVerificationToken
+	}
+	if errors.Is(err, userrepo.ErrVerificationTokenExpired) {
+		return user.User{}, ErrVerificationTokenExpired
+	}
+	if err != nil {
+		return user.User{}, err
+	}
+
+	s.wakeRelay()
+
+	return u, nil
+}
+
+// ResendVerification is the business logic for sending a user a fresh
+// verification token, replacing the last. Resends are throttled.
+func (s *Service) ResendVerification(ctx context.Context, id string) (err error) {
+	ctx, span := s.startSpan(ctx, "ResendVerification")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionUpdateUser, id); err != nil {
+		return err
+	}
+
+	u, err := s.get(ctx, id)
+	if err != nil {
+		return err
+	}
+
+	if u.EmailVerified {
+		return ErrEmailAlreadyVerified
+	}
+
+	last, err := s.repo.GetVerificationToken(ctx, id)
+	if err != nil && !errors.Is(err, userrepo.ErrVerificationTokenNotFound) {
+		return err
+	}
+	if err == nil && time.Since(last.CreatedAt) < s.options.VerificationResendInterval {
+		return ErrVerificationThrottled
+	}
+
+	return s.sendVerification(ctx, u)
+}
+
+// sendVerification replaces the user's verification token and sends them the new one
+func (s *Service) sendVerification(ctx context.Context, u user.User) error {
+	token, verification, err := s.newVerificationToken()
+	if err != nil {
+		return err
+	}
+
+	verification.UserID = u.ID
+	verification.Email = u.Email
+
+	err = s.repo.SaveVerificationToken(ctx, verification)
+	if errors.Is(err, userrepo.ErrUserNotFound) {
+		return ErrUserNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return s.enqueue(ctx, u, notifier.KindVerifyEmail, token)
+}
+
+// newVerificationToken returns a token to send and the record to store for it
+func (s *Service) newVerificationToken() (string, userrepo.VerificationToken, error) {
+	token, err := newToken()
+	if err != nil {
+		return "", userrepo.VerificationToken{}, err
+	}
+
+	return token, userrepo.VerificationToken{
+		TokenHash: hashToken(token),
+		ExpiresAt: time.Now().Add(s.options.VerificationTTL),
+	}, nil
+}
@@ -0,0 +1,300 @@
+package user
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+)
+
+const (
+	// webhookSecretPrefix marks generated secrets, so they're
+	// recognisable in receivers' configuration
+	webhookSecretPrefix = "whsec_"
+	minWebhookSecretLen = 16
+)
+
+// CreateWebhook is the business logic for subscribing a URL to events.
+// The secret requests are signed with is returned this once.
+func (s *Service) CreateWebhook(ctx context.Context, dto user.CreateWebhookDTO) (_ user.CreatedWebhook, err error) {
+	ctx, span := s.startSpan(ctx, "CreateWebhook")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionManageWebhooks, ""); err != nil {
+		return user.CreatedWebhook{}, err
+	}
+
+	if s.options.WebhookNotifier == nil {
+		return user.CreatedWebhook{}, ErrWebhooksDisabled
+	}
+
+	u, err := url.Parse(dto.URL)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || len(u.Host) == 0 {
+		return user.CreatedWebhook{}, fmt.Errorf("%w: bad url %q", ErrInvalidWebhook, dto.URL)
+	}
+
+	for _, event := range dto.Events {
+		if !slices.Contains(Events(), event) {
+			return user.CreatedWebhook{}, fmt.Errorf("%w: unknown event %q", ErrInvalidWebhook, event)
+		}
+	}
+
+	secret := dto.Secret
+	if len(secret) == 0 {
+		token, err := newToken()
+		if err != nil {
+			return user.CreatedWebhook{}, err
+		}
+		secret = webhookSecretPrefix + token
+	}
+
+	if len(secret) < minWebhookSecretLen {
+		return user.CreatedWebhook{}, ErrInvalidWebhook
+	}
+
+	stored := userrepo.Webhook{
+		ID:     uuid.NewString(),
+		URL:    u.String(),
+		Secret: secret,
+		Events: slices.Compact(slices.Sorted(slices.Values(dto.Events))),
+	}
+
+	if err := s.repo.CreateWebhook(ctx, stored); err != nil {
+		return user.CreatedWebhook{}, err
+	}
+
+	stored.CreatedAt = time.Now().UTC()
+
+	return user.CreatedWebhook{Webhook: toWebhook(stored), Secret: secret}, nil
+}
+
+// ListWebhooks is the business logic for listing subscriptions.
+func (s *Service) ListWebhooks(ctx context.Context) (_ []user.Webhook, err error) {
+	ctx, span := s.startSpan(ctx, "ListWebhooks")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.repo.ListWebhooks(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	webhooks := make([]user.Webhook, 0, len(stored))
+	for _, w := range stored {
+		webhooks = append(webhooks, toWebhook(w))
+	}
+
+	return webhooks, nil
+}
+
+// DeleteWebhook is the business logic for unsubscribing. Deliveries
+// still queued are dropped.
+func (s *Service) DeleteWebhook(ctx context.Context, id string) (err error) {
+	ctx, span := s.startSpan(ctx, "DeleteWebhook")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionManageWebhooks, ""); err != nil {
+		return err
+	}
+
+	err = s.repo.DeleteWebhook(ctx, id)
+	if errors.Is(err, userrepo.ErrWebhookNotFound) {
+		return ErrWebhookNotFound
+	}
+
+	return err
+}
+
+// ListWebhookDeliveries is the business logic for listing up to limit
+// of a webhook's deliveries, newest first, with the requests made for each.
+func (s *Service) ListWebhookDeliveries(ctx context.Context, webhookID string, limit int) (_ []user.WebhookDelivery, err error) {
+	ctx, span := s.startSpan(ctx, "ListWebhookDeliveries")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionManageWebhooks, ""); err != nil {
+		return nil, err
+	}
+
+	if limit <= 0 {
+		limit = DefaultPageSize
+	}
+
+	if limit > MaxPageSize {
+		return nil, fmt.Errorf("%w: limit must be at most %d", ErrInvalidListQuery, MaxPageSize)
+	}
+
+	if _, err := s.getWebhook(ctx, webhookID); err != nil {
+		return nil, err
+	}
+
+	stored, err := s.repo.ListWebhookDeliveries(ctx, webhookID, limit)
+	if err != nil {
+		return nil, err
+	}
+
+	deliveries := make([]user.WebhookDelivery, 0, len(stored))
+	for _, d := range stored {
+		deliveries = append(deliveries, toWebhookDelivery(d))
+	}
+
+	return deliveries, nil
+}
+
+// RedeliverWebhookDelivery is the business logic for trying a finished
+// delivery again, with the same event ID so receivers can spot repeats.
+func (s *Service) RedeliverWebhookDelivery(ctx context.Context, webhookID string, deliveryID string) (_ user.WebhookDelivery, err error) {
+	ctx, span := s.startSpan(ctx, "RedeliverWebhookDelivery")
+	defer endSpan(span, &err)
+
+	if err := s.authorize(ctx, authz.ActionManageWebhooks, ""); err != nil {
+		return user.WebhookDelivery{}, err
+	}
+
+	d, err := s.repo.GetWebhookDelivery(ctx, deliveryID)
+	if errors.Is(err, userrepo.ErrWebhookDeliveryNotFound) || (err == nil && d.WebhookID != webhookID) {
+		return user.WebhookDelivery{}, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return user.WebhookDelivery{}, err
+	}
+
+	if d.Status == userrepo.DeliveryPending {
+		return user.WebhookDelivery{}, ErrWebhookDeliveryPending
+	}
+
+	err = s.repo.RedeliverWebhookDelivery(ctx, d.ID, newDeliveryMessage(ctx, d))
+	if errors.Is(err, userrepo.ErrWebhookDeliveryNotFound) {
+		return user.WebhookDelivery{}, ErrWebhookDeliveryNotFound
+	}
+	if err != nil {
+		return user.WebhookDelivery{}, err
+	}
+
+	s.wakeRelay()
+
+	d.Status = userrepo.DeliveryPending
+
+	return toWebhookDelivery(d), nil
+}
+
+func (s *Service) getWebhook(ctx context.Context, id string) (userrepo.Webhook, error) {
+	w, err := s.repo.GetWebhook(ctx, id)
+	if errors.Is(err, userrepo.ErrWebhookNotFound) {
+		return userrepo.Webhook{}, ErrWebhookNotFound
+	}
+
+	return w, err
+}
+
+// sendWebhook makes the delivery m drives, recording the requests made
+// and the delivery's status once the outcome is known through record.
+// Deliveries whose webhook was deleted are dropped.
+func (s *Service) sendWebhook(ctx context.Context, m userrepo.OutboxMessage) (record func(userrepo.DeliveryStatus), err error) {
+	record = func(userrepo.DeliveryStatus) {}
+
+	if s.options.WebhookNotifier == nil {
+		s.options.Logger.WarnContext(ctx, "dropping webhook delivery, as webhooks are disabled", "event", m.Event, "delivery_id", m.DeliveryID)
+		return record, nil
+	}
+
+	d, err := s.repo.GetWebhookDelivery(ctx, m.DeliveryID)
+	if errors.Is(err, userrepo.ErrWebhookDeliveryNotFound) {
+		return record, nil
+	}
+	if err != nil {
+		return record, err
+	}
+
+	w, err := s.repo.GetWebhook(ctx, d.WebhookID)
+	if errors.Is(err, userrepo.ErrWebhookNotFound) {
+		return record, nil
+	}
+	if err != nil {
+		return record, err
+	}
+
+	// requests are made one at a time, as there's one endpoint
+	var attempts []userrepo.WebhookAttempt
+
+	sendErr := s.options.WebhookNotifier.Notify(ctx, m.Name, m.Email,
+		notifier.WithEvent(d.Event),
+		notifier.WithPayload(json.RawMessage(d.Payload)),
+		notifier.WithIdempotencyKey(d.EventID),
+		notifier.WithEndpoint(w.URL, w.Secret),
+		notifier.WithOnAttempt(func(statusCode int, err error) {
+			attempt := userrepo.WebhookAttempt{At: time.Now().UTC(), StatusCode: statusCode}
+			if err != nil {
+				attempt.Error = err.Error()
+			}
+			attempts = append(attempts, attempt)
+		}),
+	)
+
+	if sendErr != nil && len(attempts) == 0 {
+		attempts = append(attempts, userrepo.WebhookAttempt{At: time.Now().UTC(), Error: sendErr.Error()})
+	}
+
+	record = func(status userrepo.DeliveryStatus) {
+		err := s.repo.RecordWebhookAttempts(ctx, d.ID, status, attempts)
+		if err != nil && !errors.Is(err, userrepo.ErrWebhookDeliveryNotFound) {
+			s.options.Logger.ErrorContext(ctx, "failed to record webhook delivery", "delivery_id", d.ID, "error", err)
+		}
+	}
+
+	return record, sendErr
+}
+
+// newDeliveryMessage is the outbox message driving d
+func newDeliveryMessage(ctx context.Context, d userrepo.WebhookDelivery) userrepo.OutboxMessage {
+	return userrepo.OutboxMessage{
+		ID:          uuid.NewString(),
+		Event:       d.Event,
+		DeliveryID:  d.ID,
+		TraceParent: traceParent(ctx),
+	}
+}
+
+func toWebhook(w userrepo.Webhook) user.Webhook {
+	events := w.Events
+	if events == nil {
+		events = []string{}
+	}
+
+	return user.Webhook{
+		ID:        w.ID,
+		URL:       w.URL,
+		Events:    events,
+		CreatedAt: w.CreatedAt,
+	}
+}
+
+func toWebhookDelivery(d userrepo.WebhookDelivery) user.WebhookDelivery {
+	attempts := make([]user.WebhookAttempt, 0, len(d.Attempts))
+	for _, a := range d.Attempts {
+		attempts = append(attempts, user.WebhookAttempt{At: a.At, StatusCode: a.StatusCode, Error: a.Error})
+	}
+
+	return user.WebhookDelivery{
+		ID:        d.ID,
+		WebhookID: d.WebhookID,
+		EventID:   d.EventID,
+		Event:     d.Event,
+		Payload:   json.RawMessage(d.Payload),
+		Status:    string(d.Status),
+		Attempts:  attempts,
+		CreatedAt: d.CreatedAt,
+		UpdatedAt: d.UpdatedAt,
+	}
+}
@@ -0,0 +1,96 @@
+package tracer
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+)
+
+const defaultOTLPPath = "/v1/traces"
+
+// New returns a tracer provider batching the spans of res to the
+// exporter at loc, keeping sampleRatio of the traces started here.
+// Traces started by a caller keep the caller's sampling decision.
+//
+//   - stdout:// writes spans to stdout as JSON
+//   - file:///path/to/traces.json appends spans to the file as JSON
+//   - http://host:4318 or https://... sends spans to an OTLP collector,
+//     at /v1/traces unless the location has a path of its own
+//
+// Spans still batched are exported by Shutdown.
+func New(ctx context.Context, loc string, res *resource.Resource, sampleRatio float64) (*sdktrace.TracerProvider, error) {
+	exporter, err := newExporter(ctx, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	return sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(sampleRatio))),
+	), nil
+}
+
+func newExporter(ctx context.Context, loc string) (sdktrace.SpanExporter, error) {
+	u, err := url.Parse(loc)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse traces location: %w", err)
+	}
+
+	switch u.Scheme {
+	case "stdout":
+		return stdouttrace.New()
+	case "file":
+		if len(u.Path) == 0 {
+			return nil, errors.New("traces location file:// needs a path")
+		}
+
+		f, err := os.OpenFile(u.Path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open traces file: %w", err)
+		}
+
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+
+		return &fileExporter{SpanExporter: exporter, f: f}, nil
+	case "http", "https":
+		path := u.Path
+		if len(path) == 0 || path == "/" {
+			path = defaultOTLPPath
+		}
+
+		opts := []otlptracehttp.Option{
+			otlptracehttp.WithEndpoint(u.Host),
+			otlptracehttp.WithURLPath(path),
+		}
+
+		if u.Scheme == "http" {
+			opts = append(opts, otlptracehttp.WithInsecure())
+		}
+
+		return otlptracehttp.New(ctx, opts...)
+	default:
+		return nil, fmt.Errorf("unsupported traces location scheme: %q", u.Scheme)
+	}
+}
+
+// fileExporter closes the file it writes to on shutdown
+type fileExporter struct {
+	sdktrace.SpanExporter
+	f *os.File
+}
+
+func (e *fileExporter) Shutdown(ctx context.Context) error {
+	return errors.Join(e.SpanExporter.Shutdown(ctx), e.f.Close())
+}
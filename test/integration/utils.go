@@ -1 +1,57 @@
 package integration
+
+import (
+	"context"
+	"net/http"
+
+	"github.com/w-h-a/demo-go/api/user"
+	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/metadata"
+)
+
+const (
+	testAPIKey       = "integration-test-key"
+	testMemberAPIKey = "integration-member-key"
+)
+
+// testAuthenticator accepts testAPIKey as the admin "integration"
+// and testMemberAPIKey as "member", who holds no roles
+func testAuthenticator() authhttpmiddleware.Authenticator {
+	return authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{
+		testAPIKey:       {ID: "integration", Roles: []user.Role{user.RoleAdmin}},
+		testMemberAPIKey: {ID: "member"},
+	})
+}
+
+// authOptions tries as, then testAuthenticator
+func authOptions(as ...authhttpmiddleware.Authenticator) []authhttpmiddleware.Option {
+	return []authhttpmiddleware.Option{
+		authhttpmiddleware.WithAuthenticators(as...),
+		authhttpmiddleware.WithAuthenticators(testAuthenticator()),
+	}
+}
+
+type apiKeyTransport struct {
+	key string
+}
+
+func (t apiKeyTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	r = r.Clone(r.Context())
+	r.Header.Set("X-API-Key", t.key)
+	return http.DefaultTransport.RoundTrip(r)
+}
+
+// authedClient sends testAPIKey with every request
+var authedClient = &http.Client{Transport: apiKeyTransport{key: testAPIKey}}
+
+// memberClient sends testMemberAPIKey with every request
+var memberClient = &http.Client{Transport: apiKeyTransport{key: testMemberAPIKey}}
+
+func apiKeyUnaryInterceptor(ctx context.Context, method string, req, reply any, cc *grpc.ClientConn, invoker grpc.UnaryInvoker, opts ...grpc.CallOption) error {
+	return invoker(metadata.AppendToOutgoingContext(ctx, "x-api-key", testAPIKey), method, req, reply, cc, opts...)
+}
+
+func apiKeyStreamInterceptor(ctx context.Context, desc *grpc.StreamDesc, cc *grpc.ClientConn, method string, streamer grpc.Streamer, opts ...grpc.CallOption) (grpc.ClientStream, error) {
+	return streamer(metadata.AppendToOutgoingContext(ctx, "x-api-key", testAPIKey), desc, cc, method, opts...)
+}
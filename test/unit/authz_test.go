@@ -0,0 +1,65 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+)
+
+func TestAuthz(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	t.Run("DefaultPolicies", func(t *testing.T) {
+		// Arrange
+		a := authz.NewPolicyAuthorizer(authz.DefaultPolicies()...)
+		admin := user.User{ID: "a", Roles: []user.Role{user.RoleAdmin}}
+		operator := user.User{ID: "o", Roles: []user.Role{user.RoleOperator}}
+		self := user.User{ID: "s"}
+
+		// Act & Assert
+		assert.NoError(t, a.Authorize(ctx, admin, authz.ActionDeleteUser, "s"))
+		assert.NoError(t, a.Authorize(ctx, operator, authz.ActionReadUser, "s"))
+		assert.ErrorIs(t, a.Authorize(ctx, operator, authz.ActionListUsers, ""), authz.ErrForbidden)
+		assert.NoError(t, a.Authorize(ctx, self, authz.ActionUpdateUser, "s"))
+		assert.ErrorIs(t, a.Authorize(ctx, self, authz.ActionUpdateUser, "a"), authz.ErrForbidden)
+		assert.ErrorIs(t, a.Authorize(ctx, user.User{}, authz.ActionReadUser, ""), authz.ErrForbidden)
+	})
+
+	t.Run("LoadPolicyFile", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "policies.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"policies": [{"action": "users:create", "roles": ["*"]}]}`), 0o600))
+
+		// Act
+		policies, err := authz.LoadPolicyFile(path)
+		require.NoError(t, err)
+		a := authz.NewPolicyAuthorizer(policies...)
+
+		// Assert
+		assert.NoError(t, a.Authorize(ctx, user.User{}, authz.ActionCreateUser, ""))
+		assert.ErrorIs(t, a.Authorize(ctx, user.User{ID: "x", Roles: []user.Role{user.RoleAdmin}}, authz.ActionListUsers, ""), authz.ErrForbidden)
+	})
+
+	t.Run("LoadPolicyFileRejectsUnknownAction", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "policies.json")
+		require.NoError(t, os.WriteFile(path, []byte(`{"policies": [{"action": "users:lst", "roles": ["admin"]}]}`), 0o600))
+
+		// Act
+		_, err := authz.LoadPolicyFile(path)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,133 @@
+package unit
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/middleware"
+	authgrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/auth"
+	deadlinegrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/deadline"
+	recoverygrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/recovery"
+	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+)
+
+func TestGrpcMiddleware(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetUser"}
+
+	t.Run("RecoveryReturnsInternal", func(t *testing.T) {
+		// Arrange
+		interceptor := recoverygrpcmiddleware.NewUnary()
+
+		// Act
+		_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			panic("boom")
+		})
+
+		// Assert
+		assert.Equal(t, codes.Internal, status.Code(err))
+	})
+
+	t.Run("DeadlineCapsLongerDeadline", func(t *testing.T) {
+		// Arrange
+		interceptor := deadlinegrpcmiddleware.NewUnary(time.Second)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Hour)
+		defer cancel()
+		var remaining time.Duration
+
+		// Act
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			d, _ := ctx.Deadline()
+			remaining = time.Until(d)
+			return nil, nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, remaining, time.Second)
+	})
+
+	t.Run("DeadlineKeepsShorterDeadline", func(t *testing.T) {
+		// Arrange
+		interceptor := deadlinegrpcmiddleware.NewUnary(time.Hour)
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		expected, _ := ctx.Deadline()
+		var actual time.Time
+
+		// Act
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			actual, _ = ctx.Deadline()
+			return nil, nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expected, actual)
+	})
+
+	authenticator := authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{
+		"secret": {ID: "svc"},
+	})
+
+	t.Run("AuthPopulatesUserKey", func(t *testing.T) {
+		// Arrange
+		interceptor := authgrpcmiddleware.NewUnary(authenticator)
+		ctx := metadata.NewIncomingContext(context.Background(), metadata.Pairs("x-api-key", "secret"))
+		var principal user.User
+
+		// Act
+		_, err := interceptor(ctx, nil, info, func(ctx context.Context, req any) (any, error) {
+			principal, _ = middleware.GetUserFromCtx(ctx)
+			return nil, nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, "svc", principal.ID)
+	})
+
+	t.Run("AuthRejectsMissingCredentials", func(t *testing.T) {
+		// Arrange
+		interceptor := authgrpcmiddleware.NewUnary(authenticator)
+		called := false
+
+		// Act
+		_, err := interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			called = true
+			return nil, nil
+		})
+
+		// Assert
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+		assert.False(t, called)
+	})
+	t.Run("AuthLeavesHealthServiceOpen", func(t *testing.T) {
+		// Arrange
+		interceptor := authgrpcmiddleware.NewUnary(authenticator)
+		healthInfo := &grpc.UnaryServerInfo{FullMethod: "/grpc.health.v1.Health/Check"}
+		called := false
+
+		// Act
+		_, err := interceptor(context.Background(), nil, healthInfo, func(ctx context.Context, req any) (any, error) {
+			called = true
+			return nil, nil
+		})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.True(t, called)
+	})
+}
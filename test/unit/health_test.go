@@ -0,0 +1,196 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
+	healthgrpchandler "github.com/w-h-a/demo-go/internal/handler/grpc/health"
+	healthhttphandler "github.com/w-h-a/demo-go/internal/handler/http/health"
+	"github.com/w-h-a/demo-go/internal/health"
+	"github.com/w-h-a/demo-go/internal/server"
+	httpserver "github.com/w-h-a/demo-go/internal/server/http"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"google.golang.org/grpc/codes"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/status"
+)
+
+func TestHealth(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	up := health.CheckerFunc(func(ctx context.Context) error { return nil })
+	down := health.CheckerFunc(func(ctx context.Context) error { return errors.New("connection refused") })
+
+	// get serves GET path from the handler, returning the code and report
+	get := func(t *testing.T, h http.HandlerFunc, path string) (int, health.Report) {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		h(rec, httptest.NewRequest(http.MethodGet, path, nil))
+		var report health.Report
+		require.NoError(t, json.Unmarshal(rec.Body.Bytes(), &report))
+		return rec.Code, report
+	}
+
+	t.Run("CriticalComponentDownFailsHealth", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		require.NoError(t, registry.Register("user_repo", down))
+		require.NoError(t, registry.RegisterOptional("notifier", up))
+		h := healthhttphandler.New(registry)
+
+		// Act
+		code, report := get(t, h.Health, "/healthz")
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, health.Component{Status: health.StatusDown, Error: "connection refused"}, report.Components["user_repo"])
+		assert.Equal(t, health.Component{Status: health.StatusUp, Optional: true}, report.Components["notifier"])
+	})
+
+	t.Run("OptionalComponentDownDegradesHealth", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		require.NoError(t, registry.Register("user_repo", up))
+		require.NoError(t, registry.RegisterOptional("notifier", down))
+		h := healthhttphandler.New(registry)
+
+		// Act
+		code, report := get(t, h.Ready, "/readyz")
+
+		// Assert
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, health.StatusDegraded, report.Status)
+		assert.Equal(t, health.StatusDown, report.Components["notifier"].Status)
+	})
+
+	t.Run("HangingChecksTimeOut", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry(health.WithTimeout(50 * time.Millisecond))
+		hang := make(chan struct{})
+		defer close(hang)
+		require.NoError(t, registry.Register("user_repo", health.CheckerFunc(func(ctx context.Context) error {
+			<-hang
+			return nil
+		})))
+
+		// Act
+		start := time.Now()
+		report := registry.Health(context.Background())
+
+		// Assert
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Equal(t, health.StatusDown, report.Status)
+		assert.Equal(t, context.DeadlineExceeded.Error(), report.Components["user_repo"].Error)
+	})
+
+	t.Run("ReadinessFailsOnceShuttingDown", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		require.NoError(t, registry.Register("user_repo", up))
+		h := healthhttphandler.New(registry)
+
+		// Act
+		registry.Shutdown()
+		readyCode, ready := get(t, h.Ready, "/readyz")
+		healthCode, _ := get(t, h.Health, "/healthz")
+		liveCode, _ := get(t, h.Live, "/livez")
+
+		// Assert
+		assert.Equal(t, http.StatusServiceUnavailable, readyCode)
+		assert.True(t, ready.ShuttingDown)
+		assert.Equal(t, http.StatusOK, healthCode)
+		assert.Equal(t, http.StatusOK, liveCode)
+	})
+
+	t.Run("DuplicateNamesAreRejected", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		require.NoError(t, registry.Register("user_repo", up))
+
+		// Act
+		err := registry.RegisterOptional("user_repo", up)
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("GrpcHealthReportsReadinessAndComponents", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		require.NoError(t, registry.Register("user_repo", up))
+		require.NoError(t, registry.RegisterOptional("notifier", down))
+		h := healthgrpchandler.New(registry)
+		ctx := context.Background()
+		check := func(service string) (healthpb.HealthCheckResponse_ServingStatus, error) {
+			rsp, err := h.Check(ctx, &healthpb.HealthCheckRequest{Service: service})
+			return rsp.GetStatus(), err
+		}
+
+		// Act
+		overall, overallErr := check("")
+		notifier, notifierErr := check("notifier")
+		_, unknownErr := check("nope")
+		registry.Shutdown()
+		draining, drainingErr := check("")
+
+		// Assert
+		require.NoError(t, overallErr)
+		assert.Equal(t, healthpb.HealthCheckResponse_SERVING, overall)
+		require.NoError(t, notifierErr)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, notifier)
+		assert.Equal(t, codes.NotFound, status.Code(unknownErr))
+		require.NoError(t, drainingErr)
+		assert.Equal(t, healthpb.HealthCheckResponse_NOT_SERVING, draining)
+	})
+
+	t.Run("ServiceIsDownUntilStarted", func(t *testing.T) {
+		// Arrange
+		registry := health.NewRegistry()
+		svc := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		require.NoError(t, svc.RegisterChecks(registry))
+
+		// Act
+		before := registry.Health(context.Background())
+		require.NoError(t, svc.Start())
+		after := registry.Health(context.Background())
+		require.NoError(t, svc.Stop())
+
+		// Assert
+		assert.Equal(t, []string{"user_service", "user_repo", "notifier"}, registry.Names())
+		assert.Equal(t, health.StatusDown, before.Components["user_service"].Status)
+		assert.Equal(t, health.StatusUp, after.Status)
+	})
+
+	t.Run("ServersAreDownUnlessServing", func(t *testing.T) {
+		// Arrange
+		srv := httpserver.NewServer(server.WithAddress("127.0.0.1:0"))
+		require.NoError(t, srv.Handle(http.NotFoundHandler()))
+		ctx := context.Background()
+
+		// Act
+		before := srv.Check(ctx)
+		require.NoError(t, srv.Start())
+		serving := srv.Check(ctx)
+		require.NoError(t, srv.Stop())
+		after := srv.Check(ctx)
+
+		// Assert
+		assert.Error(t, before)
+		assert.NoError(t, serving)
+		assert.Error(t, after)
+	})
+}
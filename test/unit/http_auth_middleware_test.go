@@ -0,0 +1,362 @@
+package unit
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
+	"github.com/w-h-a/demo-go/internal/middleware"
+	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"golang.org/x/crypto/bcrypt"
+)
+
+func TestHttpAuthMiddleware(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	// serve runs req through the middleware and reports the principal it resolved
+	serve := func(req *http.Request, opts ...authhttpmiddleware.Option) (*httptest.ResponseRecorder, user.User, bool) {
+		var principal user.User
+		var ok bool
+
+		h := authhttpmiddleware.New(opts...)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			principal, ok = middleware.GetUserFromCtx(r.Context())
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+
+		return rec, principal, ok
+	}
+
+	apiKeys := authhttpmiddleware.WithAuthenticators(
+		authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{
+			"secret": {ID: "svc"},
+		}),
+	)
+
+	t.Run("APIKeyPopulatesUserKey", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("X-API-Key", "secret")
+
+		// Act
+		rec, principal, ok := serve(req, apiKeys)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, ok)
+		assert.Equal(t, "svc", principal.ID)
+	})
+
+	t.Run("InvalidAPIKeyUnauthorized", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("X-API-Key", "wrong")
+
+		// Act
+		rec, _, _ := serve(req, apiKeys)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("MissingCredentialsChallengesEveryScheme", func(t *testing.T) {
+		// Arrange
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		jwtAuth := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewJWTAuthenticator(authhttpmiddleware.JWTKeys{HMAC: []byte("k")}, "", ""),
+		)
+
+		// Act
+		rec, _, _ := serve(req, apiKeys, jwtAuth, authhttpmiddleware.WithRealm("test"))
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+		assert.Equal(t, []string{`ApiKey realm="test"`, `Bearer realm="test"`}, rec.Header().Values("WWW-Authenticate"))
+	})
+
+	t.Run("PublicRouteAllowsAnonymous", func(t *testing.T) {
+		// Arrange
+		public := authhttpmiddleware.WithPublicRoutes("POST /api/users", "/docs/*")
+		signup := httptest.NewRequest(http.MethodPost, "/api/users", nil)
+		docs := httptest.NewRequest(http.MethodGet, "/docs/index.html", nil)
+		list := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+
+		// Act
+		signupRec, _, ok := serve(signup, apiKeys, public)
+		docsRec, _, _ := serve(docs, apiKeys, public)
+		listRec, _, _ := serve(list, apiKeys, public)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, signupRec.Code)
+		assert.False(t, ok)
+		assert.Equal(t, http.StatusOK, docsRec.Code)
+		assert.Equal(t, http.StatusUnauthorized, listRec.Code)
+	})
+
+	t.Run("HMACBearerPopulatesUserKey", func(t *testing.T) {
+		// Arrange
+		secret := []byte("hmac-secret")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub":   "user-1",
+			"email": "user1@test.com",
+			"iss":   "issuer",
+			"exp":   time.Now().Add(time.Minute).Unix(),
+		}).SignedString(secret)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewJWTAuthenticator(authhttpmiddleware.JWTKeys{HMAC: secret}, "issuer", ""),
+		)
+
+		// Act
+		rec, principal, ok := serve(req, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, ok)
+		assert.Equal(t, "user-1", principal.ID)
+		assert.Equal(t, "user1@test.com", principal.Email)
+	})
+
+	t.Run("ExpiredBearerUnauthorized", func(t *testing.T) {
+		// Arrange
+		secret := []byte("hmac-secret")
+		token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, jwt.MapClaims{
+			"sub": "user-1",
+			"exp": time.Now().Add(-time.Minute).Unix(),
+		}).SignedString(secret)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+token)
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewJWTAuthenticator(authhttpmiddleware.JWTKeys{HMAC: secret}, "", ""),
+		)
+
+		// Act
+		rec, _, _ := serve(req, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, rec.Code)
+	})
+
+	t.Run("RSABearerFromJWKSFile", func(t *testing.T) {
+		// Arrange
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		require.NoError(t, err)
+		jwks, _ := json.Marshal(map[string]any{
+			"keys": []map[string]string{{
+				"kty": "RSA",
+				"kid": "k1",
+				"n":   base64.RawURLEncoding.EncodeToString(key.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(key.E)).Bytes()),
+			}},
+		})
+		path := filepath.Join(t.TempDir(), "jwks.json")
+		require.NoError(t, os.WriteFile(path, jwks, 0o600))
+		keys, err := authhttpmiddleware.LoadJWTKeys("", path)
+		require.NoError(t, err)
+		token := jwt.NewWithClaims(jwt.SigningMethodRS256, jwt.MapClaims{
+			"sub": "user-2",
+			"exp": time.Now().Add(time.Minute).Unix(),
+		})
+		token.Header["kid"] = "k1"
+		signed, err := token.SignedString(key)
+		require.NoError(t, err)
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+signed)
+
+		// Act
+		rec, principal, _ := serve(req, authhttpmiddleware.WithAuthenticators(authhttpmiddleware.NewJWTAuthenticator(keys, "", "")))
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.Equal(t, "user-2", principal.ID)
+	})
+
+	t.Run("BasicAgainstHtpasswdFile", func(t *testing.T) {
+		// Arrange
+		hash, err := bcrypt.GenerateFromPassword([]byte("hunter2"), bcrypt.MinCost)
+		require.NoError(t, err)
+		path := filepath.Join(t.TempDir(), "htpasswd")
+		require.NoError(t, os.WriteFile(path, []byte("# admins\nalice:"+string(hash)+"\n"), 0o600))
+		store, err := authhttpmiddleware.LoadHtpasswdFile(path)
+		require.NoError(t, err)
+		opt := authhttpmiddleware.WithAuthenticators(authhttpmiddleware.NewBasicAuthenticator(store))
+		good := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		good.SetBasicAuth("alice", "hunter2")
+		bad := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		bad.SetBasicAuth("alice", "wrong")
+
+		// Act
+		goodRec, principal, _ := serve(good, opt)
+		badRec, _, _ := serve(bad, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, goodRec.Code)
+		assert.Equal(t, "alice", principal.ID)
+		assert.Equal(t, http.StatusUnauthorized, badRec.Code)
+		assert.Equal(t, `Basic realm="demo-go"`, badRec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("RevokedSessionBearerNotPassedToJWT", func(t *testing.T) {
+		// Arrange
+		ctx := context.Background()
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Session", Email: "session@test.com", Password: "correct horse"})
+		require.NoError(t, err)
+		tokens, err := userService.Login(ctx, user.LoginDTO{Email: u.Email, Password: "correct horse"})
+		require.NoError(t, err)
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewSessionAuthenticator(userService),
+			authhttpmiddleware.NewJWTAuthenticator(authhttpmiddleware.JWTKeys{HMAC: []byte("other-secret")}, "", ""),
+		)
+		require.NoError(t, userService.Logout(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken}))
+		revoked := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		revoked.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+		// Act
+		revokedRec, _, _ := serve(revoked, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusUnauthorized, revokedRec.Code)
+		assert.Equal(t, []string{`Bearer realm="demo-go"`}, revokedRec.Header().Values("WWW-Authenticate"))
+	})
+
+	t.Run("SessionBearerPopulatesUserKey", func(t *testing.T) {
+		// Arrange
+		ctx := context.Background()
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Session", Email: "session@test.com", Password: "correct horse"})
+		require.NoError(t, err)
+		tokens, err := userService.Login(ctx, user.LoginDTO{Email: u.Email, Password: "correct horse"})
+		require.NoError(t, err)
+		opt := authhttpmiddleware.WithAuthenticators(authhttpmiddleware.NewSessionAuthenticator(userService))
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer "+tokens.AccessToken)
+
+		// Act
+		rec, principal, ok := serve(req, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, rec.Code)
+		assert.True(t, ok)
+		assert.Equal(t, u.ID, principal.ID)
+	})
+
+	t.Run("SessionStoreFailureIsNotUnauthorized", func(t *testing.T) {
+		// Arrange
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewSessionAuthenticator(brokenVerifier{}),
+			authhttpmiddleware.NewJWTAuthenticator(authhttpmiddleware.JWTKeys{HMAC: []byte("other-secret")}, "", ""),
+		)
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("Authorization", "Bearer session-token")
+
+		// Act
+		rec, _, ok := serve(req, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.False(t, ok)
+		assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+	})
+
+	t.Run("ManagedAPIKeyInEitherHeader", func(t *testing.T) {
+		// Arrange
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		u, err := userService.CreateUser(context.Background(), user.CreateUserDTO{Name: "Service", Email: "service@test.com"})
+		require.NoError(t, err)
+		key, err := userService.CreateAPIKey(ctxAs(u), user.CreateAPIKeyDTO{Name: "billing", Scopes: []string{"users:read"}})
+		require.NoError(t, err)
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewManagedAPIKeyAuthenticator(userService),
+			authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{"static": {ID: "static"}}),
+		)
+		bearer := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		bearer.Header.Set("Authorization", "Bearer "+key.Key)
+		header := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		header.Header.Set("X-API-Key", key.Key)
+		static := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		static.Header.Set("X-API-Key", "static")
+		revoked := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		revoked.Header.Set("X-API-Key", key.Key)
+
+		// Act
+		bearerRec, bearerPrincipal, _ := serve(bearer, opt)
+		headerRec, headerPrincipal, _ := serve(header, opt)
+		staticRec, staticPrincipal, _ := serve(static, opt)
+		require.NoError(t, userService.DeleteAPIKey(ctxAs(u), key.ID))
+		revokedRec, _, _ := serve(revoked, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusOK, bearerRec.Code)
+		assert.Equal(t, u.ID, bearerPrincipal.ID)
+		assert.Equal(t, []string{"users:read"}, bearerPrincipal.Scopes)
+		assert.Equal(t, http.StatusOK, headerRec.Code)
+		assert.Equal(t, u.ID, headerPrincipal.ID)
+		assert.Equal(t, http.StatusOK, staticRec.Code)
+		assert.Equal(t, "static", staticPrincipal.ID)
+		assert.Nil(t, staticPrincipal.Scopes)
+		assert.Equal(t, http.StatusUnauthorized, revokedRec.Code)
+	})
+
+	t.Run("APIKeyStoreFailureIsNotUnauthorized", func(t *testing.T) {
+		// Arrange
+		opt := authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewManagedAPIKeyAuthenticator(brokenVerifier{}),
+			authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{"static": {ID: "static"}}),
+		)
+		req := httptest.NewRequest(http.MethodGet, "/api/users", nil)
+		req.Header.Set("X-API-Key", "managed-key")
+
+		// Act
+		rec, _, ok := serve(req, opt)
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		assert.False(t, ok)
+		assert.Empty(t, rec.Header().Get("WWW-Authenticate"))
+	})
+}
+
+// brokenVerifier recognises every credential but can't reach its store
+type brokenVerifier struct{}
+
+func (brokenVerifier) IsAccessToken(token string) bool {
+	return true
+}
+
+func (brokenVerifier) VerifyAccessToken(ctx context.Context, token string) (user.User, error) {
+	return user.User{}, errors.New("connection refused")
+}
+
+func (brokenVerifier) IsAPIKey(key string) bool {
+	return true
+}
+
+func (brokenVerifier) VerifyAPIKey(ctx context.Context, key string) (user.User, error) {
+	return user.User{}, errors.New("connection refused")
+}
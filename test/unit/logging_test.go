@@ -0,0 +1,181 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/logger"
+	logginggrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/logging"
+	recoverygrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/recovery"
+	authhttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/auth"
+	logginghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/logging"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestLogging(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	// records decodes the JSON records written to buf
+	records := func(t *testing.T, buf *bytes.Buffer) []map[string]any {
+		var rs []map[string]any
+		for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+			var r map[string]any
+			require.NoError(t, json.Unmarshal([]byte(line), &r))
+			rs = append(rs, r)
+		}
+		return rs
+	}
+
+	// server serves h under /things/{id} behind the logging and auth
+	// middleware, accepting the API key "k" for user u1
+	server := func(buf *bytes.Buffer, h http.HandlerFunc) http.Handler {
+		l, _ := logger.New(buf, logger.FormatJSON, "info")
+		router := mux.NewRouter()
+		router.Use(logginghttpmiddleware.Route)
+		router.HandleFunc("/things/{id}", h)
+		auth := authhttpmiddleware.New(authhttpmiddleware.WithAuthenticators(
+			authhttpmiddleware.NewAPIKeyAuthenticator(map[string]user.User{"k": {ID: "u1"}}),
+		))
+		return logginghttpmiddleware.New(l)(auth(router))
+	}
+
+	t.Run("RejectsUnknownFormatsAndLevels", func(t *testing.T) {
+		// Act
+		_, formatErr := logger.New(os.Stderr, "xml", "info")
+		_, levelErr := logger.New(os.Stderr, logger.FormatText, "loud")
+
+		// Assert
+		assert.Error(t, formatErr)
+		assert.Error(t, levelErr)
+	})
+
+	t.Run("DropsRecordsBelowLevel", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		l, err := logger.New(&buf, logger.FormatText, "warn")
+		require.NoError(t, err)
+
+		// Act
+		l.Info("quiet")
+		l.Warn("loud")
+
+		// Assert
+		assert.NotContains(t, buf.String(), "quiet")
+		assert.Contains(t, buf.String(), "loud")
+	})
+
+	t.Run("HTTPRecordsCarryRequestFields", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		h := server(&buf, func(w http.ResponseWriter, r *http.Request) {
+			logger.FromContext(r.Context()).InfoContext(r.Context(), "handling")
+			w.WriteHeader(http.StatusNoContent)
+		})
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("X-API-Key", "k")
+		req.Header.Set(logginghttpmiddleware.RequestIDHeader, "req-1")
+		rec := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, "req-1", rec.Header().Get(logginghttpmiddleware.RequestIDHeader))
+		rs := records(t, &buf)
+		require.Len(t, rs, 2)
+		assert.Equal(t, "handling", rs[0]["msg"])
+		assert.Equal(t, "http request", rs[1]["msg"])
+		assert.EqualValues(t, http.StatusNoContent, rs[1]["status"])
+		for _, r := range rs {
+			assert.Equal(t, "req-1", r["request_id"])
+			assert.Equal(t, "GET /things/{id}", r["route"])
+			assert.Equal(t, "u1", r["principal"])
+		}
+	})
+
+	t.Run("HTTPReplacesUnusableRequestIDs", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		h := server(&buf, func(w http.ResponseWriter, r *http.Request) {})
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("X-API-Key", "k")
+		req.Header.Set(logginghttpmiddleware.RequestIDHeader, "forged\nline")
+		rec := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(rec, req)
+
+		// Assert
+		id := rec.Header().Get(logginghttpmiddleware.RequestIDHeader)
+		assert.NotEmpty(t, id)
+		assert.NotContains(t, id, "forged")
+		assert.Equal(t, id, records(t, &buf)[0]["request_id"])
+	})
+
+	t.Run("HTTPPanicsAreLoggedAndAnswered500", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		h := server(&buf, func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("X-API-Key", "k")
+		rec := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(rec, req)
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		rs := records(t, &buf)
+		require.Len(t, rs, 2)
+		assert.Equal(t, "recovered from panic", rs[0]["msg"])
+		assert.Equal(t, "boom", rs[0]["panic"])
+		assert.Equal(t, "ERROR", rs[1]["level"])
+		assert.EqualValues(t, http.StatusInternalServerError, rs[1]["status"])
+	})
+
+	t.Run("GrpcPanicsAreLoggedWithCallFields", func(t *testing.T) {
+		// Arrange
+		var buf bytes.Buffer
+		l, err := logger.New(&buf, logger.FormatJSON, "info")
+		require.NoError(t, err)
+		logging := logginggrpcmiddleware.NewUnary(l)
+		recovery := recoverygrpcmiddleware.NewUnary()
+		info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetUser"}
+
+		// Act
+		_, err = logging(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return recovery(ctx, req, info, func(ctx context.Context, req any) (any, error) {
+				panic("boom")
+			})
+		})
+
+		// Assert
+		assert.Equal(t, codes.Internal, status.Code(err))
+		rs := records(t, &buf)
+		require.Len(t, rs, 2)
+		assert.Equal(t, "recovered from panic", rs[0]["msg"])
+		assert.Equal(t, "grpc request", rs[1]["msg"])
+		assert.Equal(t, "Internal", rs[1]["code"])
+		for _, r := range rs {
+			assert.NotEmpty(t, r["request_id"])
+			assert.Equal(t, info.FullMethod, r["route"])
+		}
+	})
+}
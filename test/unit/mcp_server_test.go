@@ -0,0 +1,56 @@
+package unit
+
+import (
+	"os"
+	"testing"
+
+	"github.com/mark3labs/mcp-go/mcp"
+	mcpgoserver "github.com/mark3labs/mcp-go/server"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/internal/server"
+	mcpserver "github.com/w-h-a/demo-go/internal/server/mcp"
+)
+
+func TestMCPServer(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	tool := mcpgoserver.ServerTool{Tool: mcp.NewTool("noop")}
+
+	t.Run("InvalidOptionsFailHandleAndStart", func(t *testing.T) {
+		for name, opt := range map[string]server.Option{
+			"UnknownTransport": mcpserver.WithTransport("websocket"),
+			"WildcardBasePath": mcpserver.WithBasePath("/users/{id}"),
+			"QueryInBasePath":  mcpserver.WithBasePath("/mcp?x=1"),
+		} {
+			t.Run(name, func(t *testing.T) {
+				// Arrange
+				srv := mcpserver.NewServer(server.WithAddress("127.0.0.1:0"), opt)
+
+				// Act
+				handleErr := srv.Handle(tool)
+				startErr := srv.Start()
+
+				// Assert
+				assert.Error(t, handleErr)
+				assert.Error(t, startErr)
+			})
+		}
+	})
+
+	t.Run("ValidOptionsStart", func(t *testing.T) {
+		// Arrange
+		srv := mcpserver.NewServer(server.WithAddress("127.0.0.1:0"), mcpserver.WithTransport(mcpserver.TransportSSE), mcpserver.WithBasePath("tools/"))
+		require.NoError(t, srv.Handle(tool))
+
+		// Act
+		err := srv.Start()
+
+		// Assert
+		require.NoError(t, err)
+		assert.NoError(t, srv.Stop())
+	})
+}
@@ -0,0 +1,209 @@
+package unit
+
+import (
+	"context"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gorilla/mux"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	testmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
+	mocknotifier "github.com/w-h-a/demo-go/internal/client/notifier/mock"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
+	mockrepo "github.com/w-h-a/demo-go/internal/client/user_repo/mock"
+	metricsgrpcmiddleware "github.com/w-h-a/demo-go/internal/middleware/grpc/metrics"
+	logginghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/logging"
+	metricshttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/metrics"
+	metricsmcpmiddleware "github.com/w-h-a/demo-go/internal/middleware/mcp/metrics"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+)
+
+func TestMetrics(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	// server serves h under /things/{id} behind the logging and
+	// metrics middleware, recording to reg
+	server := func(reg prometheus.Registerer, h http.HandlerFunc) http.Handler {
+		router := mux.NewRouter()
+		router.Use(logginghttpmiddleware.Route)
+		router.HandleFunc("/things/{id}", h)
+		l := slog.New(slog.NewTextHandler(io.Discard, nil))
+		return logginghttpmiddleware.New(l)(metricshttpmiddleware.New(reg)(router))
+	}
+
+	t.Run("HTTPRequestsAreCountedByRouteAndCode", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		h := server(reg, func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		})
+
+		// Act
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/2", nil))
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/elsewhere", nil))
+
+		// Assert
+		expected := `
+# HELP http_server_requests_total HTTP requests answered, by route and status code.
+# TYPE http_server_requests_total counter
+http_server_requests_total{code="404",route="GET /things/{id}"} 2
+http_server_requests_total{code="404",route="unmatched"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_server_requests_total"))
+		assert.Equal(t, 2, testutil.CollectAndCount(reg, "http_server_request_duration_seconds"))
+	})
+
+	t.Run("HTTPPanicsAreCountedAs500", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		h := server(reg, func(w http.ResponseWriter, r *http.Request) {
+			panic("boom")
+		})
+		rec := httptest.NewRecorder()
+
+		// Act
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/things/1", nil))
+
+		// Assert
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+		expected := `
+# HELP http_server_requests_total HTTP requests answered, by route and status code.
+# TYPE http_server_requests_total counter
+http_server_requests_total{code="500",route="GET /things/{id}"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_server_requests_total"))
+	})
+
+	t.Run("MiddlewareOnTwoServersSharesMetrics", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+
+		// Act
+		first := server(reg, func(w http.ResponseWriter, r *http.Request) {})
+		second := server(reg, func(w http.ResponseWriter, r *http.Request) {})
+		first.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/1", nil))
+		second.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/things/1", nil))
+
+		// Assert
+		expected := `
+# HELP http_server_requests_total HTTP requests answered, by route and status code.
+# TYPE http_server_requests_total counter
+http_server_requests_total{code="200",route="GET /things/{id}"} 2
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "http_server_requests_total"))
+	})
+
+	t.Run("GrpcCallsAreCountedByMethodAndCode", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		interceptor := metricsgrpcmiddleware.NewUnary(reg)
+		info := &grpc.UnaryServerInfo{FullMethod: "/user.v1.UserService/GetUser"}
+
+		// Act
+		interceptor(context.Background(), nil, info, func(ctx context.Context, req any) (any, error) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		})
+
+		// Assert
+		expected := `
+# HELP grpc_server_requests_total gRPC calls answered, by method and status code.
+# TYPE grpc_server_requests_total counter
+grpc_server_requests_total{code="NotFound",method="/user.v1.UserService/GetUser"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "grpc_server_requests_total"))
+	})
+
+	t.Run("MCPToolErrorResultsAreCountedAsErrors", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		tool := metricsmcpmiddleware.NewTool(reg)(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError("no such user"), nil
+		})
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "get_user"
+
+		// Act
+		_, err := tool(context.Background(), req)
+
+		// Assert
+		require.NoError(t, err)
+		expected := `
+# HELP mcp_tool_calls_total MCP tool calls answered, by tool and result.
+# TYPE mcp_tool_calls_total counter
+mcp_tool_calls_total{result="error",tool="get_user"} 1
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "mcp_tool_calls_total"))
+	})
+
+	t.Run("SentNotificationsAreCounted", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		svc := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier(), userservice.WithRegisterer(reg))
+		require.NoError(t, svc.Start())
+
+		// Act
+		_, err := svc.CreateUser(context.Background(), user.CreateUserDTO{Name: "Counted", Email: "counted@test.com"})
+		require.NoError(t, svc.Stop())
+
+		// Assert
+		require.NoError(t, err)
+		expected := `
+# HELP user_notifications_total Attempts at sending notifications, by channel and result.
+# TYPE user_notifications_total counter
+user_notifications_total{channel="email",result="sent"} 1
+# HELP user_background_tasks_queued Background side effects, such as queueing emails, waiting for a worker.
+# TYPE user_background_tasks_queued gauge
+user_background_tasks_queued 0
+`
+		assert.NoError(t, testutil.GatherAndCompare(reg, strings.NewReader(expected), "user_notifications_total", "user_background_tasks_queued"))
+	})
+
+	t.Run("QueuedTasksOfEveryServiceOnTheRegistryAreCounted", func(t *testing.T) {
+		// Arrange
+		reg := prometheus.NewRegistry()
+		release := make(chan struct{})
+		t.Cleanup(func() { close(release) })
+		mockRepo := mockrepo.NewUserRepo()
+		mockRepo.On("GetByEmail", testmock.Anything, testmock.Anything).Return(user.User{}, userrepo.ErrUserNotFound).Run(func(testmock.Arguments) {
+			<-release
+		})
+		userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier(), userservice.WithRegisterer(reg))
+		busy := userservice.New(mockRepo, mocknotifier.NewNotifier(), userservice.WithRegisterer(reg), userservice.WithWorkers(1, 5))
+		dto := user.PasswordResetRequestDTO{Email: "a@test.com"}
+
+		// Act
+		// one for the worker, one for the queue
+		require.NoError(t, busy.RequestPasswordReset(context.Background(), dto))
+		require.NoError(t, busy.RequestPasswordReset(context.Background(), dto))
+
+		// Assert
+		expected := `
+# HELP user_background_tasks_queued Background side effects, such as queueing emails, waiting for a worker.
+# TYPE user_background_tasks_queued gauge
+user_background_tasks_queued 1
+`
+		assert.Eventually(t, func() bool {
+			return testutil.GatherAndCompare(reg, strings.NewReader(expected), "user_background_tasks_queued") == nil
+		}, time.Second, 10*time.Millisecond)
+	})
+}
@@ -0,0 +1,506 @@
+package unit
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/mail"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	smtpnotifier "github.com/w-h-a/demo-go/internal/client/notifier/smtp"
+)
+
+func TestSMTPNotifier(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	serverTLS, clientTLS := selfSignedTLS(t)
+
+	t.Run("SendsMultipartAlternative", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("Demo <no-reply@example.com>"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(
+			context.Background(), "Ada", "ada@example.com",
+			notifier.WithKind(notifier.KindVerifyEmail),
+			notifier.WithToken("tok-123"),
+		)
+
+		// Assert
+		require.NoError(t, err)
+		sent := srv.received()
+		require.Len(t, sent, 1)
+		assert.Equal(t, "no-reply@example.com", sent[0].from)
+		assert.Equal(t, []string{"ada@example.com"}, sent[0].to)
+		assert.True(t, sent[0].tls)
+
+		msg := parseSentMail(t, sent[0].data)
+		assert.Equal(t, "Confirm your email address", msg.subject)
+		assert.Contains(t, msg.header.Get("From"), "no-reply@example.com")
+		assert.Contains(t, msg.header.Get("To"), "ada@example.com")
+		assert.Equal(t, []string{"text/plain", "text/html"}, msg.types)
+		assert.Contains(t, msg.parts["text/plain"], "Hi Ada,")
+		assert.Contains(t, msg.parts["text/plain"], "tok-123")
+		assert.Contains(t, msg.parts["text/html"], "<code>tok-123</code>")
+	})
+
+	t.Run("HTMLIsEscaped", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(context.Background(), "<b>Ada</b>", "ada@example.com")
+
+		// Assert
+		require.NoError(t, err)
+		msg := parseSentMail(t, srv.received()[0].data)
+		assert.Contains(t, msg.parts["text/plain"], "<b>Ada</b>")
+		assert.Contains(t, msg.parts["text/html"], "&lt;b&gt;Ada&lt;/b&gt;")
+	})
+
+	t.Run("STARTTLSWithAuth", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://mailer:s3cret@"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(
+			context.Background(), "Ada", "ada@example.com",
+			notifier.WithKind(notifier.KindPasswordReset),
+			notifier.WithToken("tok-456"),
+		)
+
+		// Assert
+		require.NoError(t, err)
+		sent := srv.received()
+		require.Len(t, sent, 1)
+		assert.True(t, sent[0].tls)
+		assert.Equal(t, "\x00mailer\x00s3cret", sent[0].auth)
+		assert.Equal(t, "Reset your password", parseSentMail(t, sent[0].data).subject)
+	})
+
+	t.Run("ImplicitTLS", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, true)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtps://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(
+			context.Background(), "Ada", "ada@example.com",
+			notifier.WithKind(notifier.KindMagicLink),
+			notifier.WithToken("tok-789"),
+		)
+
+		// Assert
+		require.NoError(t, err)
+		sent := srv.received()
+		require.Len(t, sent, 1)
+		assert.True(t, sent[0].tls)
+		assert.Contains(t, parseSentMail(t, sent[0].data).parts["text/plain"], "tok-789")
+	})
+
+	t.Run("ServerWithoutSTARTTLSIsRefused", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, nil, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(
+			context.Background(), "Ada", "ada@example.com",
+			notifier.WithKind(notifier.KindMagicLink),
+			notifier.WithToken("tok-789"),
+		)
+
+		// Assert
+		require.Error(t, err)
+		assert.Contains(t, err.Error(), "STARTTLS")
+		assert.Empty(t, srv.received())
+	})
+
+	t.Run("InsecureSendsWithoutSTARTTLS", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, nil, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithInsecure(true),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(context.Background(), "Ada", "ada@example.com")
+
+		// Assert
+		require.NoError(t, err)
+		sent := srv.received()
+		require.Len(t, sent, 1)
+		assert.False(t, sent[0].tls)
+	})
+
+	t.Run("UntrustedCertificateFails", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(context.Background(), "Ada", "ada@example.com")
+
+		// Assert
+		require.Error(t, err)
+		assert.Empty(t, srv.received())
+	})
+
+	t.Run("CheckLogsInWithoutSending", func(t *testing.T) {
+		// Arrange
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://mailer:s3cret@"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Check(context.Background())
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Empty(t, srv.received())
+	})
+
+	t.Run("CheckFailsWhenUnreachable", func(t *testing.T) {
+		// Arrange
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		require.NoError(t, err)
+		addr := ln.Addr().String()
+		ln.Close()
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+addr),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Check(context.Background())
+
+		// Assert
+		assert.Error(t, err)
+	})
+
+	t.Run("TemplatesDirOverridesPerFile", func(t *testing.T) {
+		// Arrange
+		dir := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(dir, "welcome.subject.txt"), []byte("Hello from {{.Email}}"), 0o600))
+		srv := newSMTPStandIn(t, serverTLS, false)
+		n, err := smtpnotifier.NewNotifier(
+			notifier.WithLocation("smtp://"+srv.addr()),
+			smtpnotifier.WithFrom("no-reply@example.com"),
+			smtpnotifier.WithTLSConfig(clientTLS),
+			smtpnotifier.WithTemplatesDir(dir),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(context.Background(), "Ada", "ada@example.com")
+
+		// Assert
+		require.NoError(t, err)
+		msg := parseSentMail(t, srv.received()[0].data)
+		assert.Equal(t, "Hello from ada@example.com", msg.subject)
+		assert.Contains(t, msg.parts["text/plain"], "Hi Ada,")
+	})
+
+	t.Run("InvalidConfigurationIsRejected", func(t *testing.T) {
+		badTemplates := t.TempDir()
+		require.NoError(t, os.WriteFile(filepath.Join(badTemplates, "welcome.html"), []byte("{{.Name"), 0o600))
+
+		for name, opts := range map[string][]notifier.Option{
+			"NoFrom":      {notifier.WithLocation("smtp://localhost:25")},
+			"BadFrom":     {notifier.WithLocation("smtp://localhost:25"), smtpnotifier.WithFrom("not an address")},
+			"BadScheme":   {notifier.WithLocation("http://localhost:25"), smtpnotifier.WithFrom("a@example.com")},
+			"NoHost":      {notifier.WithLocation("smtp://"), smtpnotifier.WithFrom("a@example.com")},
+			"BadTemplate": {notifier.WithLocation("smtp://localhost:25"), smtpnotifier.WithFrom("a@example.com"), smtpnotifier.WithTemplatesDir(badTemplates)},
+		} {
+			// Act
+			_, err := smtpnotifier.NewNotifier(opts...)
+
+			// Assert
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+// sentMail is a message as the stand-in received it
+type sentMail struct {
+	from string
+	to   []string
+	auth string
+	tls  bool
+	data []byte
+}
+
+// smtpStandIn is just enough of an SMTP server to accept mail
+// from net/smtp, with STARTTLS and AUTH PLAIN
+type smtpStandIn struct {
+	ln net.Listener
+	// tlsConfig enables STARTTLS, or TLS from the start when implicit
+	tlsConfig *tls.Config
+	implicit  bool
+
+	mu   sync.Mutex
+	mail []sentMail
+}
+
+func newSMTPStandIn(t *testing.T, tlsConfig *tls.Config, implicit bool) *smtpStandIn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+
+	s := &smtpStandIn{ln: ln, tlsConfig: tlsConfig, implicit: implicit}
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go s.serve(conn)
+		}
+	}()
+
+	t.Cleanup(func() { ln.Close() })
+
+	return s
+}
+
+func (s *smtpStandIn) addr() string {
+	return s.ln.Addr().String()
+}
+
+func (s *smtpStandIn) received() []sentMail {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]sentMail(nil), s.mail...)
+}
+
+func (s *smtpStandIn) serve(conn net.Conn) {
+	defer func() { conn.Close() }()
+
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+
+	secure := false
+	if s.implicit {
+		conn = tls.Server(conn, s.tlsConfig)
+		secure = true
+	}
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 stand-in ESMTP")
+
+	var m sentMail
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		verb, arg, _ := strings.Cut(line, " ")
+
+		switch strings.ToUpper(verb) {
+		case "EHLO":
+			tp.PrintfLine("250-stand-in")
+			if s.tlsConfig != nil && !secure {
+				tp.PrintfLine("250-STARTTLS")
+			}
+			tp.PrintfLine("250 AUTH PLAIN")
+		case "HELO", "NOOP":
+			tp.PrintfLine("250 OK")
+		case "STARTTLS":
+			tp.PrintfLine("220 ready")
+			conn = tls.Server(conn, s.tlsConfig)
+			tp = textproto.NewConn(conn)
+			secure = true
+		case "AUTH":
+			_, resp, _ := strings.Cut(arg, " ")
+			bs, err := base64.StdEncoding.DecodeString(resp)
+			if err != nil {
+				tp.PrintfLine("501 bad response")
+				continue
+			}
+			m.auth = string(bs)
+			tp.PrintfLine("235 accepted")
+		case "MAIL":
+			m.from = addrOf(arg)
+			tp.PrintfLine("250 OK")
+		case "RCPT":
+			m.to = append(m.to, addrOf(arg))
+			tp.PrintfLine("250 OK")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := tp.ReadDotBytes()
+			if err != nil {
+				return
+			}
+			m.data = data
+			m.tls = secure
+			s.mu.Lock()
+			s.mail = append(s.mail, m)
+			s.mu.Unlock()
+			m = sentMail{auth: m.auth}
+			tp.PrintfLine("250 queued")
+		case "RSET":
+			m = sentMail{auth: m.auth}
+			tp.PrintfLine("250 OK")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+// addrOf returns the address in "FROM:<a@example.com> ..."
+func addrOf(arg string) string {
+	_, rest, _ := strings.Cut(arg, "<")
+	addr, _, _ := strings.Cut(rest, ">")
+	return addr
+}
+
+// parsedMail is a sent message taken apart
+type parsedMail struct {
+	header  mail.Header
+	subject string
+	// types are the content types of the parts in order
+	types []string
+	parts map[string]string
+}
+
+func parseSentMail(t *testing.T, data []byte) parsedMail {
+	t.Helper()
+
+	msg, err := mail.ReadMessage(bufio.NewReader(strings.NewReader(string(data))))
+	require.NoError(t, err)
+
+	subject, err := new(mime.WordDecoder).DecodeHeader(msg.Header.Get("Subject"))
+	require.NoError(t, err)
+
+	mediaType, params, err := mime.ParseMediaType(msg.Header.Get("Content-Type"))
+	require.NoError(t, err)
+	require.Equal(t, "multipart/alternative", mediaType)
+
+	parsed := parsedMail{header: msg.Header, subject: subject, parts: map[string]string{}}
+
+	r := multipart.NewReader(msg.Body, params["boundary"])
+	for {
+		part, err := r.NextRawPart()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+
+		partType, _, err := mime.ParseMediaType(part.Header.Get("Content-Type"))
+		require.NoError(t, err)
+		require.Equal(t, "quoted-printable", part.Header.Get("Content-Transfer-Encoding"))
+
+		body, err := io.ReadAll(quotedprintable.NewReader(part))
+		require.NoError(t, err)
+
+		parsed.types = append(parsed.types, partType)
+		parsed.parts[partType] = string(body)
+	}
+
+	return parsed
+}
+
+// selfSignedTLS returns a server config with a certificate for
+// 127.0.0.1 and a client config trusting it
+func selfSignedTLS(t *testing.T) (*tls.Config, *tls.Config) {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "stand-in"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		IsCA:         true,
+
+		BasicConstraintsValid: true,
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+
+	server := &tls.Config{Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}}}
+	client := &tls.Config{RootCAs: pool}
+
+	return server, client
+}
@@ -0,0 +1,80 @@
+package unit
+
+import (
+	"encoding/base32"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/internal/totp"
+)
+
+func TestTOTP(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	// the SHA-1 key of RFC 6238's test vectors
+	secret := base32.StdEncoding.EncodeToString([]byte("12345678901234567890"))
+
+	t.Run("RFC6238Vectors", func(t *testing.T) {
+		// the RFC's codes are 8 digits, and ours their last 6
+		vectors := map[int64]string{
+			59:         "287082",
+			1111111109: "081804",
+			1111111111: "050471",
+			1234567890: "005924",
+			2000000000: "279037",
+		}
+
+		for unix, want := range vectors {
+			// Act
+			code, err := totp.Code(secret, time.Unix(unix, 0))
+
+			// Assert
+			require.NoError(t, err)
+			assert.Equal(t, want, code, "at %d", unix)
+		}
+	})
+
+	t.Run("ValidateAllowsSkewOnly", func(t *testing.T) {
+		// Arrange
+		now := time.Unix(1234567890, 0)
+		previous, err := totp.Code(secret, now.Add(-totp.Period))
+		require.NoError(t, err)
+		stale, err := totp.Code(secret, now.Add(-2*totp.Period))
+		require.NoError(t, err)
+
+		// Act
+		counter, ok := totp.Validate(secret, previous, now)
+		_, staleOk := totp.Validate(secret, stale, now)
+		_, shortOk := totp.Validate(secret, previous[1:], now)
+
+		// Assert
+		assert.True(t, ok)
+		assert.Equal(t, uint64(1234567890/30-1), counter)
+		assert.False(t, staleOk)
+		assert.False(t, shortOk)
+	})
+
+	t.Run("URI", func(t *testing.T) {
+		// Arrange
+		secret, err := totp.GenerateSecret()
+		require.NoError(t, err)
+
+		// Act
+		uri, err := url.Parse(totp.URI("demo go", "a@test.com", secret))
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "otpauth", uri.Scheme)
+		assert.Equal(t, "totp", uri.Host)
+		assert.Equal(t, "/demo go:a@test.com", uri.Path)
+		assert.Equal(t, secret, uri.Query().Get("secret"))
+		assert.Equal(t, "demo go", uri.Query().Get("issuer"))
+	})
+}
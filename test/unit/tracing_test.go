@@ -0,0 +1,183 @@
+package unit
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/gorilla/mux"
+	"github.com/mark3labs/mcp-go/mcp"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
+	"github.com/w-h-a/demo-go/internal/logger"
+	logginghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/logging"
+	tracinghttpmiddleware "github.com/w-h-a/demo-go/internal/middleware/http/tracing"
+	tracingmcpmiddleware "github.com/w-h-a/demo-go/internal/middleware/mcp/tracing"
+	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"github.com/w-h-a/demo-go/internal/tracer"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func TestTracing(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	otel.SetTextMapPropagator(propagation.TraceContext{})
+
+	// recorder returns a provider whose ended spans are kept in memory
+	recorder := func() (*sdktrace.TracerProvider, *tracetest.SpanRecorder) {
+		sr := tracetest.NewSpanRecorder()
+		return sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(sr)), sr
+	}
+
+	// named returns the ended span called name
+	named := func(t *testing.T, sr *tracetest.SpanRecorder, name string) sdktrace.ReadOnlySpan {
+		for _, s := range sr.Ended() {
+			if s.Name() == name {
+				return s
+			}
+		}
+		require.Failf(t, "span not found", "no span named %q", name)
+		return nil
+	}
+
+	t.Run("HTTPSpansContinueCallerTracesAndNameRoutes", func(t *testing.T) {
+		// Arrange
+		tp, sr := recorder()
+		var buf bytes.Buffer
+		l, err := logger.New(&buf, logger.FormatJSON, "info")
+		require.NoError(t, err)
+		router := mux.NewRouter()
+		router.Use(tracinghttpmiddleware.Route)
+		router.HandleFunc("/things/{id}", func(w http.ResponseWriter, r *http.Request) {})
+		h := tracinghttpmiddleware.New(tp)(logginghttpmiddleware.New(l)(router))
+		req := httptest.NewRequest(http.MethodGet, "/things/1", nil)
+		req.Header.Set("traceparent", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+
+		// Act
+		h.ServeHTTP(httptest.NewRecorder(), req)
+
+		// Assert
+		span := named(t, sr, "GET /things/{id}")
+		assert.Equal(t, "4bf92f3577b34da6a3ce929d0e0e4736", span.SpanContext().TraceID().String())
+		assert.Equal(t, "00f067aa0ba902b7", span.Parent().SpanID().String())
+		var record map[string]any
+		require.NoError(t, json.Unmarshal(buf.Bytes(), &record))
+		assert.Equal(t, span.SpanContext().TraceID().String(), record["trace_id"])
+		assert.Equal(t, span.SpanContext().SpanID().String(), record["span_id"])
+	})
+
+	t.Run("WelcomeEmailJoinsTheRequestTrace", func(t *testing.T) {
+		// Arrange
+		tp, sr := recorder()
+		svc := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier(), userservice.WithTracerProvider(tp))
+		require.NoError(t, svc.Start())
+		ctx, request := tp.Tracer("test").Start(context.Background(), "request")
+
+		// Act
+		_, err := svc.CreateUser(ctx, user.CreateUserDTO{Name: "Traced", Email: "traced@test.com"})
+		request.End()
+		require.NoError(t, svc.Stop())
+
+		// Assert
+		require.NoError(t, err)
+		create := named(t, sr, "UserService.CreateUser")
+		deliver := named(t, sr, "UserService.deliver")
+		assert.Equal(t, request.SpanContext().SpanID(), create.Parent().SpanID())
+		assert.Equal(t, request.SpanContext().TraceID(), deliver.SpanContext().TraceID())
+		assert.Equal(t, create.SpanContext().SpanID(), deliver.Parent().SpanID())
+	})
+
+	t.Run("ServiceErrorsFailTheirSpans", func(t *testing.T) {
+		// Arrange
+		tp, sr := recorder()
+		svc := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier(), userservice.WithTracerProvider(tp))
+
+		// Act
+		_, err := svc.GetUser(context.Background(), "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrUserNotFound)
+		span := named(t, sr, "UserService.GetUser")
+		assert.Equal(t, codes.Error, span.Status().Code)
+	})
+
+	t.Run("MCPToolErrorResultsFailTheirSpans", func(t *testing.T) {
+		// Arrange
+		tp, sr := recorder()
+		tool := tracingmcpmiddleware.NewTool(tp)(func(ctx context.Context, req mcp.CallToolRequest) (*mcp.CallToolResult, error) {
+			return mcp.NewToolResultError("no such user"), nil
+		})
+		req := mcp.CallToolRequest{}
+		req.Params.Name = "get_user"
+
+		// Act
+		_, err := tool(context.Background(), req)
+
+		// Assert
+		require.NoError(t, err)
+		span := named(t, sr, "tools/call get_user")
+		assert.Equal(t, codes.Error, span.Status().Code)
+	})
+
+	t.Run("FileExporterWritesSpansOnShutdown", func(t *testing.T) {
+		// Arrange
+		path := filepath.Join(t.TempDir(), "traces.json")
+		tp, err := tracer.New(context.Background(), "file://"+path, resource.Empty(), 1)
+		require.NoError(t, err)
+
+		// Act
+		_, span := tp.Tracer("test").Start(context.Background(), "written")
+		span.End()
+		require.NoError(t, tp.Shutdown(context.Background()))
+
+		// Assert
+		bs, err := os.ReadFile(path)
+		require.NoError(t, err)
+		assert.Contains(t, string(bs), `"Name":"written"`)
+	})
+
+	t.Run("OTLPExporterPostsToTheCollector", func(t *testing.T) {
+		// Arrange
+		received := make(chan string, 1)
+		collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.Copy(io.Discard, r.Body)
+			received <- r.Method + " " + r.URL.Path
+		}))
+		defer collector.Close()
+		tp, err := tracer.New(context.Background(), collector.URL, resource.Empty(), 1)
+		require.NoError(t, err)
+
+		// Act
+		_, span := tp.Tracer("test").Start(context.Background(), "sent")
+		span.End()
+		require.NoError(t, tp.Shutdown(context.Background()))
+
+		// Assert
+		assert.Equal(t, "POST /v1/traces", <-received)
+	})
+
+	t.Run("RejectsUnknownLocations", func(t *testing.T) {
+		// Act
+		_, err := tracer.New(context.Background(), "jaeger://localhost", resource.Empty(), 1)
+
+		// Assert
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,179 @@
+package unit
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/api/user"
+	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
+)
+
+func TestMemoryUserRepo(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	t.Run("CreateAndGet", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+		dto := user.CreateUserDTO{Name: "Test User", Email: "test@test.com"}
+
+		// Act
+		created, err := repo.Create(ctx, dto)
+		require.NoError(t, err)
+		byID, errByID := repo.GetByID(ctx, created.ID)
+		byEmail, errByEmail := repo.GetByEmail(ctx, dto.Email)
+		all, errAll := repo.GetAll(ctx)
+
+		// Assert
+		assert.NotEmpty(t, created.ID)
+		assert.NoError(t, errByID)
+		assert.Equal(t, created, byID)
+		assert.NoError(t, errByEmail)
+		assert.Equal(t, created, byEmail)
+		assert.NoError(t, errAll)
+		assert.Equal(t, []user.User{created}, all)
+		assert.False(t, created.CreatedAt.IsZero())
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+
+		// Act
+		_, errByID := repo.GetByID(ctx, "missing")
+		_, errByEmail := repo.GetByEmail(ctx, "missing@test.com")
+
+		// Assert
+		assert.ErrorIs(t, errByID, userrepo.ErrUserNotFound)
+		assert.ErrorIs(t, errByEmail, userrepo.ErrUserNotFound)
+	})
+
+	t.Run("DuplicateEmail", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+		dto := user.CreateUserDTO{Name: "Test User", Email: "test@test.com"}
+		_, err := repo.Create(ctx, dto)
+		require.NoError(t, err)
+
+		// Act
+		_, err = repo.Create(ctx, dto)
+
+		// Assert
+		assert.ErrorIs(t, err, userrepo.ErrEmailInUse)
+	})
+
+	t.Run("ConcurrentCreate", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+		var wg sync.WaitGroup
+
+		// Act
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				_, _ = repo.Create(ctx, user.CreateUserDTO{Name: "Test User", Email: fmt.Sprintf("test%d@test.com", i)})
+			}(i)
+		}
+		wg.Wait()
+		all, err := repo.GetAll(ctx)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, all, 50)
+	})
+
+	t.Run("OutboxClaimsAreExclusive", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+		_, err := repo.Create(ctx, user.CreateUserDTO{Name: "Test User", Email: "test@test.com"}, userrepo.WithOutboxMessage(userrepo.OutboxMessage{ID: "welcome", Kind: "welcome"}))
+		require.NoError(t, err)
+		require.NoError(t, repo.EnqueueOutboxMessage(ctx, userrepo.OutboxMessage{ID: "reset", Kind: "password_reset"}))
+		var wg sync.WaitGroup
+		claims := make(chan []userrepo.OutboxMessage, 10)
+
+		// Act
+		for i := 0; i < 10; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				claimed, _ := repo.ClaimOutboxMessages(ctx, 10, time.Minute)
+				claims <- claimed
+			}()
+		}
+		wg.Wait()
+		close(claims)
+		retryErr := repo.RetryOutboxMessage(ctx, "reset", "boom", time.Now())
+		retried, _ := repo.ClaimOutboxMessages(ctx, 10, time.Minute)
+		deadErr := repo.DeadLetterOutboxMessage(ctx, "reset", "boom")
+		completeErr := repo.CompleteOutboxMessage(ctx, "welcome")
+		_, againErr := repo.ClaimOutboxMessages(ctx, 10, 0)
+
+		// Assert
+		seen := map[string]int{}
+		for claimed := range claims {
+			for _, m := range claimed {
+				seen[m.ID]++
+			}
+		}
+		assert.Equal(t, map[string]int{"welcome": 1, "reset": 1}, seen)
+		assert.NoError(t, retryErr)
+		require.Len(t, retried, 1)
+		assert.Equal(t, 2, retried[0].Attempts)
+		assert.Equal(t, "boom", retried[0].LastError)
+		assert.NoError(t, deadErr)
+		assert.NoError(t, completeErr)
+		assert.ErrorIs(t, repo.CompleteOutboxMessage(ctx, "welcome"), userrepo.ErrOutboxMessageNotFound)
+		assert.NoError(t, againErr)
+	})
+
+	t.Run("EventsAreStoredWithTheChange", func(t *testing.T) {
+		// Arrange
+		repo := memoryuserrepo.NewUserRepo()
+		require.NoError(t, repo.CreateWebhook(ctx, userrepo.Webhook{ID: "hook", URL: "https://example.com"}))
+		event := func(name string) userrepo.EventFunc {
+			return func(u user.User) (userrepo.Event, error) {
+				return userrepo.Event{
+					Messages: []userrepo.OutboxMessage{{ID: name, Event: name, Email: u.Email}},
+					Deliveries: []userrepo.EventDelivery{
+						{Delivery: userrepo.WebhookDelivery{ID: name + "-hook", WebhookID: "hook", Event: name}, Message: userrepo.OutboxMessage{ID: name + "-hook-message", DeliveryID: name + "-hook"}},
+						{Delivery: userrepo.WebhookDelivery{ID: name + "-gone", WebhookID: "gone", Event: name}, Message: userrepo.OutboxMessage{ID: name + "-gone-message", DeliveryID: name + "-gone"}},
+					},
+				}, nil
+			}
+		}
+		failing := func(u user.User) (userrepo.Event, error) { return userrepo.Event{}, errors.New("boom") }
+
+		// Act
+		created, createErr := repo.Create(ctx, user.CreateUserDTO{Name: "Test User", Email: "test@test.com"}, userrepo.WithEvent(event("created")))
+		_, failedErr := repo.Update(ctx, created.ID, user.UpdateUserDTO{Name: "Failed", Email: "failed@test.com"}, userrepo.WithChangeEvent(failing))
+		unchanged, _ := repo.GetByID(ctx, created.ID)
+		deleteErr := repo.Delete(ctx, created.ID, userrepo.WithChangeEvent(event("deleted")))
+		claimed, _ := repo.ClaimOutboxMessages(ctx, 10, time.Minute)
+		deliveries, _ := repo.ListWebhookDeliveries(ctx, "hook", 10)
+
+		// Assert
+		require.NoError(t, createErr)
+		assert.EqualError(t, failedErr, "boom")
+		assert.Equal(t, created, unchanged)
+		assert.NoError(t, deleteErr)
+		var ids []string
+		for _, m := range claimed {
+			ids = append(ids, m.ID)
+		}
+		assert.ElementsMatch(t, []string{"created", "created-hook-message", "deleted", "deleted-hook-message"}, ids)
+		assert.Len(t, deliveries, 2)
+	})
+}
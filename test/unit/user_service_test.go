@@ -2,17 +2,29 @@ package unit
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
 	"os"
-	"sync"
+	"strings"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/assert"
 	testmock "github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
 	"github.com/w-h-a/demo-go/api/user"
+	"github.com/w-h-a/demo-go/internal/authz"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	memorynotifier "github.com/w-h-a/demo-go/internal/client/notifier/memory"
 	mocknotifier "github.com/w-h-a/demo-go/internal/client/notifier/mock"
+	"github.com/w-h-a/demo-go/internal/client/notifier/webhook"
 	userrepo "github.com/w-h-a/demo-go/internal/client/user_repo"
+	memoryuserrepo "github.com/w-h-a/demo-go/internal/client/user_repo/memory"
 	mockrepo "github.com/w-h-a/demo-go/internal/client/user_repo/mock"
+	"github.com/w-h-a/demo-go/internal/middleware"
 	userservice "github.com/w-h-a/demo-go/internal/service/user"
+	"github.com/w-h-a/demo-go/internal/totp"
 )
 
 func TestUserService_CreateUser(t *testing.T) {
@@ -27,27 +39,1545 @@ func TestUserService_CreateUser(t *testing.T) {
 
 	t.Run("Success", func(t *testing.T) {
 		// Arrange
-		var wg sync.WaitGroup
-		wg.Add(1)
-
 		mockRepo := mockrepo.NewUserRepo()
 		mockNotifier := mocknotifier.NewNotifier()
 		userService := userservice.New(mockRepo, mockNotifier)
 
-		mockRepo.On("GetByEmail", ctx, dto.Email).Return(user.User{}, userrepo.ErrUserNotFound)
-		mockRepo.On("Create", ctx, dto).Return(expectedUser, nil)
-		mockNotifier.On("Notify", testmock.Anything, expectedUser.Name, expectedUser.Email, testmock.Anything).Return(nil).Run(func(args testmock.Arguments) {
-			wg.Done()
-		})
+		mockRepo.On("GetByEmail", testmock.Anything, dto.Email).Return(user.User{}, userrepo.ErrUserNotFound)
+		// the welcome email is queued in the same call as the user is stored
+		mockRepo.On("Create", testmock.Anything, dto, testmock.MatchedBy(func(opts []userrepo.CreateOption) bool {
+			outbox := userrepo.NewCreateOptions(opts...).Outbox
+			return len(outbox) == 1 && outbox[0].Kind == string(notifier.KindWelcome) && outbox[0].Email == dto.Email && outbox[0].Token != ""
+		})).Return(expectedUser, nil)
 
 		// Act
 		u, err := userService.CreateUser(ctx, dto)
-		wg.Wait()
 
 		// Assert
 		assert.NoError(t, err)
 		assert.Equal(t, expectedUser, u)
 		mockRepo.AssertExpectations(t)
-		mockNotifier.AssertExpectations(t)
+		mockNotifier.AssertNotCalled(t, "Notify")
+	})
+}
+
+func TestUserService_UpdateUser(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	id := "some-uuid"
+	dto := user.UpdateUserDTO{Name: " New Name ", Email: " New@Test.com "}
+	normalised := user.UpdateUserDTO{Name: "New Name", Email: "new@test.com"}
+	expectedUser := user.User{ID: id, Name: normalised.Name, Email: normalised.Email}
+
+	t.Run("Success", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("GetByEmail", testmock.Anything, normalised.Email).Return(user.User{}, userrepo.ErrUserNotFound)
+		mockRepo.On("Update", testmock.Anything, id, normalised).Return(expectedUser, nil)
+		// the email changed, so it has to be verified again
+		mockRepo.On("SaveVerificationToken", testmock.Anything, testmock.MatchedBy(func(t userrepo.VerificationToken) bool {
+			return t.UserID == id && t.Email == normalised.Email && t.TokenHash != ""
+		})).Return(nil)
+		mockRepo.On("EnqueueOutboxMessage", testmock.Anything, testmock.MatchedBy(func(m userrepo.OutboxMessage) bool {
+			return m.Kind == string(notifier.KindVerifyEmail) && m.Email == normalised.Email && m.Token != ""
+		})).Return(nil)
+
+		// Act
+		u, err := userService.UpdateUser(ctx, id, dto)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, expectedUser, u)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("SameEmailNotReverified", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("GetByEmail", testmock.Anything, normalised.Email).Return(expectedUser, nil)
+		mockRepo.On("Update", testmock.Anything, id, normalised).Return(expectedUser, nil)
+
+		// Act
+		_, err := userService.UpdateUser(ctx, id, dto)
+
+		// Assert
+		assert.NoError(t, err)
+		mockRepo.AssertNotCalled(t, "SaveVerificationToken", testmock.Anything, testmock.Anything)
+	})
+
+	t.Run("EmailInUse", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("GetByEmail", testmock.Anything, normalised.Email).Return(user.User{ID: "other-uuid"}, nil)
+
+		// Act
+		_, err := userService.UpdateUser(ctx, id, dto)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrEmailInUse)
+		mockRepo.AssertNotCalled(t, "Update", testmock.Anything, testmock.Anything, testmock.Anything)
+	})
+
+	t.Run("InvalidInput", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		// Act
+		_, err := userService.UpdateUser(ctx, id, user.UpdateUserDTO{Name: "  ", Email: "new@test.com"})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidInput)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("GetByEmail", testmock.Anything, normalised.Email).Return(user.User{}, userrepo.ErrUserNotFound)
+		mockRepo.On("Update", testmock.Anything, id, normalised).Return(user.User{}, userrepo.ErrUserNotFound)
+
+		// Act
+		_, err := userService.UpdateUser(ctx, id, dto)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrUserNotFound)
+	})
+}
+
+func TestUserService_PatchUser(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	existing := user.User{ID: "some-uuid", Name: "Test User", Email: "test@test.com"}
+
+	t.Run("OnlyName", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+		name := "Renamed"
+		expected := user.UpdateUserDTO{Name: name, Email: existing.Email}
+
+		mockRepo.On("GetByID", testmock.Anything, existing.ID).Return(existing, nil)
+		mockRepo.On("GetByEmail", testmock.Anything, existing.Email).Return(existing, nil)
+		mockRepo.On("Update", testmock.Anything, existing.ID, expected).Return(user.User{ID: existing.ID, Name: name, Email: existing.Email}, nil)
+
+		// Act
+		u, err := userService.PatchUser(ctx, existing.ID, user.PatchUserDTO{Name: &name})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, name, u.Name)
+		assert.Equal(t, existing.Email, u.Email)
+		mockRepo.AssertExpectations(t)
+	})
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("GetByID", testmock.Anything, existing.ID).Return(user.User{}, userrepo.ErrUserNotFound)
+
+		// Act
+		_, err := userService.PatchUser(ctx, existing.ID, user.PatchUserDTO{})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrUserNotFound)
+	})
+}
+
+func TestUserService_DeleteUser(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	t.Run("NotFound", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier())
+
+		mockRepo.On("Delete", testmock.Anything, "missing").Return(userrepo.ErrUserNotFound)
+
+		// Act
+		err := userService.DeleteUser(ctx, "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrUserNotFound)
+		mockRepo.AssertExpectations(t)
+	})
+}
+
+func TestUserService_GetAllUsers(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	seed := func(t *testing.T) *userservice.Service {
+		repo := memoryuserrepo.NewUserRepo()
+		for _, name := range []string{"alice", "bob", "carol", "dave", "erin"} {
+			_, err := repo.Create(ctx, user.CreateUserDTO{Name: name, Email: name + "@test.com"})
+			require.NoError(t, err)
+		}
+		return userservice.New(repo, mocknotifier.NewNotifier())
+	}
+
+	t.Run("PagesWithCursor", func(t *testing.T) {
+		// Arrange
+		userService := seed(t)
+		var names []string
+		dto := user.ListUsersDTO{Limit: 2, Sort: "-name"}
+
+		// Act
+		for {
+			page, err := userService.GetAllUsers(ctx, dto)
+			require.NoError(t, err)
+			for _, u := range page.Users {
+				names = append(names, u.Name)
+			}
+			if page.NextCursor == "" {
+				break
+			}
+			dto.Cursor = page.NextCursor
+		}
+
+		// Assert
+		assert.Equal(t, []string{"erin", "dave", "carol", "bob", "alice"}, names)
+	})
+
+	t.Run("FiltersByEmailPrefix", func(t *testing.T) {
+		// Arrange
+		userService := seed(t)
+
+		// Act
+		page, err := userService.GetAllUsers(ctx, user.ListUsersDTO{Email: "CA"})
+
+		// Assert
+		assert.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "carol", page.Users[0].Name)
+		assert.Empty(t, page.NextCursor)
+	})
+
+	t.Run("RejectsMismatchedCursor", func(t *testing.T) {
+		// Arrange
+		userService := seed(t)
+		page, err := userService.GetAllUsers(ctx, user.ListUsersDTO{Limit: 1, Sort: "name"})
+		require.NoError(t, err)
+
+		// Act
+		_, err = userService.GetAllUsers(ctx, user.ListUsersDTO{Limit: 1, Sort: "email", Cursor: page.NextCursor})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidListQuery)
+	})
+
+	t.Run("RejectsInvalidQuery", func(t *testing.T) {
+		// Arrange
+		userService := seed(t)
+
+		// Act
+		_, limitErr := userService.GetAllUsers(ctx, user.ListUsersDTO{Limit: userservice.MaxPageSize + 1})
+		_, sortErr := userService.GetAllUsers(ctx, user.ListUsersDTO{Sort: "password"})
+
+		// Assert
+		assert.ErrorIs(t, limitErr, userservice.ErrInvalidListQuery)
+		assert.ErrorIs(t, sortErr, userservice.ErrInvalidListQuery)
+	})
+}
+
+func TestUserService_Authorization(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	admin := user.User{ID: "admin", Roles: []user.Role{user.RoleAdmin}}
+
+	newService := func(t *testing.T) (*userservice.Service, user.User) {
+		userService := userservice.New(
+			memoryuserrepo.NewUserRepo(),
+			memorynotifier.NewNotifier(),
+			userservice.WithAuthorizer(authz.NewPolicyAuthorizer(authz.DefaultPolicies()...)),
+		)
+		ur, err := userService.CreateUser(ctxAs(admin), user.CreateUserDTO{Name: "Self", Email: "self@test.com"})
+		require.NoError(t, err)
+		return userService, ur
+	}
+
+	t.Run("SelfCanReadItself", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+
+		// Act
+		u, err := userService.GetUser(ctxAs(self), self.ID)
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, self.ID, u.ID)
+	})
+
+	t.Run("SelfCannotReadOthers", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+
+		// Act
+		_, err := userService.GetUser(ctxAs(user.User{ID: "someone-else"}), self.ID)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrForbidden)
+	})
+
+	t.Run("OnlyAdminsCanList", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+
+		// Act
+		_, selfErr := userService.GetAllUsers(ctxAs(self), user.ListUsersDTO{})
+		_, anonErr := userService.GetAllUsers(context.Background(), user.ListUsersDTO{})
+		page, adminErr := userService.GetAllUsers(ctxAs(admin), user.ListUsersDTO{})
+
+		// Assert
+		assert.ErrorIs(t, selfErr, userservice.ErrForbidden)
+		assert.ErrorIs(t, anonErr, userservice.ErrForbidden)
+		assert.NoError(t, adminErr)
+		assert.Len(t, page.Users, 1)
+	})
+
+	t.Run("SelfCannotAssignRoles", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+		name := "Renamed"
+		roles := []user.Role{user.RoleAdmin}
+
+		// Act
+		_, nameErr := userService.PatchUser(ctxAs(self), self.ID, user.PatchUserDTO{Name: &name})
+		_, rolesErr := userService.PatchUser(ctxAs(self), self.ID, user.PatchUserDTO{Roles: &roles})
+
+		// Assert
+		assert.NoError(t, nameErr)
+		assert.ErrorIs(t, rolesErr, userservice.ErrForbidden)
+	})
+
+	t.Run("AdminAssignsRoles", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+		roles := []user.Role{user.RoleOperator, user.RoleOperator}
+
+		// Act
+		u, err := userService.PatchUser(ctxAs(admin), self.ID, user.PatchUserDTO{Roles: &roles})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Equal(t, []user.Role{user.RoleOperator}, u.Roles)
+	})
+
+	t.Run("RejectsUnknownRole", func(t *testing.T) {
+		// Arrange
+		userService, self := newService(t)
+
+		// Act
+		_, err := userService.UpdateUser(ctxAs(admin), self.ID, user.UpdateUserDTO{Name: "Self", Email: "self@test.com", Roles: []user.Role{user.RoleSelf}})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidRole)
+	})
+}
+
+func TestUserService_Sessions(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	login := user.LoginDTO{Email: "Session@Test.com", Password: "correct horse"}
+
+	newService := func(t *testing.T) (*userservice.Service, user.User) {
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Session", Email: "session@test.com", Password: login.Password})
+		require.NoError(t, err)
+		return userService, u
+	}
+
+	t.Run("RejectsShortPassword", func(t *testing.T) {
+		// Arrange
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+
+		// Act
+		_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Short", Email: "short@test.com", Password: "short"})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidPassword)
+	})
+
+	t.Run("LoginIssuesVerifiableTokens", func(t *testing.T) {
+		// Arrange
+		userService, u := newService(t)
+
+		// Act
+		tokens, err := userService.Login(ctx, login)
+		require.NoError(t, err)
+		principal, verifyErr := userService.VerifyAccessToken(ctx, tokens.AccessToken)
+
+		// Assert
+		assert.Equal(t, "Bearer", tokens.TokenType)
+		assert.NotEmpty(t, tokens.RefreshToken)
+		assert.True(t, userService.IsAccessToken(tokens.AccessToken))
+		assert.NoError(t, verifyErr)
+		assert.Equal(t, u.ID, principal.ID)
+	})
+
+	t.Run("RejectsWrongPasswordAndUnknownEmail", func(t *testing.T) {
+		// Arrange
+		userService, _ := newService(t)
+
+		// Act
+		_, wrongErr := userService.Login(ctx, user.LoginDTO{Email: login.Email, Password: "wrong password"})
+		_, unknownErr := userService.Login(ctx, user.LoginDTO{Email: "nobody@test.com", Password: login.Password})
+
+		// Assert
+		assert.ErrorIs(t, wrongErr, userservice.ErrInvalidCredentials)
+		assert.ErrorIs(t, unknownErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("RefreshRotatesToken", func(t *testing.T) {
+		// Arrange
+		userService, _ := newService(t)
+		tokens, err := userService.Login(ctx, login)
+		require.NoError(t, err)
+
+		// Act
+		refreshed, err := userService.Refresh(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken})
+		_, reuseErr := userService.Refresh(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.NotEqual(t, tokens.RefreshToken, refreshed.RefreshToken)
+		assert.ErrorIs(t, reuseErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("LogoutRevokesAccessTokens", func(t *testing.T) {
+		// Arrange
+		userService, _ := newService(t)
+		tokens, err := userService.Login(ctx, login)
+		require.NoError(t, err)
+
+		// Act
+		logoutErr := userService.Logout(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken})
+		againErr := userService.Logout(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken})
+		_, verifyErr := userService.VerifyAccessToken(ctx, tokens.AccessToken)
+		_, refreshErr := userService.Refresh(ctx, user.RefreshDTO{RefreshToken: tokens.RefreshToken})
+
+		// Assert
+		assert.NoError(t, logoutErr)
+		assert.NoError(t, againErr)
+		assert.ErrorIs(t, verifyErr, userservice.ErrInvalidCredentials)
+		assert.ErrorIs(t, refreshErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("RejectsTokensFromOtherSecrets", func(t *testing.T) {
+		// Arrange
+		userService, _ := newService(t)
+		other := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier())
+		tokens, err := userService.Login(ctx, login)
+		require.NoError(t, err)
+
+		// Act
+		_, verifyErr := other.VerifyAccessToken(ctx, tokens.AccessToken)
+
+		// Assert
+		assert.ErrorIs(t, verifyErr, userservice.ErrInvalidCredentials)
 	})
 }
+
+func TestUserService_EmailVerification(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	t.Run("TokenFromWelcomeVerifiesOnce", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t)
+		created, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: "verify@test.com"})
+		require.NoError(t, err)
+		token := <-tokens
+
+		// Act
+		u, err := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: token})
+		_, againErr := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: token})
+
+		// Assert
+		assert.False(t, created.EmailVerified)
+		assert.NoError(t, err)
+		assert.True(t, u.EmailVerified)
+		assert.ErrorIs(t, againErr, userservice.ErrInvalidVerificationToken)
+	})
+
+	t.Run("ExpiredTokenRejected", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithVerification(-time.Minute, time.Minute))
+		_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: "verify@test.com"})
+		require.NoError(t, err)
+
+		// Act
+		_, err = userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: <-tokens})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrVerificationTokenExpired)
+	})
+
+	t.Run("ResendThrottledAndReplacesToken", func(t *testing.T) {
+		// Arrange
+		throttled, _ := tokenCapturingService(t)
+		u, err := throttled.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: "verify@test.com"})
+		require.NoError(t, err)
+		userService, tokens := tokenCapturingService(t, userservice.WithVerification(time.Hour, 0))
+		other, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: "verify@test.com"})
+		require.NoError(t, err)
+		first := <-tokens
+
+		// Act
+		throttledErr := throttled.ResendVerification(ctx, u.ID)
+		resendErr := userService.ResendVerification(ctx, other.ID)
+		second := <-tokens
+		_, firstErr := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: first})
+		_, secondErr := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: second})
+		verifiedErr := userService.ResendVerification(ctx, other.ID)
+
+		// Assert
+		assert.ErrorIs(t, throttledErr, userservice.ErrVerificationThrottled)
+		assert.NoError(t, resendErr)
+		assert.ErrorIs(t, firstErr, userservice.ErrInvalidVerificationToken)
+		assert.NoError(t, secondErr)
+		assert.ErrorIs(t, verifiedErr, userservice.ErrEmailAlreadyVerified)
+	})
+
+	t.Run("EmailChangeNeedsVerifying", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t)
+		created, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: "verify@test.com"})
+		require.NoError(t, err)
+		oldToken := <-tokens
+		email := "changed@test.com"
+
+		// Act
+		changed, err := userService.PatchUser(ctx, created.ID, user.PatchUserDTO{Email: &email})
+		require.NoError(t, err)
+		newToken := <-tokens
+		_, oldErr := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: oldToken})
+		verified, newErr := userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: newToken})
+
+		// Assert
+		assert.False(t, changed.EmailVerified)
+		assert.ErrorIs(t, oldErr, userservice.ErrInvalidVerificationToken)
+		assert.NoError(t, newErr)
+		assert.Equal(t, email, verified.Email)
+		assert.True(t, verified.EmailVerified)
+	})
+
+	t.Run("FiltersByVerification", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t)
+		_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verified", Email: "verified@test.com"})
+		require.NoError(t, err)
+		_, err = userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: <-tokens})
+		require.NoError(t, err)
+		_, err = userService.CreateUser(ctx, user.CreateUserDTO{Name: "Unverified", Email: "unverified@test.com"})
+		require.NoError(t, err)
+		unverified := false
+
+		// Act
+		page, err := userService.GetAllUsers(ctx, user.ListUsersDTO{EmailVerified: &unverified})
+
+		// Assert
+		assert.NoError(t, err)
+		require.Len(t, page.Users, 1)
+		assert.Equal(t, "unverified@test.com", page.Users[0].Email)
+	})
+
+	t.Run("LoginBlockedUntilVerified", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithRequireVerifiedEmail(true))
+		login := user.LoginDTO{Email: "verify@test.com", Password: "correct horse"}
+		_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Verify", Email: login.Email, Password: login.Password})
+		require.NoError(t, err)
+
+		// Act
+		_, blockedErr := userService.Login(ctx, login)
+		_, wrongErr := userService.Login(ctx, user.LoginDTO{Email: login.Email, Password: "wrong password"})
+		_, err = userService.VerifyEmail(ctx, user.VerifyEmailDTO{Token: <-tokens})
+		require.NoError(t, err)
+		_, loginErr := userService.Login(ctx, login)
+
+		// Assert
+		assert.ErrorIs(t, blockedErr, userservice.ErrEmailNotVerified)
+		assert.ErrorIs(t, wrongErr, userservice.ErrInvalidCredentials)
+		assert.NoError(t, loginErr)
+	})
+}
+
+func TestUserService_PasswordReset(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	email := "reset@test.com"
+
+	newUser := func(t *testing.T, userService *userservice.Service, tokens chan string) user.User {
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Reset", Email: email, Password: "old password"})
+		require.NoError(t, err)
+		<-tokens // the welcome email's verification token
+		return u
+	}
+
+	t.Run("ResetsPasswordOnceAndRevokesSessions", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t)
+		newUser(t, userService, tokens)
+		session, err := userService.Login(ctx, user.LoginDTO{Email: email, Password: "old password"})
+		require.NoError(t, err)
+		require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: " Reset@Test.com "}))
+		token := <-tokens
+
+		// Act
+		shortErr := userService.ConfirmPasswordReset(ctx, user.PasswordResetConfirmDTO{Token: token, Password: "short"})
+		err = userService.ConfirmPasswordReset(ctx, user.PasswordResetConfirmDTO{Token: token, Password: "new password"})
+		againErr := userService.ConfirmPasswordReset(ctx, user.PasswordResetConfirmDTO{Token: token, Password: "newer password"})
+		_, oldErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: "old password"})
+		_, newErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: "new password"})
+		_, sessionErr := userService.VerifyAccessToken(ctx, session.AccessToken)
+
+		// Assert
+		assert.ErrorIs(t, shortErr, userservice.ErrInvalidPassword)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, againErr, userservice.ErrInvalidToken)
+		assert.ErrorIs(t, oldErr, userservice.ErrInvalidCredentials)
+		assert.NoError(t, newErr)
+		assert.ErrorIs(t, sessionErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("UnknownEmailSucceedsSilently", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t)
+
+		// Act
+		err := userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: "nobody@test.com"})
+
+		// Assert
+		assert.NoError(t, err)
+		select {
+		case <-tokens:
+			t.Fatal("sent a token to an unknown email")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("ExpiredTokenRejected", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithPasswordResetTTL(-time.Minute))
+		newUser(t, userService, tokens)
+		require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email}))
+
+		// Act
+		err := userService.ConfirmPasswordReset(ctx, user.PasswordResetConfirmDTO{Token: <-tokens, Password: "new password"})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidToken)
+	})
+
+	t.Run("RequestsThrottledByTheirOwnInterval", func(t *testing.T) {
+		// Arrange
+		throttled, throttledTokens := tokenCapturingService(t, userservice.WithVerification(time.Hour, 0))
+		newUser(t, throttled, throttledTokens)
+		userService, tokens := tokenCapturingService(t, userservice.WithOneTimeTokenResendInterval(0))
+		newUser(t, userService, tokens)
+
+		// Act
+		require.NoError(t, throttled.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email}))
+		<-throttledTokens
+		throttledErr := throttled.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email})
+		require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email}))
+		first := <-tokens
+		resendErr := userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email})
+		second := <-tokens
+
+		// Assert
+		assert.NoError(t, throttledErr)
+		select {
+		case <-throttledTokens:
+			t.Fatal("sent a second token within the interval")
+		case <-time.After(50 * time.Millisecond):
+		}
+		assert.NoError(t, resendErr)
+		assert.NotEqual(t, first, second)
+	})
+}
+
+func TestUserService_MagicLink(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	email := "magic@test.com"
+
+	t.Run("DisabledByDefault", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t)
+
+		// Act
+		requestErr := userService.RequestMagicLink(ctx, user.MagicLinkRequestDTO{Email: email})
+		_, loginErr := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: "token"})
+
+		// Assert
+		assert.ErrorIs(t, requestErr, userservice.ErrMagicLinkDisabled)
+		assert.ErrorIs(t, loginErr, userservice.ErrMagicLinkDisabled)
+	})
+
+	t.Run("IssuesSessionTokensOnce", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithMagicLink(true, time.Minute))
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Magic", Email: email})
+		require.NoError(t, err)
+		<-tokens
+		require.NoError(t, userService.RequestMagicLink(ctx, user.MagicLinkRequestDTO{Email: email}))
+		token := <-tokens
+
+		// Act
+		session, err := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token})
+		require.NoError(t, err)
+		principal, verifyErr := userService.VerifyAccessToken(ctx, session.AccessToken)
+		_, againErr := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token})
+
+		// Assert
+		assert.NoError(t, verifyErr)
+		assert.Equal(t, u.ID, principal.ID)
+		assert.ErrorIs(t, againErr, userservice.ErrInvalidToken)
+	})
+
+	t.Run("ResetTokenNotAccepted", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithMagicLink(true, time.Minute))
+		_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Magic", Email: email})
+		require.NoError(t, err)
+		<-tokens
+		require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email}))
+
+		// Act
+		_, err = userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: <-tokens})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrInvalidToken)
+	})
+
+	t.Run("TOTPUserRetriesSameLinkWithCode", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithMagicLink(true, time.Minute))
+		u, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Magic", Email: email})
+		require.NoError(t, err)
+		<-tokens
+		enrollment, err := userService.EnrollTOTP(ctxAs(u), u.ID)
+		require.NoError(t, err)
+		code, err := totp.Code(enrollment.Secret, time.Now())
+		require.NoError(t, err)
+		_, err = userService.ConfirmTOTP(ctxAs(u), u.ID, user.TOTPCodeDTO{Code: code})
+		require.NoError(t, err)
+		// the confirming code's period is used up, so take the next
+		next, err := totp.Code(enrollment.Secret, time.Now().Add(totp.Period))
+		require.NoError(t, err)
+		require.NoError(t, userService.RequestMagicLink(ctx, user.MagicLinkRequestDTO{Email: email}))
+		token := <-tokens
+
+		// Act
+		_, missingErr := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token})
+		_, wrongErr := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token, TOTPCode: "123456x"})
+		session, err := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token, TOTPCode: next})
+		_, againErr := userService.LoginWithMagicLink(ctx, user.MagicLinkLoginDTO{Token: token, TOTPCode: next})
+
+		// Assert
+		assert.ErrorIs(t, missingErr, userservice.ErrTOTPRequired)
+		assert.ErrorIs(t, wrongErr, userservice.ErrInvalidTOTPCode)
+		require.NoError(t, err)
+		assert.NotEmpty(t, session.AccessToken)
+		assert.ErrorIs(t, againErr, userservice.ErrInvalidToken)
+	})
+}
+
+func TestUserService_TOTP(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	email := "totp@test.com"
+	password := "password123"
+
+	// enrolled returns a user with totp enabled, a context acting as
+	// them, their secret and recovery codes
+	enrolled := func(t *testing.T, userService *userservice.Service) (context.Context, user.User, string, []string) {
+		u, err := userService.CreateUser(context.Background(), user.CreateUserDTO{Name: "TOTP", Email: email, Password: password})
+		require.NoError(t, err)
+		ctx := ctxAs(u)
+
+		enrollment, err := userService.EnrollTOTP(ctx, u.ID)
+		require.NoError(t, err)
+		code, err := totp.Code(enrollment.Secret, time.Now())
+		require.NoError(t, err)
+		codes, err := userService.ConfirmTOTP(ctx, u.ID, user.TOTPCodeDTO{Code: code})
+		require.NoError(t, err)
+
+		return ctx, u, enrollment.Secret, codes.Codes
+	}
+
+	t.Run("EnrollReturnsURIAndRequiresConfirmation", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t, userservice.WithTokenIssuer("demo-go"))
+		u, err := userService.CreateUser(context.Background(), user.CreateUserDTO{Name: "TOTP", Email: email, Password: password})
+		require.NoError(t, err)
+
+		// Act
+		enrollment, err := userService.EnrollTOTP(ctxAs(u), u.ID)
+		require.NoError(t, err)
+		_, wrongErr := userService.ConfirmTOTP(ctxAs(u), u.ID, user.TOTPCodeDTO{Code: "000000x"})
+		_, loginErr := userService.Login(context.Background(), user.LoginDTO{Email: email, Password: password})
+
+		// Assert
+		assert.NotEmpty(t, enrollment.Secret)
+		assert.Contains(t, enrollment.URI, "otpauth://totp/demo-go:totp@test.com?")
+		assert.Contains(t, enrollment.URI, "secret="+enrollment.Secret)
+		assert.ErrorIs(t, wrongErr, userservice.ErrInvalidTOTPCode)
+		assert.NoError(t, loginErr, "unconfirmed enrollment shouldn't block login")
+	})
+
+	t.Run("OthersCannotEnroll", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t, userservice.WithAuthorizer(authz.NewPolicyAuthorizer(authz.DefaultPolicies()...)))
+		admin := user.User{ID: "admin", Roles: []user.Role{user.RoleAdmin}}
+		u, err := userService.CreateUser(ctxAs(admin), user.CreateUserDTO{Name: "TOTP", Email: email})
+		require.NoError(t, err)
+
+		// Act
+		_, err = userService.EnrollTOTP(ctxAs(user.User{ID: "other"}), u.ID)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrForbidden)
+	})
+
+	t.Run("LoginRequiresCodeAndRejectsReplay", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t)
+		ctx, _, secret, codes := enrolled(t, userService)
+		// the confirming code's period is used up, so take the next
+		next, err := totp.Code(secret, time.Now().Add(totp.Period))
+		require.NoError(t, err)
+
+		// Act
+		_, missingErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password})
+		_, wrongErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password, TOTPCode: "123456x"})
+		_, err = userService.Login(ctx, user.LoginDTO{Email: email, Password: password, TOTPCode: next})
+		_, replayErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password, TOTPCode: next})
+		_, badPasswordErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: "wrong password"})
+
+		// Assert
+		assert.Len(t, codes, 10)
+		assert.ErrorIs(t, missingErr, userservice.ErrTOTPRequired)
+		assert.ErrorIs(t, wrongErr, userservice.ErrInvalidTOTPCode)
+		assert.NoError(t, err)
+		assert.ErrorIs(t, replayErr, userservice.ErrInvalidTOTPCode)
+		assert.ErrorIs(t, badPasswordErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("RecoveryCodesWorkOnce", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t)
+		ctx, _, _, codes := enrolled(t, userService)
+
+		// Act
+		_, err := userService.Login(ctx, user.LoginDTO{Email: email, Password: password, RecoveryCode: strings.ToUpper(strings.ReplaceAll(codes[0], "-", ""))})
+		_, againErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password, RecoveryCode: codes[0]})
+		_, otherErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password, RecoveryCode: codes[1]})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.ErrorIs(t, againErr, userservice.ErrInvalidTOTPCode)
+		assert.NoError(t, otherErr)
+	})
+
+	t.Run("DisableRequiresSecondFactor", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t)
+		ctx, u, _, codes := enrolled(t, userService)
+
+		// Act
+		missingErr := userService.DisableTOTP(ctx, u.ID, user.TOTPCodeDTO{})
+		err := userService.DisableTOTP(ctx, u.ID, user.TOTPCodeDTO{RecoveryCode: codes[0]})
+		_, loginErr := userService.Login(ctx, user.LoginDTO{Email: email, Password: password})
+		againErr := userService.DisableTOTP(ctx, u.ID, user.TOTPCodeDTO{})
+
+		// Assert
+		assert.ErrorIs(t, missingErr, userservice.ErrTOTPRequired)
+		assert.NoError(t, err)
+		assert.NoError(t, loginErr)
+		assert.ErrorIs(t, againErr, userservice.ErrTOTPNotEnrolled)
+	})
+
+	t.Run("InvalidCodesInARowLockSecondFactor", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t, userservice.WithTOTPLockout(3, time.Minute))
+		ctx, u, secret, codes := enrolled(t, userService)
+		next, err := totp.Code(secret, time.Now().Add(totp.Period))
+		require.NoError(t, err)
+		login := func(dto user.LoginDTO) error {
+			dto.Email, dto.Password = email, password
+			_, err := userService.Login(ctx, dto)
+			return err
+		}
+
+		// Act
+		firstErr := login(user.LoginDTO{TOTPCode: "000000x"})
+		secondErr := login(user.LoginDTO{RecoveryCode: "not-a-code"})
+		thirdErr := login(user.LoginDTO{TOTPCode: "000000x"})
+		validErr := login(user.LoginDTO{TOTPCode: next})
+		recoveryErr := login(user.LoginDTO{RecoveryCode: codes[0]})
+		disableErr := userService.DisableTOTP(ctx, u.ID, user.TOTPCodeDTO{Code: next})
+
+		// Assert
+		assert.ErrorIs(t, firstErr, userservice.ErrInvalidTOTPCode)
+		assert.ErrorIs(t, secondErr, userservice.ErrInvalidTOTPCode)
+		assert.ErrorIs(t, thirdErr, userservice.ErrInvalidTOTPCode)
+		assert.ErrorIs(t, validErr, userservice.ErrTOTPLocked)
+		assert.ErrorIs(t, recoveryErr, userservice.ErrTOTPLocked)
+		assert.ErrorIs(t, disableErr, userservice.ErrTOTPLocked)
+	})
+
+	t.Run("ValidCodeStartsCountAgain", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t, userservice.WithTOTPLockout(3, time.Minute))
+		ctx, _, secret, codes := enrolled(t, userService)
+		next, err := totp.Code(secret, time.Now().Add(totp.Period))
+		require.NoError(t, err)
+		login := func(dto user.LoginDTO) error {
+			dto.Email, dto.Password = email, password
+			_, err := userService.Login(ctx, dto)
+			return err
+		}
+
+		// Act
+		var wrongErrs []error
+		for range 2 {
+			wrongErrs = append(wrongErrs, login(user.LoginDTO{TOTPCode: "000000x"}))
+		}
+		recoveryErr := login(user.LoginDTO{RecoveryCode: codes[0]})
+		for range 2 {
+			wrongErrs = append(wrongErrs, login(user.LoginDTO{TOTPCode: "000000x"}))
+		}
+		validErr := login(user.LoginDTO{TOTPCode: next})
+
+		// Assert
+		for _, err := range wrongErrs {
+			assert.ErrorIs(t, err, userservice.ErrInvalidTOTPCode)
+		}
+		assert.NoError(t, recoveryErr)
+		assert.NoError(t, validErr)
+	})
+
+	t.Run("AlreadyEnabled", func(t *testing.T) {
+		// Arrange
+		userService, _ := tokenCapturingService(t)
+		ctx, u, _, _ := enrolled(t, userService)
+
+		// Act
+		_, err := userService.EnrollTOTP(ctx, u.ID)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrTOTPAlreadyEnabled)
+	})
+}
+
+func TestUserService_APIKeys(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	admin := user.User{ID: "admin", Roles: []user.Role{user.RoleAdmin}}
+
+	// newService returns a service enforcing the default policies, and a user in it
+	newService := func(t *testing.T, opts ...userservice.Option) (*userservice.Service, user.User) {
+		opts = append(opts, userservice.WithAuthorizer(authz.NewPolicyAuthorizer(authz.DefaultPolicies()...)))
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), memorynotifier.NewNotifier(), opts...)
+		u, err := userService.CreateUser(ctxAs(admin), user.CreateUserDTO{Name: "Owner", Email: "owner@test.com"})
+		require.NoError(t, err)
+		return userService, u
+	}
+
+	t.Run("CreateListVerifyDelete", func(t *testing.T) {
+		// Arrange
+		userService, u := newService(t)
+		ctx := ctxAs(u)
+
+		// Act
+		created, err := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: " billing ", Scopes: []string{"users:read", "users:read"}})
+		require.NoError(t, err)
+		principal, verifyErr := userService.VerifyAPIKey(context.Background(), created.Key)
+		keys, listErr := userService.ListAPIKeys(ctx, "")
+		deleteErr := userService.DeleteAPIKey(ctx, created.ID)
+		_, revokedErr := userService.VerifyAPIKey(context.Background(), created.Key)
+
+		// Assert
+		assert.True(t, userService.IsAPIKey(created.Key))
+		assert.True(t, strings.HasPrefix(created.Key, created.Prefix))
+		assert.Equal(t, "billing", created.Name)
+		assert.Equal(t, []string{"users:read"}, created.Scopes)
+		assert.WithinDuration(t, time.Now().Add(90*24*time.Hour), created.ExpiresAt, time.Minute)
+		assert.NoError(t, verifyErr)
+		assert.Equal(t, u.ID, principal.ID)
+		assert.Equal(t, []string{"users:read"}, principal.Scopes)
+		assert.NoError(t, listErr)
+		require.Len(t, keys, 1)
+		assert.Equal(t, created.Prefix, keys[0].Prefix)
+		assert.NotNil(t, keys[0].LastUsedAt)
+		assert.NoError(t, deleteErr)
+		assert.ErrorIs(t, revokedErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("ScopesRestrictKeys", func(t *testing.T) {
+		// Arrange
+		userService, u := newService(t)
+		created, err := userService.CreateAPIKey(ctxAs(u), user.CreateAPIKeyDTO{Name: "reader", Scopes: []string{"users:read", "api_keys:manage"}})
+		require.NoError(t, err)
+		principal, err := userService.VerifyAPIKey(context.Background(), created.Key)
+		require.NoError(t, err)
+		ctx := ctxAs(principal)
+
+		// Act
+		_, readErr := userService.GetUser(ctx, u.ID)
+		_, updateErr := userService.UpdateUser(ctx, u.ID, user.UpdateUserDTO{Name: "Renamed", Email: u.Email})
+		_, widenErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "wider", Scopes: []string{"users:update"}})
+		_, narrowErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "narrower", Scopes: []string{"users:read"}})
+
+		// Assert
+		assert.NoError(t, readErr)
+		assert.ErrorIs(t, updateErr, userservice.ErrForbidden)
+		assert.ErrorIs(t, widenErr, userservice.ErrForbidden)
+		assert.NoError(t, narrowErr)
+	})
+
+	t.Run("InvalidKeysRejected", func(t *testing.T) {
+		// Arrange
+		userService, u := newService(t, userservice.WithAPIKeyMaxTTL(time.Hour))
+		ctx := ctxAs(u)
+		past := time.Now().Add(-time.Minute)
+		tooLate := time.Now().Add(2 * time.Hour)
+
+		// Act
+		_, noNameErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Scopes: []string{"users:read"}})
+		_, noScopesErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "none"})
+		_, unknownErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "unknown", Scopes: []string{"users:everything"}})
+		_, pastErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "past", Scopes: []string{"users:read"}, ExpiresAt: &past})
+		_, tooLateErr := userService.CreateAPIKey(ctx, user.CreateAPIKeyDTO{Name: "late", Scopes: []string{"users:read"}, ExpiresAt: &tooLate})
+		_, verifyErr := userService.VerifyAPIKey(context.Background(), "dgk_unknown")
+
+		// Assert
+		assert.ErrorIs(t, noNameErr, userservice.ErrInvalidAPIKey)
+		assert.ErrorIs(t, noScopesErr, userservice.ErrInvalidAPIKey)
+		assert.ErrorIs(t, unknownErr, userservice.ErrInvalidAPIKey)
+		assert.ErrorIs(t, pastErr, userservice.ErrInvalidAPIKey)
+		assert.ErrorIs(t, tooLateErr, userservice.ErrInvalidAPIKey)
+		assert.ErrorIs(t, verifyErr, userservice.ErrInvalidCredentials)
+	})
+
+	t.Run("OthersKeysForbidden", func(t *testing.T) {
+		// Arrange
+		userService, u := newService(t)
+		created, err := userService.CreateAPIKey(ctxAs(u), user.CreateAPIKeyDTO{Name: "mine", Scopes: []string{"users:read"}})
+		require.NoError(t, err)
+		other := ctxAs(user.User{ID: "other"})
+
+		// Act
+		_, createErr := userService.CreateAPIKey(other, user.CreateAPIKeyDTO{Name: "theirs", UserID: u.ID, Scopes: []string{"users:read"}})
+		_, listErr := userService.ListAPIKeys(other, u.ID)
+		deleteErr := userService.DeleteAPIKey(other, created.ID)
+		adminKeys, adminErr := userService.ListAPIKeys(ctxAs(admin), u.ID)
+
+		// Assert
+		assert.ErrorIs(t, createErr, userservice.ErrForbidden)
+		assert.ErrorIs(t, listErr, userservice.ErrForbidden)
+		assert.ErrorIs(t, deleteErr, userservice.ErrForbidden)
+		assert.NoError(t, adminErr)
+		assert.Len(t, adminKeys, 1)
+	})
+}
+
+func TestUserService_Outbox(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	// failingService returns a started service whose notifier fails the
+	// first failures attempts, reporting each attempt on the channel
+	failingService := func(t *testing.T, failures int, maxAttempts int) (*userservice.Service, chan error) {
+		attempts := make(chan error, 10)
+		failure := errors.New("mail server down")
+		mockNotifier := mocknotifier.NewNotifier()
+		mockNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(failure).Times(failures).Run(func(testmock.Arguments) {
+			attempts <- failure
+		})
+		mockNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(nil).Run(func(testmock.Arguments) {
+			attempts <- nil
+		})
+		mockNotifier.On("Close", testmock.Anything).Return(nil)
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), mockNotifier, userservice.WithOutboxRelay(5*time.Millisecond, maxAttempts, time.Millisecond))
+		require.NoError(t, userService.Start())
+		t.Cleanup(func() { userService.Stop() })
+		return userService, attempts
+	}
+
+	next := func(t *testing.T, attempts chan error) error {
+		select {
+		case err := <-attempts:
+			return err
+		case <-time.After(time.Second):
+			t.Fatal("no delivery attempt")
+			return nil
+		}
+	}
+
+	t.Run("RetriesUntilDelivered", func(t *testing.T) {
+		// Arrange
+		userService, attempts := failingService(t, 2, 5)
+
+		// Act
+		_, err := userService.CreateUser(context.Background(), user.CreateUserDTO{Name: "Retry", Email: "retry@test.com"})
+
+		// Assert
+		require.NoError(t, err)
+		assert.Error(t, next(t, attempts))
+		assert.Error(t, next(t, attempts))
+		assert.NoError(t, next(t, attempts))
+		select {
+		case <-attempts:
+			t.Fatal("sent a delivered message again")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+
+	t.Run("DeadLettersAfterMaxAttempts", func(t *testing.T) {
+		// Arrange
+		userService, attempts := failingService(t, 100, 3)
+
+		// Act
+		_, err := userService.CreateUser(context.Background(), user.CreateUserDTO{Name: "Dead", Email: "dead@test.com"})
+
+		// Assert
+		require.NoError(t, err)
+		for range 3 {
+			assert.Error(t, next(t, attempts))
+		}
+		select {
+		case <-attempts:
+			t.Fatal("retried a dead-lettered message")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestUserService_Events(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	// eventCapturingService returns a started service whose event
+	// notifier delivers the options of each event to the channel
+	eventCapturingService := func(t *testing.T) (*userservice.Service, chan notifier.NotifyOptions) {
+		events := make(chan notifier.NotifyOptions, 10)
+		eventNotifier := mocknotifier.NewNotifier()
+		eventNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(nil).Run(func(args testmock.Arguments) {
+			events <- notifier.NewNotifyOptions(args.Get(3).([]notifier.NotifyOption)...)
+		})
+		eventNotifier.On("Close", testmock.Anything).Return(nil)
+		emailNotifier := mocknotifier.NewNotifier()
+		emailNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(nil)
+		emailNotifier.On("Close", testmock.Anything).Return(nil)
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), emailNotifier, userservice.WithEventNotifier(eventNotifier))
+		require.NoError(t, userService.Start())
+		t.Cleanup(func() { userService.Stop() })
+		return userService, events
+	}
+
+	next := func(t *testing.T, events chan notifier.NotifyOptions) (notifier.NotifyOptions, user.User) {
+		select {
+		case options := <-events:
+			var u user.User
+			require.NoError(t, json.Unmarshal(options.Payload.(json.RawMessage), &u))
+			return options, u
+		case <-time.After(time.Second):
+			t.Fatal("no event")
+			return notifier.NotifyOptions{}, user.User{}
+		}
+	}
+
+	t.Run("LifecycleEvents", func(t *testing.T) {
+		// Arrange
+		userService, events := eventCapturingService(t)
+
+		// Act
+		created, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Events", Email: "events@test.com"})
+		require.NoError(t, err)
+		createdEvent, createdPayload := next(t, events)
+
+		_, err = userService.UpdateUser(ctx, created.ID, user.UpdateUserDTO{Name: "Renamed", Email: "events@test.com"})
+		require.NoError(t, err)
+		updatedEvent, updatedPayload := next(t, events)
+
+		err = userService.DeleteUser(ctx, created.ID)
+		require.NoError(t, err)
+		deletedEvent, deletedPayload := next(t, events)
+
+		// Assert
+		assert.Equal(t, userservice.EventUserCreated, createdEvent.Event)
+		assert.Equal(t, created.ID, createdPayload.ID)
+		assert.Equal(t, userservice.EventUserUpdated, updatedEvent.Event)
+		assert.Equal(t, "Renamed", updatedPayload.Name)
+		assert.Equal(t, userservice.EventUserDeleted, deletedEvent.Event)
+		assert.Equal(t, created.ID, deletedPayload.ID)
+		assert.NotEmpty(t, createdEvent.IdempotencyKey)
+		assert.NotEqual(t, createdEvent.IdempotencyKey, updatedEvent.IdempotencyKey)
+	})
+
+	t.Run("NoEventsForFailedChanges", func(t *testing.T) {
+		// Arrange
+		userService, events := eventCapturingService(t)
+
+		// Act
+		err := userService.DeleteUser(ctx, "missing")
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrUserNotFound)
+		select {
+		case options := <-events:
+			t.Fatalf("sent %s event", options.Event)
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
+
+func TestUserService_Webhooks(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	admin := user.User{ID: "admin", Roles: []user.Role{user.RoleAdmin}}
+	asAdmin := ctxAs(admin)
+
+	// webhookService returns a service posting to subscribed webhooks,
+	// making one request per relay attempt
+	webhookService := func(t *testing.T) *userservice.Service {
+		webhooks, err := webhook.NewNotifier(webhook.WithRetry(1, time.Millisecond))
+		require.NoError(t, err)
+		emailNotifier := mocknotifier.NewNotifier()
+		emailNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(nil)
+		emailNotifier.On("Close", testmock.Anything).Return(nil)
+		return userservice.New(
+			memoryuserrepo.NewUserRepo(),
+			emailNotifier,
+			userservice.WithAuthorizer(authz.NewPolicyAuthorizer(authz.DefaultPolicies()...)),
+			userservice.WithWebhookNotifier(webhooks),
+			userservice.WithOutboxRelay(5*time.Millisecond, 5, time.Millisecond),
+		)
+	}
+
+	start := func(t *testing.T, userService *userservice.Service) {
+		require.NoError(t, userService.Start())
+		t.Cleanup(func() { userService.Stop() })
+	}
+
+	t.Run("OnlyAdminsManage", func(t *testing.T) {
+		// Arrange
+		userService := webhookService(t)
+		someone := ctxAs(user.User{ID: "someone"})
+
+		// Act
+		_, createErr := userService.CreateWebhook(someone, user.CreateWebhookDTO{URL: "https://example.com/hook"})
+		_, listErr := userService.ListWebhooks(someone)
+
+		// Assert
+		assert.ErrorIs(t, createErr, userservice.ErrForbidden)
+		assert.ErrorIs(t, listErr, userservice.ErrForbidden)
+	})
+
+	t.Run("ValidatesSubscriptions", func(t *testing.T) {
+		// Arrange
+		userService := webhookService(t)
+
+		// Act
+		created, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook", Events: []string{userservice.EventUserDeleted}})
+		_, badURL := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "example.com/hook"})
+		_, badEvent := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook", Events: []string{"user.exploded"}})
+		_, shortSecret := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook", Secret: "short"})
+		listed, listErr := userService.ListWebhooks(asAdmin)
+
+		// Assert
+		require.NoError(t, err)
+		assert.True(t, strings.HasPrefix(created.Secret, "whsec_"))
+		assert.Equal(t, []string{userservice.EventUserDeleted}, created.Events)
+		assert.ErrorIs(t, badURL, userservice.ErrInvalidWebhook)
+		assert.ErrorIs(t, badEvent, userservice.ErrInvalidWebhook)
+		assert.ErrorIs(t, shortSecret, userservice.ErrInvalidWebhook)
+		require.NoError(t, listErr)
+		require.Len(t, listed, 1)
+		assert.Equal(t, created.ID, listed[0].ID)
+	})
+
+	t.Run("DeliversSubscribedEventsAndLogsAttempts", func(t *testing.T) {
+		// Arrange
+		receiver := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusOK)
+		userService := webhookService(t)
+		start(t, userService)
+		hook, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{
+			URL:    receiver.URL,
+			Secret: "a-long-enough-secret",
+			Events: []string{userservice.EventUserCreated},
+		})
+		require.NoError(t, err)
+
+		// Act
+		created, err := userService.CreateUser(asAdmin, user.CreateUserDTO{Name: "Hooked", Email: "hooked@test.com"})
+		require.NoError(t, err)
+		_, err = userService.UpdateUser(asAdmin, created.ID, user.UpdateUserDTO{Name: "Renamed", Email: "hooked@test.com"})
+		require.NoError(t, err)
+
+		// Assert
+		var deliveries []user.WebhookDelivery
+		require.Eventually(t, func() bool {
+			deliveries, err = userService.ListWebhookDeliveries(asAdmin, hook.ID, 0)
+			return err == nil && len(deliveries) == 1 && deliveries[0].Status == "succeeded"
+		}, time.Second, 5*time.Millisecond)
+		assert.Equal(t, userservice.EventUserCreated, deliveries[0].Event)
+		require.Len(t, deliveries[0].Attempts, 2)
+		assert.Equal(t, http.StatusInternalServerError, deliveries[0].Attempts[0].StatusCode)
+		assert.NotEmpty(t, deliveries[0].Attempts[0].Error)
+		assert.Equal(t, http.StatusOK, deliveries[0].Attempts[1].StatusCode)
+
+		reqs := receiver.received()
+		require.Len(t, reqs, 2)
+		assert.Equal(t, deliveries[0].EventID, reqs[1].header.Get(webhook.IDHeader))
+		assert.NoError(t, webhook.Verify("a-long-enough-secret", reqs[1].header.Get(webhook.TimestampHeader), reqs[1].header.Get(webhook.SignatureHeader), reqs[1].body, time.Minute, time.Now()))
+	})
+
+	t.Run("RedeliversFinishedDeliveries", func(t *testing.T) {
+		// Arrange
+		receiver := newWebhookReceiver(t, http.StatusOK)
+		userService := webhookService(t)
+		start(t, userService)
+		hook, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: receiver.URL})
+		require.NoError(t, err)
+		_, err = userService.CreateUser(asAdmin, user.CreateUserDTO{Name: "Again", Email: "again@test.com"})
+		require.NoError(t, err)
+		var deliveries []user.WebhookDelivery
+		require.Eventually(t, func() bool {
+			deliveries, err = userService.ListWebhookDeliveries(asAdmin, hook.ID, 0)
+			return err == nil && len(deliveries) == 1 && deliveries[0].Status == "succeeded"
+		}, time.Second, 5*time.Millisecond)
+
+		// Act
+		redelivered, err := userService.RedeliverWebhookDelivery(asAdmin, hook.ID, deliveries[0].ID)
+		_, wrongWebhook := userService.RedeliverWebhookDelivery(asAdmin, "other", deliveries[0].ID)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Equal(t, "pending", redelivered.Status)
+		assert.ErrorIs(t, wrongWebhook, userservice.ErrWebhookDeliveryNotFound)
+		require.Eventually(t, func() bool { return len(receiver.received()) == 2 }, time.Second, 5*time.Millisecond)
+		reqs := receiver.received()
+		assert.Equal(t, reqs[0].header.Get(webhook.IDHeader), reqs[1].header.Get(webhook.IDHeader))
+	})
+
+	t.Run("PendingDeliveriesCannotBeRedelivered", func(t *testing.T) {
+		// Arrange
+		userService := webhookService(t) // not started, so nothing is sent
+		hook, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook"})
+		require.NoError(t, err)
+		_, err = userService.CreateUser(asAdmin, user.CreateUserDTO{Name: "Waiting", Email: "waiting@test.com"})
+		require.NoError(t, err)
+		deliveries, err := userService.ListWebhookDeliveries(asAdmin, hook.ID, 0)
+		require.NoError(t, err)
+		require.Len(t, deliveries, 1)
+
+		// Act
+		_, err = userService.RedeliverWebhookDelivery(asAdmin, hook.ID, deliveries[0].ID)
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrWebhookDeliveryPending)
+	})
+
+	t.Run("DeletingRemovesDeliveries", func(t *testing.T) {
+		// Arrange
+		userService := webhookService(t)
+		hook, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook"})
+		require.NoError(t, err)
+
+		// Act
+		err = userService.DeleteWebhook(asAdmin, hook.ID)
+		_, listErr := userService.ListWebhookDeliveries(asAdmin, hook.ID, 0)
+		deleteAgain := userService.DeleteWebhook(asAdmin, hook.ID)
+
+		// Assert
+		require.NoError(t, err)
+		assert.ErrorIs(t, listErr, userservice.ErrWebhookNotFound)
+		assert.ErrorIs(t, deleteAgain, userservice.ErrWebhookNotFound)
+	})
+
+	t.Run("DisabledWithoutNotifier", func(t *testing.T) {
+		// Arrange
+		userService := userservice.New(memoryuserrepo.NewUserRepo(), mocknotifier.NewNotifier())
+
+		// Act
+		_, err := userService.CreateWebhook(asAdmin, user.CreateWebhookDTO{URL: "https://example.com/hook"})
+
+		// Assert
+		assert.ErrorIs(t, err, userservice.ErrWebhooksDisabled)
+	})
+}
+
+func TestUserService_BackgroundWork(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+
+	// blockEmailLookups has the repo's email lookups wait for a value
+	// on the channel returned, which is closed when the test ends
+	blockEmailLookups := func(t *testing.T, mockRepo *testmock.Mock) chan struct{} {
+		release := make(chan struct{})
+		t.Cleanup(func() { close(release) })
+		mockRepo.On("GetByEmail", testmock.Anything, testmock.Anything).Return(user.User{}, userrepo.ErrUserNotFound).Run(func(testmock.Arguments) {
+			<-release
+		})
+		mockRepo.On("ClaimOutboxMessages", testmock.Anything, testmock.Anything, testmock.Anything).Return([]userrepo.OutboxMessage{}, nil)
+		mockRepo.On("Close", testmock.Anything).Return(nil)
+		return release
+	}
+
+	t.Run("StopDrainsQueuedEmails", func(t *testing.T) {
+		// Arrange
+		userService, tokens := tokenCapturingService(t, userservice.WithWorkers(1, 10))
+		emails := []string{"a@test.com", "b@test.com", "c@test.com"}
+		for _, email := range emails {
+			_, err := userService.CreateUser(ctx, user.CreateUserDTO{Name: "Test User", Email: email})
+			require.NoError(t, err)
+			<-tokens // the welcome email's verification token
+		}
+		for _, email := range emails {
+			require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: email}))
+		}
+
+		// Act
+		err := userService.Stop()
+		afterErr := userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: emails[0]})
+
+		// Assert
+		assert.NoError(t, err)
+		assert.Len(t, tokens, len(emails))
+		assert.ErrorIs(t, afterErr, userservice.ErrBusy)
+	})
+
+	t.Run("FullQueueTurnsRequestsAway", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		release := blockEmailLookups(t, mockRepo.Mock)
+		userService := userservice.New(mockRepo, mocknotifier.NewNotifier(), userservice.WithWorkers(1, 1))
+		dto := user.PasswordResetRequestDTO{Email: "a@test.com"}
+		// one for the worker, one for the queue
+		require.NoError(t, userService.RequestPasswordReset(ctx, dto))
+		require.NoError(t, userService.RequestPasswordReset(ctx, dto))
+
+		// Act
+		busyErr := userService.RequestPasswordReset(ctx, dto)
+		go func() {
+			time.Sleep(50 * time.Millisecond)
+			release <- struct{}{}
+		}()
+		waitedErr := userService.RequestPasswordReset(ctx, dto)
+
+		// Assert
+		assert.ErrorIs(t, busyErr, userservice.ErrBusy)
+		assert.NoError(t, waitedErr)
+	})
+
+	t.Run("StopAbandonsWorkPastTheDeadline", func(t *testing.T) {
+		// Arrange
+		mockRepo := mockrepo.NewUserRepo()
+		blockEmailLookups(t, mockRepo.Mock)
+		mockNotifier := mocknotifier.NewNotifier()
+		mockNotifier.On("Close", testmock.Anything).Return(nil)
+		userService := userservice.New(mockRepo, mockNotifier, userservice.WithWorkers(1, 5), userservice.WithShutdownTimeout(50*time.Millisecond))
+		require.NoError(t, userService.Start())
+		for range 3 {
+			require.NoError(t, userService.RequestPasswordReset(ctx, user.PasswordResetRequestDTO{Email: "a@test.com"}))
+		}
+
+		// Act
+		err := userService.Stop()
+
+		// Assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, "abandoned 3 background tasks")
+		mockRepo.AssertCalled(t, "Close", testmock.Anything)
+		mockNotifier.AssertCalled(t, "Close", testmock.Anything)
+	})
+}
+
+// tokenCapturingService returns a service whose notifications deliver their tokens to the channel,
+// and starts it, so its outbox relay runs until the test ends
+func tokenCapturingService(t *testing.T, opts ...userservice.Option) (*userservice.Service, chan string) {
+	tokens := make(chan string, 10)
+	mockNotifier := mocknotifier.NewNotifier()
+	mockNotifier.On("Notify", testmock.Anything, testmock.Anything, testmock.Anything, testmock.Anything).Return(nil).Run(func(args testmock.Arguments) {
+		tokens <- notifier.NewNotifyOptions(args.Get(3).([]notifier.NotifyOption)...).Token
+	})
+	mockNotifier.On("Close", testmock.Anything).Return(nil)
+	userService := userservice.New(memoryuserrepo.NewUserRepo(), mockNotifier, opts...)
+	require.NoError(t, userService.Start())
+	t.Cleanup(func() { userService.Stop() })
+	return userService, tokens
+}
+
+func ctxAs(principal user.User) context.Context {
+	return context.WithValue(context.Background(), middleware.UserKey{}, principal)
+}
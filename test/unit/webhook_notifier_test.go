@@ -0,0 +1,275 @@
+package unit
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/w-h-a/demo-go/internal/client/notifier"
+	"github.com/w-h-a/demo-go/internal/client/notifier/webhook"
+)
+
+func TestWebhookNotifier(t *testing.T) {
+	if len(os.Getenv("INTEGRATION")) > 0 {
+		t.Log("SKIPPING UNIT TEST")
+		return
+	}
+
+	ctx := context.Background()
+	secret := "whsec-test"
+
+	event := []notifier.NotifyOption{
+		notifier.WithEvent("user.created"),
+		notifier.WithPayload(map[string]string{"id": "u1"}),
+		notifier.WithIdempotencyKey("evt-1"),
+	}
+
+	t.Run("SignsEvents", func(t *testing.T) {
+		// Arrange
+		srv := newWebhookReceiver(t, http.StatusOK)
+		n, err := webhook.NewNotifier(webhook.WithEndpoint(srv.URL, secret, time.Second))
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(ctx, "", "", event...)
+
+		// Assert
+		require.NoError(t, err)
+		reqs := srv.received()
+		require.Len(t, reqs, 1)
+		assert.Equal(t, "application/json", reqs[0].header.Get("Content-Type"))
+		assert.Equal(t, "evt-1", reqs[0].header.Get(webhook.IDHeader))
+		assert.Equal(t, "user.created", reqs[0].header.Get(webhook.EventHeader))
+		assert.NoError(t, webhook.Verify(secret, reqs[0].header.Get(webhook.TimestampHeader), reqs[0].header.Get(webhook.SignatureHeader), reqs[0].body, time.Minute, time.Now()))
+
+		var got webhook.Event
+		require.NoError(t, json.Unmarshal(reqs[0].body, &got))
+		assert.Equal(t, "evt-1", got.ID)
+		assert.Equal(t, "user.created", got.Type)
+		assert.JSONEq(t, `{"id":"u1"}`, string(got.Data))
+	})
+
+	t.Run("VerifyRejectsTamperingAndReplays", func(t *testing.T) {
+		// Arrange
+		body := []byte(`{"id":"evt-1"}`)
+		signedAt := time.Unix(1700000000, 0)
+		timestamp := "1700000000"
+		signature := webhook.Sign(secret, signedAt, body)
+
+		// Act
+		fresh := webhook.Verify(secret, timestamp, signature, body, 5*time.Minute, signedAt.Add(time.Minute))
+		tampered := webhook.Verify(secret, timestamp, signature, []byte(`{"id":"evt-2"}`), 5*time.Minute, signedAt)
+		wrongSecret := webhook.Verify("other", timestamp, signature, body, 5*time.Minute, signedAt)
+		retimed := webhook.Verify(secret, "1700000001", signature, body, 5*time.Minute, signedAt)
+		replayed := webhook.Verify(secret, timestamp, signature, body, 5*time.Minute, signedAt.Add(time.Hour))
+
+		// Assert
+		assert.NoError(t, fresh)
+		assert.ErrorIs(t, tampered, webhook.ErrInvalidSignature)
+		assert.ErrorIs(t, wrongSecret, webhook.ErrInvalidSignature)
+		assert.ErrorIs(t, retimed, webhook.ErrInvalidSignature)
+		assert.ErrorIs(t, replayed, webhook.ErrStaleTimestamp)
+	})
+
+	t.Run("RetriesServerErrors", func(t *testing.T) {
+		// Arrange
+		srv := newWebhookReceiver(t, http.StatusInternalServerError, http.StatusTooManyRequests, http.StatusNoContent)
+		n, err := webhook.NewNotifier(
+			webhook.WithEndpoint(srv.URL, secret, time.Second),
+			webhook.WithRetry(3, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(ctx, "", "", event...)
+
+		// Assert
+		require.NoError(t, err)
+		reqs := srv.received()
+		require.Len(t, reqs, 3)
+		for _, r := range reqs {
+			assert.Equal(t, "evt-1", r.header.Get(webhook.IDHeader))
+		}
+	})
+
+	t.Run("GivesUpAfterAttempts", func(t *testing.T) {
+		// Arrange
+		srv := newWebhookReceiver(t, http.StatusBadGateway)
+		n, err := webhook.NewNotifier(
+			webhook.WithEndpoint(srv.URL, secret, time.Second),
+			webhook.WithRetry(2, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(ctx, "", "", event...)
+
+		// Assert
+		assert.ErrorContains(t, err, "502")
+		assert.Len(t, srv.received(), 2)
+	})
+
+	t.Run("DoesNotRetryClientErrors", func(t *testing.T) {
+		// Arrange
+		srv := newWebhookReceiver(t, http.StatusBadRequest)
+		n, err := webhook.NewNotifier(
+			webhook.WithEndpoint(srv.URL, secret, time.Second),
+			webhook.WithRetry(3, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(ctx, "", "", event...)
+
+		// Assert
+		assert.ErrorContains(t, err, "400")
+		assert.Len(t, srv.received(), 1)
+	})
+
+	t.Run("TimesOutPerEndpoint", func(t *testing.T) {
+		// Arrange
+		release := make(chan struct{})
+		slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+			}
+		}))
+		t.Cleanup(slow.Close)
+		t.Cleanup(func() { close(release) })
+		fast := newWebhookReceiver(t, http.StatusOK)
+		n, err := webhook.NewNotifier(
+			webhook.WithEndpoint(slow.URL, secret, 20*time.Millisecond),
+			webhook.WithEndpoint(fast.URL, secret, time.Second),
+			webhook.WithRetry(1, time.Millisecond),
+		)
+		require.NoError(t, err)
+
+		// Act
+		start := time.Now()
+		err = n.Notify(ctx, "", "", event...)
+
+		// Assert
+		assert.ErrorIs(t, err, context.DeadlineExceeded)
+		assert.ErrorContains(t, err, slow.URL)
+		assert.Less(t, time.Since(start), time.Second)
+		assert.Len(t, fast.received(), 1)
+	})
+
+	t.Run("InvalidConfigurationIsRejected", func(t *testing.T) {
+		for name, opts := range map[string][]notifier.Option{
+			"NoSecret":  {webhook.WithEndpoint("https://example.com/hook", "", 0)},
+			"BadScheme": {webhook.WithEndpoint("ftp://example.com/hook", secret, 0)},
+			"NoHost":    {webhook.WithEndpoint("https:///hook", secret, 0)},
+		} {
+			// Act
+			_, err := webhook.NewNotifier(opts...)
+
+			// Assert
+			assert.Error(t, err, name)
+		}
+	})
+
+	t.Run("PostsToEndpointGivenPerNotification", func(t *testing.T) {
+		// Arrange
+		configured := newWebhookReceiver(t, http.StatusOK)
+		given := newWebhookReceiver(t, http.StatusServiceUnavailable, http.StatusAccepted)
+		n, err := webhook.NewNotifier(
+			webhook.WithEndpoint(configured.URL, secret, time.Second),
+			webhook.WithRetry(2, time.Millisecond),
+		)
+		require.NoError(t, err)
+		var codes []int
+
+		// Act
+		err = n.Notify(ctx, "", "", append(event,
+			notifier.WithEndpoint(given.URL, "other-secret"),
+			notifier.WithOnAttempt(func(statusCode int, err error) { codes = append(codes, statusCode) }),
+		)...)
+
+		// Assert
+		require.NoError(t, err)
+		assert.Empty(t, configured.received())
+		reqs := given.received()
+		require.Len(t, reqs, 2)
+		assert.NoError(t, webhook.Verify("other-secret", reqs[1].header.Get(webhook.TimestampHeader), reqs[1].header.Get(webhook.SignatureHeader), reqs[1].body, time.Minute, time.Now()))
+		assert.Equal(t, []int{http.StatusServiceUnavailable, http.StatusAccepted}, codes)
+	})
+
+	t.Run("NeedsSomewhereToPost", func(t *testing.T) {
+		// Arrange
+		n, err := webhook.NewNotifier()
+		require.NoError(t, err)
+
+		// Act
+		noEndpoint := n.Notify(ctx, "", "", event...)
+		badEndpoint := n.Notify(ctx, "", "", append(event, notifier.WithEndpoint("ftp://example.com", secret))...)
+
+		// Assert
+		assert.Error(t, noEndpoint)
+		assert.Error(t, badEndpoint)
+	})
+
+	t.Run("EventIsRequired", func(t *testing.T) {
+		// Arrange
+		srv := newWebhookReceiver(t, http.StatusOK)
+		n, err := webhook.NewNotifier(webhook.WithEndpoint(srv.URL, secret, time.Second))
+		require.NoError(t, err)
+
+		// Act
+		err = n.Notify(ctx, "Ada", "ada@example.com", notifier.WithKind(notifier.KindWelcome))
+
+		// Assert
+		assert.Error(t, err)
+		assert.Empty(t, srv.received())
+	})
+}
+
+// webhookRequest is a request as the receiver got it
+type webhookRequest struct {
+	header http.Header
+	body   []byte
+}
+
+type webhookReceiver struct {
+	*httptest.Server
+	mu   sync.Mutex
+	reqs []webhookRequest
+}
+
+// newWebhookReceiver responds to the nth request with the nth status,
+// and to any after the last with the last
+func newWebhookReceiver(t *testing.T, statuses ...int) *webhookReceiver {
+	t.Helper()
+
+	r := &webhookReceiver{}
+
+	r.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+
+		r.mu.Lock()
+		r.reqs = append(r.reqs, webhookRequest{header: req.Header.Clone(), body: body})
+		status := statuses[min(len(r.reqs), len(statuses))-1]
+		r.mu.Unlock()
+
+		w.WriteHeader(status)
+	}))
+
+	t.Cleanup(r.Close)
+
+	return r
+}
+
+func (r *webhookReceiver) received() []webhookRequest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return append([]webhookRequest(nil), r.reqs...)
+}